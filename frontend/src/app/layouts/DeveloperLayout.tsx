@@ -1,7 +1,7 @@
 import { Anchor, AppShell, Group } from '@mantine/core';
 import { useMemo } from 'react';
 import { Link, Outlet, useLocation } from 'react-router-dom';
-import { useAuth } from '../../features/auth';
+import { isStaffRole, useAuth } from '../../features/auth';
 import { appConfig } from '../../lib/config';
 import { ThemeToggle } from '../components/ThemeToggle';
 import { UserMenu } from '../components/UserMenu';
@@ -14,14 +14,14 @@ export const DeveloperLayout = () => {
     const links: Array<{ label: string; to: string; key: string }> = [
       { label: 'Rent mode', to: '/app', key: 'rent' },
     ];
-    if (user?.is_staff) {
+    if (isStaffRole(user?.role)) {
       links.push({ label: 'Staff mode', to: '/staff/dashboard', key: 'staff' });
     }
     if (appConfig.developerMode) {
       links.push({ label: 'Dev mode', to: '/dev', key: 'dev' });
     }
     return links;
-  }, [user?.is_staff]);
+  }, [user?.role]);
 
   const activeMode = location.pathname.startsWith('/staff')
     ? 'staff'
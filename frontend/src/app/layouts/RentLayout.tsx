@@ -3,7 +3,7 @@ import { IconLayoutDashboard, IconUser, IconKey } from '@tabler/icons-react';
 import { useDisclosure } from '@mantine/hooks';
 import { Outlet, useLocation, Link } from 'react-router-dom';
 import { useMemo } from 'react';
-import { useAuth } from '../../features/auth';
+import { isStaffRole, useAuth } from '../../features/auth';
 import { appConfig } from '../../lib/config';
 import { ThemeToggle } from '../components/ThemeToggle';
 import { UserMenu } from '../components/UserMenu';
@@ -15,14 +15,14 @@ export const RentLayout = () => {
 
   const modeLinks = useMemo(() => {
     const links = [{ label: 'Rent mode', to: '/app', key: 'rent' }];
-    if (user?.is_staff) {
+    if (isStaffRole(user?.role)) {
       links.push({ label: 'Staff mode', to: '/staff/dashboard', key: 'staff' });
     }
     if (appConfig.developerMode) {
       links.push({ label: 'Dev mode', to: '/dev', key: 'dev' });
     }
     return links;
-  }, [user?.is_staff]);
+  }, [user?.role]);
 
   const navItems = useMemo(
     () => [
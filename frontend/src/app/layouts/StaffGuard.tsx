@@ -1,10 +1,10 @@
 import { Navigate, Outlet } from 'react-router-dom';
-import { useAuth } from '../../features/auth';
+import { isStaffRole, useAuth } from '../../features/auth';
 
 export const StaffGuard = () => {
   const { user } = useAuth();
 
-  if (!user?.is_staff) {
+  if (!isStaffRole(user?.role)) {
     return <Navigate to="/app" replace />;
   }
 
@@ -2,7 +2,7 @@ import { Anchor, AppShell, Burger, Group, NavLink, ScrollArea } from '@mantine/c
 import { IconGauge, IconKey, IconMap, IconUsers, IconBuildingWarehouse } from '@tabler/icons-react';
 import { useDisclosure } from '@mantine/hooks';
 import { Outlet, useLocation, Link, Navigate } from 'react-router-dom';
-import { useAuth } from '../../features/auth';
+import { isStaffRole, useAuth } from '../../features/auth';
 import { appConfig } from '../../lib/config';
 import { ThemeToggle } from '../components/ThemeToggle';
 import { UserMenu } from '../components/UserMenu';
@@ -20,7 +20,7 @@ export const StaffLayout = () => {
   const { user, logout } = useAuth();
   const location = useLocation();
 
-  if (!user?.is_staff) {
+  if (!isStaffRole(user?.role)) {
     return <Navigate to="/app" replace />;
   }
 
@@ -2,7 +2,7 @@ import { Anchor, Button, Paper, PasswordInput, Stack, Text, TextInput, Title } f
 import { useForm } from '@mantine/form';
 import { useState } from 'react';
 import { useLocation, useNavigate, type Location, Link } from 'react-router-dom';
-import { useAuth, type AuthUser } from '../../features/auth';
+import { isStaffRole, useAuth, type AuthUser } from '../../features/auth';
 import { pb } from '../../lib/pocketbase';
 
 interface LoginForm {
@@ -28,7 +28,7 @@ export const LoginPage = () => {
     setError(null);
     try {
       const loggedIn = (await login(values.email, values.password)) ?? (pb.authStore.model as AuthUser | null);
-      const redirectFallback = loggedIn?.is_staff ? '/staff/dashboard' : '/app';
+      const redirectFallback = isStaffRole(loggedIn?.role) ? '/staff/dashboard' : '/app';
       const redirectTo = location?.state?.from?.pathname ?? redirectFallback;
       navigate(redirectTo, { replace: true });
     } catch (err) {
@@ -3,6 +3,7 @@ import { useDebouncedValue } from '@mantine/hooks';
 import { showNotification } from '@mantine/notifications';
 import { useEffect, useMemo, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
+import { getRequestErrorMessage } from '../../../features/requests/api';
 import { useCreateRequestMutation, useStaffUsersQuery, useUpsertAssignmentMutation } from '../../../features/staff/hooks';
 import { StaffRequestForm, type StaffRequestFormValues } from '../../../features/staff/components/StaffRequestForm';
 import { REQUEST_STATUS_OPTIONS } from '../../../features/staff/constants';
@@ -18,6 +19,7 @@ const DEFAULT_VALUES: StaffRequestFormValues = {
   preferred_zone: '',
   preferred_locker: '',
   status: 'pending',
+  allow_duplicate: false,
 };
 
 export const StaffRequestCreatePage = () => {
@@ -63,6 +65,7 @@ export const StaffRequestCreatePage = () => {
         preferred_zone: values.preferred_zone || null,
         preferred_locker: values.preferred_locker || null,
         status: values.status,
+        allow_duplicate: values.allow_duplicate,
       });
 
       if (lockerId) {
@@ -73,7 +76,11 @@ export const StaffRequestCreatePage = () => {
       navigate(`/staff/requests/${record.id}`, { replace: true });
     } catch (error) {
       console.error(error);
-      showNotification({ color: 'red', title: 'Create failed', message: 'Unable to create the request.' });
+      showNotification({
+        color: 'red',
+        title: 'Create failed',
+        message: getRequestErrorMessage(error) ?? 'Unable to create the request.',
+      });
     }
   };
 
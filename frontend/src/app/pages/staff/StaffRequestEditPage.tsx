@@ -2,7 +2,7 @@ import { Button, Card, Loader, Stack, Text } from '@mantine/core';
 import { showNotification } from '@mantine/notifications';
 import { useEffect, useMemo, useState } from 'react';
 import { useNavigate, useParams } from 'react-router-dom';
-import type { LockerRequestRecord } from '../../../features/requests/api';
+import { getRequestErrorMessage, type LockerRequestRecord } from '../../../features/requests/api';
 import {
   useStaffAssignmentQuery,
   useStaffRequestQuery,
@@ -23,6 +23,7 @@ const mapRequestToValues = (request: LockerRequestRecord | null): StaffRequestFo
   preferred_zone: request?.preferred_zone ?? '',
   preferred_locker: request?.preferred_locker ?? '',
   status: request?.status ?? 'pending',
+  allow_duplicate: request?.allow_duplicate ?? false,
 });
 
 export const StaffRequestEditPage = () => {
@@ -86,6 +87,7 @@ export const StaffRequestEditPage = () => {
         preferred_zone: values.preferred_zone || null,
         preferred_locker: values.preferred_locker || null,
         status: values.status,
+        allow_duplicate: values.allow_duplicate,
         },
       });
       await upsertAssignmentMutation.mutateAsync({
@@ -97,7 +99,11 @@ export const StaffRequestEditPage = () => {
       navigate(`/staff/requests/${requestId}`, { replace: true });
     } catch (error) {
       console.error(error);
-      showNotification({ color: 'red', title: 'Save failed', message: 'Unable to update the request.' });
+      showNotification({
+        color: 'red',
+        title: 'Save failed',
+        message: getRequestErrorMessage(error) ?? 'Unable to update the request.',
+      });
     }
   };
 
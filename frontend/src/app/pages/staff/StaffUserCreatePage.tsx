@@ -1,7 +1,6 @@
 import {
   Button,
   Card,
-  Checkbox,
   Group,
   PasswordInput,
   Select,
@@ -12,8 +11,16 @@ import { useForm } from '@mantine/form';
 import { showNotification } from '@mantine/notifications';
 import { useNavigate } from 'react-router-dom';
 import { useCreateUserAccountMutation } from '../../../features/staff/hooks';
+import type { UserRole } from '../../../features/auth';
 import { PageTitle } from '../../components/PageTitle';
 
+const roleOptions = [
+  { value: 'family', label: 'Family' },
+  { value: 'janitor', label: 'Janitor (read-only)' },
+  { value: 'staff', label: 'Staff' },
+  { value: 'admin', label: 'Admin' },
+];
+
 const languageOptions = [
   { value: 'de', label: 'German' },
   { value: 'en', label: 'English' },
@@ -31,7 +38,7 @@ export const StaffUserCreatePage = () => {
       phone: '',
       address: '',
       language: 'de',
-      is_staff: false,
+      role: 'family' as UserRole,
     },
     validate: {
       email: (value) => (/^[^@\s]+@[^@\s]+\.[^@\s]+$/.test(value) ? null : 'Invalid email'),
@@ -68,7 +75,7 @@ export const StaffUserCreatePage = () => {
               <Select label="Language" data={languageOptions} {...form.getInputProps('language')} />
             </Group>
             <TextInput label="Address" placeholder="Optional" {...form.getInputProps('address')} />
-            <Checkbox label="Staff member" {...form.getInputProps('is_staff', { type: 'checkbox' })} />
+            <Select label="Role" data={roleOptions} allowDeselect={false} {...form.getInputProps('role')} />
             <Group justify="flex-end" mt="sm">
               <Button type="submit" loading={createUserMutation.isPending}>
                 Create user
@@ -106,7 +106,7 @@ export const StaffUserDetailsPage = () => {
           </div>
           <div>
             <Text fw={500}>Role</Text>
-            <Text size="sm">{user.is_staff ? 'Staff' : 'Family'}</Text>
+            <Text size="sm">{user.role ? user.role.charAt(0).toUpperCase() + user.role.slice(1) : 'Family'}</Text>
           </div>
         </Stack>
       </Card>
@@ -1,7 +1,6 @@
 import {
   Button,
   Card,
-  Checkbox,
   Group,
   Select,
   Stack,
@@ -13,8 +12,16 @@ import { showNotification } from '@mantine/notifications';
 import { useEffect, useRef } from 'react';
 import { useNavigate, useParams } from 'react-router-dom';
 import { useStaffUserQuery, useUpdateUserAccountMutation } from '../../../features/staff/hooks';
+import type { UserRole } from '../../../features/auth';
 import { PageTitle } from '../../components/PageTitle';
 
+const roleOptions = [
+  { value: 'family', label: 'Family' },
+  { value: 'janitor', label: 'Janitor (read-only)' },
+  { value: 'staff', label: 'Staff' },
+  { value: 'admin', label: 'Admin' },
+];
+
 const languageOptions = [
   { value: 'de', label: 'German' },
   { value: 'en', label: 'English' },
@@ -47,7 +54,7 @@ export const StaffUserEditPage = () => {
       phone: '',
       address: '',
       language: 'de',
-      is_staff: false,
+      role: 'family' as UserRole,
     },
   });
 
@@ -58,7 +65,7 @@ export const StaffUserEditPage = () => {
       phone: user.phone ?? '',
       address: user.address ?? '',
       language: user.language ?? 'de',
-      is_staff: Boolean(user.is_staff),
+      role: user.role ?? ('family' as UserRole),
     };
     const signature = JSON.stringify(nextValues);
     if (lastAppliedValuesRef.current === signature) {
@@ -121,7 +128,7 @@ export const StaffUserEditPage = () => {
               <Select label="Language" data={languageOptions} {...form.getInputProps('language')} />
             </Group>
             <TextInput label="Address" placeholder="Optional" {...form.getInputProps('address')} />
-            <Checkbox label="Staff member" {...form.getInputProps('is_staff', { type: 'checkbox' })} />
+            <Select label="Role" data={roleOptions} allowDeselect={false} {...form.getInputProps('role')} />
             <Group justify="flex-end" mt="sm">
               <Button type="submit" loading={updateUserMutation.isPending}>
                 Save changes
@@ -16,10 +16,25 @@ import { showNotification } from '@mantine/notifications';
 import { Link } from 'react-router-dom';
 import { useStaffUsersQuery } from '../../../features/staff/hooks';
 import type { StaffUserRecord } from '../../../features/staff/api';
+import type { UserRole } from '../../../features/auth';
 import { PageTitle } from '../../components/PageTitle';
 
 const USERS_PER_PAGE = 12;
-type RoleFilter = 'all' | 'staff' | 'family';
+
+const roleLabels: Record<UserRole, string> = {
+  family: 'Family',
+  janitor: 'Janitor',
+  staff: 'Staff',
+  admin: 'Admin',
+};
+
+const roleColors: Record<UserRole, string> = {
+  family: 'gray',
+  janitor: 'teal',
+  staff: 'blue',
+  admin: 'grape',
+};
+type RoleFilter = 'all' | UserRole;
 
 export const StaffUsersPage = () => {
   const [page, setPage] = useState(1);
@@ -61,16 +76,10 @@ export const StaffUsersPage = () => {
   const fetching = isLoading || isFetching;
 
   const roleBadge = useCallback((user: StaffUserRecord) => {
-    if (user.is_staff) {
-      return (
-        <Badge color="blue" variant="light">
-          Staff
-        </Badge>
-      );
-    }
+    const role = user.role ?? 'family';
     return (
-      <Badge color="gray" variant="light">
-        Family
+      <Badge color={roleColors[role]} variant="light">
+        {roleLabels[role]}
       </Badge>
     );
   }, []);
@@ -95,8 +104,10 @@ export const StaffUsersPage = () => {
         <Select
           data={[
             { value: 'all', label: 'All roles' },
-            { value: 'staff', label: 'Staff only' },
             { value: 'family', label: 'Family/Parents' },
+            { value: 'janitor', label: 'Janitors' },
+            { value: 'staff', label: 'Staff' },
+            { value: 'admin', label: 'Admins' },
           ]}
           value={roleFilter}
           onChange={(value) => setRoleFilter((value as RoleFilter) ?? 'all')}
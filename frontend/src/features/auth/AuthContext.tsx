@@ -37,7 +37,6 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
         address: profile.address ?? '',
         phone: profile.phone ?? '',
         language: profile.language ?? 'de',
-        is_staff: false,
         emailVisibility: true,
       });
 
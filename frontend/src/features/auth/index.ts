@@ -1,3 +1,4 @@
 export { AuthProvider } from './AuthContext';
 export { useAuth } from './useAuth';
-export type { AuthUser, SignupPayload, ProfileUpdatePayload, AuthContextValue } from './types';
+export { isStaffRole, userRoles } from './types';
+export type { AuthUser, UserRole, SignupPayload, ProfileUpdatePayload, AuthContextValue } from './types';
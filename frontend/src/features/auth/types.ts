@@ -1,7 +1,13 @@
 import type { RecordModel } from 'pocketbase';
 
+export type UserRole = 'family' | 'janitor' | 'staff' | 'admin';
+
+export const userRoles: UserRole[] = ['family', 'janitor', 'staff', 'admin'];
+
+export const isStaffRole = (role?: string | null) => role === 'staff' || role === 'admin';
+
 export type AuthUser = RecordModel & {
-  is_staff?: boolean;
+  role?: UserRole;
   language?: string;
   full_name?: string;
   address?: string;
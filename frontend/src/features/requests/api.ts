@@ -1,4 +1,4 @@
-import type { RecordModel } from 'pocketbase';
+import { ClientResponseError, type RecordModel } from 'pocketbase';
 import { pb } from '../../lib/pocketbase';
 
 export interface LockerRequestInput {
@@ -17,6 +17,7 @@ export type LockerRequestRecord = RecordModel & LockerRequestInput & {
   user: string;
   status: string;
   submitted_at: string;
+  allow_duplicate?: boolean;
   expand?: {
     preferred_zone?: ZoneRecord;
     user?: RecordModel;
@@ -35,6 +36,7 @@ export type AssignmentRecord = RecordModel & {
 
 export interface LockerRecord extends RecordModel {
   number: number;
+  label?: string;
   status: string;
   zone?: string;
   note?: string;
@@ -43,9 +45,28 @@ export interface LockerRecord extends RecordModel {
   };
 }
 
+const requestRuleErrors: Record<string, string> = {
+  student_name: 'validation_duplicate_request',
+  school_year: 'validation_request_quota',
+};
+
+/**
+ * Returns the server message when a request was rejected by a duplicate,
+ * quota or rate limit rule, or null for any other error.
+ */
+export function getRequestErrorMessage(error: unknown): string | null {
+  if (!(error instanceof ClientResponseError)) return null;
+  if (error.status === 429) return error.response?.message ?? 'Too many requests, please try again later.';
+  for (const [field, code] of Object.entries(requestRuleErrors)) {
+    const fieldError = error.response?.data?.[field];
+    if (fieldError?.code === code) return fieldError.message;
+  }
+  return null;
+}
+
 export async function listRequests(userId: string): Promise<LockerRequestRecord[]> {
   return pb.collection('requests').getFullList<LockerRequestRecord>({
-    filter: `user = "${userId}"`,
+    filter: pb.filter('user = {:user}', { user: userId }),
     sort: '-submitted_at',
     expand: 'preferred_zone',
   });
@@ -66,7 +87,7 @@ export async function cancelLockerRequest(requestId: string): Promise<LockerRequ
 
 export async function listAssignments(userId: string): Promise<AssignmentRecord[]> {
   return pb.collection('assignments').getFullList<AssignmentRecord>({
-    filter: `request.user = "${userId}"`,
+    filter: pb.filter('request.user = {:user}', { user: userId }),
     sort: '-assigned_at',
     expand: 'request,locker,locker.zone,request.preferred_zone',
   });
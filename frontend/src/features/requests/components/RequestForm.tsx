@@ -5,7 +5,7 @@ import { useForm } from '@mantine/form';
 import { showNotification } from '@mantine/notifications';
 import { IconPlus } from '@tabler/icons-react';
 import { useAuth } from '../../auth';
-import type { LockerRequestInput } from '../api';
+import { getRequestErrorMessage, type LockerRequestInput } from '../api';
 import { useCreateLockerRequestMutation, useZonesQuery } from '../hooks';
 import { useNavigate } from 'react-router-dom';
 
@@ -118,7 +118,11 @@ export const RequestForm = () => {
       navigate('/app', { replace: true });
     } catch (error) {
       console.error(error);
-      showNotification({ color: 'red', title: 'Submit error', message: 'Unable to submit request right now.' });
+      showNotification({
+        color: 'red',
+        title: 'Submit error',
+        message: getRequestErrorMessage(error) ?? 'Unable to submit request right now.',
+      });
     }
   });
 
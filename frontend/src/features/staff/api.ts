@@ -1,6 +1,7 @@
 import type { RecordModel } from 'pocketbase';
 import { pb } from '../../lib/pocketbase';
 import type { LockerRequestRecord, LockerRecord, ZoneRecord } from '../requests/api';
+import type { UserRole } from '../auth';
 
 export interface PaginatedResult<T> {
   items: T[];
@@ -10,7 +11,6 @@ export interface PaginatedResult<T> {
   totalPages: number;
 }
 
-const escapeFilterValue = (value: string) => value.replace(/"/g, '\\"');
 
 export interface StaffUserRecord extends RecordModel {
   full_name?: string;
@@ -18,24 +18,23 @@ export interface StaffUserRecord extends RecordModel {
   phone?: string;
   address?: string;
   language?: string;
-  is_staff?: boolean;
+  role?: UserRole;
   emailVisibility?: boolean;
 }
 
 export async function listUsers(
   page = 1,
   perPage = 20,
-  options?: { search?: string; staffOnly?: boolean | null },
+  options?: { search?: string; role?: UserRole | null },
 ): Promise<PaginatedResult<StaffUserRecord>> {
   const filters: string[] = [];
   if (options?.search) {
-    const term = escapeFilterValue(options.search);
-    filters.push(`(email ~ "${term}" || full_name ~ "${term}" || phone ~ "${term}")`);
+    filters.push(
+      pb.filter('(email ~ {:term} || full_name ~ {:term} || phone ~ {:term})', { term: options.search }),
+    );
   }
-  if (options?.staffOnly === true) {
-    filters.push('is_staff = true');
-  } else if (options?.staffOnly === false) {
-    filters.push('is_staff = false');
+  if (options?.role) {
+    filters.push(pb.filter('role = {:role}', { role: options.role }));
   }
 
   const result = await pb.collection('users').getList<StaffUserRecord>(page, perPage, {
@@ -58,19 +57,20 @@ export async function listAllRequests(
 ): Promise<PaginatedResult<LockerRequestRecord>> {
   const filters: string[] = [];
   if (options?.search) {
-    const term = escapeFilterValue(options.search);
     filters.push(
-      `(
-        student_name ~ "${term}" ||
-        requester_name ~ "${term}" ||
-        requester_address ~ "${term}" ||
-        requester_phone ~ "${term}"
+      pb.filter(
+        `(
+        student_name ~ {:term} ||
+        requester_name ~ {:term} ||
+        requester_address ~ {:term} ||
+        requester_phone ~ {:term}
       )`,
+        { term: options.search },
+      ),
     );
   }
   if (options?.status && options.status !== 'all') {
-    const term = escapeFilterValue(options.status);
-    filters.push(`status = "${term}"`);
+    filters.push(pb.filter('status = {:status}', { status: options.status }));
   }
 
   const result = await pb.collection('requests').getList<LockerRequestRecord>(page, perPage, {
@@ -111,6 +111,7 @@ export interface RequestUpdatePayload {
   preferred_zone?: string | null;
   preferred_locker?: string | null;
   status?: string;
+  allow_duplicate?: boolean;
 }
 
 export async function updateRequest(id: string, payload: RequestUpdatePayload): Promise<LockerRequestRecord> {
@@ -164,7 +165,7 @@ export interface AssignmentRecordLite extends RecordModel {
 
 export async function getAssignmentForRequest(requestId: string): Promise<AssignmentRecordLite | null> {
   const result = await pb.collection('assignments').getList<AssignmentRecordLite>(1, 1, {
-    filter: `request = "${requestId}"`,
+    filter: pb.filter('request = {:request}', { request: requestId }),
   });
   return result.items.at(0) ?? null;
 }
@@ -206,20 +207,17 @@ export async function listLockers(
 ): Promise<PaginatedResult<LockerRecord>> {
   const filters: string[] = [];
   if (options?.status && options.status !== 'all') {
-    const term = escapeFilterValue(options.status);
-    filters.push(`status = "${term}"`);
+    filters.push(pb.filter('status = {:status}', { status: options.status }));
   }
   if (options?.zone && options.zone !== 'all') {
-    const term = escapeFilterValue(options.zone);
-    filters.push(`zone = "${term}"`);
+    filters.push(pb.filter('zone = {:zone}', { zone: options.zone }));
   }
   if (options?.search) {
-    const term = escapeFilterValue(options.search);
-    const numeric = Number(term);
+    const numeric = Number(options.search);
     if (!Number.isNaN(numeric)) {
-      filters.push(`number = ${numeric}`);
+      filters.push(pb.filter('number = {:number}', { number: numeric }));
     } else {
-      filters.push(`(note ~ "${term}" )`);
+      filters.push(pb.filter('(label ~ {:term} || note ~ {:term})', { term: options.search }));
     }
   }
 
@@ -252,8 +250,7 @@ export async function listAllZones(
 ): Promise<PaginatedResult<ZoneRecord>> {
   const filters: string[] = [];
   if (options?.search) {
-    const term = escapeFilterValue(options.search);
-    filters.push(`(name ~ "${term}" || description ~ "${term}")`);
+    filters.push(pb.filter('(name ~ {:term} || description ~ {:term})', { term: options.search }));
   }
 
   const result = await pb.collection('zones').getList<ZoneRecord>(page, perPage, {
@@ -301,35 +298,24 @@ export interface StaffMetrics {
   totalZones: number;
 }
 
-const isoFromNow = (days: number) => {
-  const now = new Date();
-  now.setDate(now.getDate() - days);
-  return now.toISOString();
-};
+interface StaffDashboardStats {
+  users: { total: number; new_this_week: number };
+  zones: number;
+  occupancy: { total: number; statuses: Record<string, number> };
+  requests: { total: number; statuses: Record<string, number> };
+}
 
 export async function getStaffMetrics(): Promise<StaffMetrics> {
-  const weekAgoISO = isoFromNow(7);
-
-  const [usersAll, usersRecent, requestsAll, requestsPending, lockersAll, lockersFree, zonesAll] = await Promise.all([
-    pb.collection('users').getList<StaffUserRecord>(1, 1),
-    pb
-      .collection('users')
-      .getList<StaffUserRecord>(1, 1, { filter: `created >= "${weekAgoISO}"` }),
-    pb.collection('requests').getList<LockerRequestRecord>(1, 1),
-    pb.collection('requests').getList<LockerRequestRecord>(1, 1, { filter: 'status = "pending"' }),
-    pb.collection('lockers').getList<LockerRecord>(1, 1),
-    pb.collection('lockers').getList<LockerRecord>(1, 1, { filter: 'status = "free"' }),
-    pb.collection('zones').getList<ZoneRecord>(1, 1),
-  ]);
+  const stats = await pb.send<StaffDashboardStats>('/api/spindit/staff/dashboard', { method: 'GET' });
 
   return {
-    totalUsers: usersAll.totalItems,
-    newUsersThisWeek: usersRecent.totalItems,
-    totalRequests: requestsAll.totalItems,
-    pendingRequests: requestsPending.totalItems,
-    totalLockers: lockersAll.totalItems,
-    freeLockers: lockersFree.totalItems,
-    totalZones: zonesAll.totalItems,
+    totalUsers: stats.users.total,
+    newUsersThisWeek: stats.users.new_this_week,
+    totalRequests: stats.requests.total,
+    pendingRequests: stats.requests.statuses.pending ?? 0,
+    totalLockers: stats.occupancy.total,
+    freeLockers: stats.occupancy.statuses.free ?? 0,
+    totalZones: stats.zones,
   };
 }
 
@@ -348,7 +334,7 @@ export interface CreateUserInput {
   phone?: string;
   address?: string;
   language?: string;
-  is_staff?: boolean;
+  role?: UserRole;
 }
 
 export interface UpdateUserInput {
@@ -356,7 +342,7 @@ export interface UpdateUserInput {
   phone?: string;
   address?: string;
   language?: string;
-  is_staff?: boolean;
+  role?: UserRole;
 }
 
 export async function createUserAccount(payload: CreateUserInput): Promise<StaffUserRecord> {
@@ -368,7 +354,7 @@ export async function createUserAccount(payload: CreateUserInput): Promise<Staff
     phone: payload.phone ?? '',
     address: payload.address ?? '',
     language: payload.language ?? 'de',
-    is_staff: payload.is_staff ?? false,
+    role: payload.role ?? 'family',
     emailVisibility: true,
   });
   return record;
@@ -380,7 +366,7 @@ export async function updateUserAccount(id: string, payload: UpdateUserInput): P
     phone: payload.phone,
     address: payload.address,
     language: payload.language,
-    is_staff: payload.is_staff,
+    role: payload.role,
   });
   return record;
 }
@@ -1,5 +1,6 @@
 import {
   Button,
+  Checkbox,
   Group,
   Loader,
   Select,
@@ -27,6 +28,7 @@ export type StaffRequestFormValues = {
   preferred_zone: string;
   preferred_locker: string;
   status: string;
+  allow_duplicate: boolean;
 };
 
 type StaffRequestFormProps = {
@@ -51,6 +53,7 @@ const EMPTY_VALUES: StaffRequestFormValues = {
   preferred_zone: '',
   preferred_locker: '',
   status: 'pending',
+  allow_duplicate: false,
 };
 
 const shallowEqual = (a: StaffRequestFormValues, b: StaffRequestFormValues) => {
@@ -63,7 +66,8 @@ const shallowEqual = (a: StaffRequestFormValues, b: StaffRequestFormValues) => {
     a.school_year === b.school_year &&
     a.preferred_zone === b.preferred_zone &&
     a.preferred_locker === b.preferred_locker &&
-    a.status === b.status
+    a.status === b.status &&
+    a.allow_duplicate === b.allow_duplicate
   );
 };
 
@@ -205,6 +209,7 @@ export const StaffRequestForm = ({
       preferred_zone: values.preferred_zone,
       preferred_locker: values.preferred_locker.trim(),
       status: values.status,
+      allow_duplicate: values.allow_duplicate,
     };
     await onSubmit(normalized, assignedLockerId);
   });
@@ -234,6 +239,11 @@ export const StaffRequestForm = ({
                 <TextInput label="Class" required {...form.getInputProps('student_class')} />
                 <TextInput label="School year" required {...form.getInputProps('school_year')} />
               </Group>
+              <Checkbox
+                label="Allow duplicate request"
+                description="Accept this request even if the student already has an active request for the school year."
+                {...form.getInputProps('allow_duplicate', { type: 'checkbox' })}
+              />
             </Stack>
           </Tabs.Panel>
 
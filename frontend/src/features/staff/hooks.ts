@@ -37,8 +37,9 @@ import {
   upsertAssignment,
 } from './api';
 import type { LockerRecord, LockerRequestRecord, ZoneRecord } from '../requests/api';
+import type { UserRole } from '../auth';
 
-type RoleFilter = 'all' | 'staff' | 'family';
+type RoleFilter = 'all' | UserRole;
 
 const usersInvalidate = (queryClient: ReturnType<typeof useQueryClient>) => {
   void queryClient.invalidateQueries({ queryKey: ['staff', 'users'] });
@@ -68,7 +69,7 @@ export const useStaffUsersQuery = (params: {
     queryFn: (): Promise<PaginatedResult<StaffUserRecord>> =>
       listUsers(params.page, params.perPage, {
         search: params.search.trim() || undefined,
-        staffOnly: params.role === 'all' ? null : params.role === 'staff',
+        role: params.role === 'all' ? null : params.role,
       }),
     placeholderData: (previous) => previous,
   });
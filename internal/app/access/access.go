@@ -0,0 +1,87 @@
+package access
+
+import (
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+const usersCollection = "users"
+
+// User roles stored in the users.role select field.
+const (
+	RoleFamily  = "family"
+	RoleJanitor = "janitor"
+	RoleStaff   = "staff"
+	RoleAdmin   = "admin"
+)
+
+// Register defaults the role of newly created users to [RoleFamily].
+func Register(app core.App) {
+	app.OnRecordCreate(usersCollection).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("role") == "" {
+			e.Record.Set("role", RoleFamily)
+		}
+
+		return e.Next()
+	})
+}
+
+// Role returns the role of the given auth record.
+//
+// Superusers are reported as [RoleAdmin] and users without an explicit role as [RoleFamily].
+func Role(auth *core.Record) string {
+	if auth == nil {
+		return ""
+	}
+
+	if auth.IsSuperuser() {
+		return RoleAdmin
+	}
+
+	if auth.Collection().Name != usersCollection {
+		return ""
+	}
+
+	if role := auth.GetString("role"); role != "" {
+		return role
+	}
+
+	return RoleFamily
+}
+
+// HasRole reports whether the auth record has one of the given roles.
+func HasRole(auth *core.Record, roles ...string) bool {
+	role := Role(auth)
+	return role != "" && slices.Contains(roles, role)
+}
+
+// IsStaff reports whether the given auth record belongs to a superuser, admin or staff member.
+func IsStaff(auth *core.Record) bool {
+	return HasRole(auth, RoleStaff, RoleAdmin)
+}
+
+// RequireStaff middleware restricts a route to superusers, admins and staff members.
+func RequireStaff() *hook.Handler[*core.RequestEvent] {
+	return RequireRole(RoleStaff, RoleAdmin)
+}
+
+// RequireRole middleware restricts a route to auth records with one of the given roles.
+// Superusers are always allowed.
+func RequireRole(roles ...string) *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Id: "spinditRequireRole",
+		Func: func(e *core.RequestEvent) error {
+			if e.Auth == nil {
+				return e.UnauthorizedError("The request requires valid record authorization token.", nil)
+			}
+
+			if !e.Auth.IsSuperuser() && !HasRole(e.Auth, roles...) {
+				return e.ForbiddenError("You are not allowed to perform this request.", nil)
+			}
+
+			return e.Next()
+		},
+	}
+}
@@ -0,0 +1,371 @@
+// Package alerts evaluates alert rules on the job run history and the email
+// queue and notifies staff by email and an optional webhook when an alert
+// fires and when it resolves.
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/app/text"
+)
+
+// Collection is the name of the alert state collection.
+const Collection = "alerts"
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+
+	// RuleJobFailed fires per job while its latest finished run failed.
+	RuleJobFailed = "job_failed"
+	// RuleEmailBounces fires while too many emails of the last
+	// [BounceWindow] failed.
+	RuleEmailBounces = "email_bounces"
+)
+
+// BounceWindow is the period the email failure rate is computed over.
+const BounceWindow = time.Hour
+
+// MaxNotifyAttempts bounds the deliveries of a notification. Evaluations
+// run every 5 minutes, so a notification is retried for about an hour.
+const MaxNotifyAttempts = 12
+
+// webhookTimeout bounds a webhook delivery, so an unreachable endpoint does
+// not hold the job lease.
+const webhookTimeout = 10 * time.Second
+
+// Condition is a currently violated rule.
+type Condition struct {
+	Rule string
+	// Key identifies the alert, e.g. the rule and the failing job.
+	Key     string
+	Message string
+}
+
+// Notification is sent to the webhook when an alert fires or resolves.
+type Notification struct {
+	Status     string         `json:"status"`
+	Rule       string         `json:"rule"`
+	Key        string         `json:"key"`
+	Message    string         `json:"message"`
+	FiredAt    types.DateTime `json:"fired_at"`
+	ResolvedAt types.DateTime `json:"resolved_at"`
+}
+
+// Report summarizes an evaluation.
+type Report struct {
+	Fired    int
+	Resolved int
+	// Notified counts the delivered notifications, including retries.
+	Notified int
+	// NotifyFailed counts the notifications that could not be delivered.
+	NotifyFailed int
+}
+
+// Evaluate checks all rules at now. Conditions without a firing alert fire
+// a new one, firing alerts whose condition cleared are resolved. Alerts that
+// are still firing are not notified again.
+//
+// Alerts are saved before staff are notified, so each alert keeps the last
+// status it was emailed and posted with. Undelivered notifications are
+// retried per channel by the next evaluations up to [MaxNotifyAttempts]
+// times. A dry run of the job
+// evaluates in a transaction that is rolled back and notifies nothing.
+func Evaluate(app core.App, now time.Time, dryRun bool) (Report, error) {
+	report := Report{}
+
+	s, err := settings.Load(app)
+	if err != nil {
+		return report, err
+	}
+
+	conditions, err := Check(app, s, now)
+	if err != nil {
+		return report, err
+	}
+
+	firing, err := query.FindAll(app, Collection, query.Eq("status", StatusFiring), "fired_at", 0, 0)
+	if err != nil {
+		return report, err
+	}
+	open := map[string]*core.Record{}
+	for _, alert := range firing {
+		open[alert.GetString("key")] = alert
+	}
+
+	collection, err := app.FindCollectionByNameOrId(Collection)
+	if err != nil {
+		return report, err
+	}
+
+	active := map[string]bool{}
+	for _, condition := range conditions {
+		active[condition.Key] = true
+		if open[condition.Key] != nil {
+			continue
+		}
+
+		alert := core.NewRecord(collection)
+		alert.Set("rule", condition.Rule)
+		alert.Set("key", condition.Key)
+		alert.Set("status", StatusFiring)
+		alert.Set("message", text.Truncate(condition.Message, 1000))
+		alert.Set("fired_at", now)
+		if err := app.Save(alert); err != nil {
+			return report, fmt.Errorf("failed to fire alert %s: %w", condition.Key, err)
+		}
+
+		report.Fired++
+	}
+
+	for key, alert := range open {
+		if active[key] {
+			continue
+		}
+
+		alert.Set("status", StatusResolved)
+		alert.Set("resolved_at", now)
+		alert.Set("notify_attempts", 0)
+		if err := app.Save(alert); err != nil {
+			return report, fmt.Errorf("failed to resolve alert %s: %w", key, err)
+		}
+
+		report.Resolved++
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	pending, err := app.FindAllRecords(Collection, dbx.NewExp(
+		"([[notified_email_status]] != [[status]] OR [[notified_webhook_status]] != [[status]]) AND [[notify_attempts]] < {:max}",
+		dbx.Params{"max": MaxNotifyAttempts},
+	))
+	if err != nil {
+		return report, err
+	}
+
+	for _, alert := range pending {
+		if notifyErr := Notify(app, s, alert); notifyErr != nil {
+			report.NotifyFailed++
+			app.Logger().Error("failed to deliver the alert notification", "alert", alert.GetString("key"), "status", alert.GetString("status"), "error", notifyErr)
+			alert.Set("notify_attempts", alert.GetInt("notify_attempts")+1)
+			alert.Set("notify_error", text.Truncate(notifyErr.Error(), 2000))
+		} else {
+			report.Notified++
+			alert.Set("notify_attempts", 0)
+			alert.Set("notify_error", "")
+		}
+
+		if err := app.Save(alert); err != nil {
+			return report, fmt.Errorf("failed to record the notification of alert %s: %w", alert.GetString("key"), err)
+		}
+	}
+
+	return report, nil
+}
+
+func notification(alert *core.Record) Notification {
+	return Notification{
+		Status:     alert.GetString("status"),
+		Rule:       alert.GetString("rule"),
+		Key:        alert.GetString("key"),
+		Message:    alert.GetString("message"),
+		FiredAt:    alert.GetDateTime("fired_at"),
+		ResolvedAt: alert.GetDateTime("resolved_at"),
+	}
+}
+
+// Check returns the rules violated at now.
+func Check(app core.App, s settings.Settings, now time.Time) ([]Condition, error) {
+	conditions, err := failedJobs(app)
+	if err != nil {
+		return nil, err
+	}
+
+	bounces, ok, err := emailBounces(app, s, now)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		conditions = append(conditions, bounces)
+	}
+
+	return conditions, nil
+}
+
+// failedJobs reports the jobs whose latest finished run failed. Dry runs
+// and skipped runs are ignored.
+func failedJobs(app core.App) ([]Condition, error) {
+	rows := []struct {
+		Job   string `db:"job"`
+		Error string `db:"error"`
+	}{}
+	err := app.DB().NewQuery(`
+		SELECT r.job AS job, r.error AS error
+		FROM job_runs r
+		WHERE r.status = 'failed' AND r.dry_run = FALSE AND r.started_at = (
+			SELECT MAX(l.started_at)
+			FROM job_runs l
+			WHERE l.job = r.job AND l.dry_run = FALSE AND l.status IN ('success', 'failed')
+		)
+		ORDER BY r.job
+	`).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make([]Condition, 0, len(rows))
+	for _, row := range rows {
+		conditions = append(conditions, Condition{
+			Rule:    RuleJobFailed,
+			Key:     RuleJobFailed + ":" + row.Job,
+			Message: fmt.Sprintf("Job %s failed: %s", row.Job, row.Error),
+		})
+	}
+
+	return conditions, nil
+}
+
+// emailBounces compares the failed and sent emails queued within the
+// [BounceWindow] before now.
+func emailBounces(app core.App, s settings.Settings, now time.Time) (Condition, bool, error) {
+	if s.AlertBounceRatePercent <= 0 {
+		return Condition{}, false, nil
+	}
+
+	var failed, sent int
+	err := app.DB().NewQuery(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'sent' THEN 1 ELSE 0 END), 0)
+		FROM email_queue
+		WHERE created >= {:since}
+	`).Bind(dbx.Params{
+		"since": now.Add(-BounceWindow).UTC().Format(types.DefaultDateLayout),
+	}).Row(&failed, &sent)
+	if err != nil {
+		return Condition{}, false, err
+	}
+
+	total := failed + sent
+	if failed == 0 || failed < s.AlertBounceMinFailures || failed*100 < s.AlertBounceRatePercent*total {
+		return Condition{}, false, nil
+	}
+
+	return Condition{
+		Rule: RuleEmailBounces,
+		Key:  RuleEmailBounces,
+		Message: fmt.Sprintf("%d of %d emails of the last hour failed (%d%%, threshold %d%%)",
+			failed, total, failed*100/total, s.AlertBounceRatePercent),
+	}, true, nil
+}
+
+// Notify emails the current status of alert to all staff and admins and
+// posts it to the webhook of s, each unless that channel was notified of
+// the status already. The delivered channels are recorded on alert, which
+// the caller saves, so a failed channel is retried alone.
+func Notify(app core.App, s settings.Settings, alert *core.Record) error {
+	var errs []error
+
+	n := notification(alert)
+
+	if alert.GetString("notified_email_status") != n.Status {
+		if err := sendEmail(app, n); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		} else {
+			alert.Set("notified_email_status", n.Status)
+		}
+	}
+
+	if alert.GetString("notified_webhook_status") != n.Status {
+		if s.AlertWebhookURL == "" {
+			// nothing to deliver
+			alert.Set("notified_webhook_status", n.Status)
+		} else if err := postWebhook(s.AlertWebhookURL, n); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		} else {
+			alert.Set("notified_webhook_status", n.Status)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func sendEmail(app core.App, n Notification) error {
+	staff, err := query.FindAll(app, "users", query.Or(
+		query.Eq("role", access.RoleStaff),
+		query.Eq("role", access.RoleAdmin),
+	), "email", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	to := []mail.Address{}
+	for _, user := range staff {
+		if email := user.Email(); email != "" {
+			to = append(to, mail.Address{Name: user.GetString("full_name"), Address: email})
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("[Spindit] %s: %s", strings.ToUpper(n.Status), n.Key)
+	paragraphs := []string{n.Message, "Fired at " + n.FiredAt.String()}
+	if n.Status == StatusResolved {
+		paragraphs = append(paragraphs, "Resolved at "+n.ResolvedAt.String())
+	}
+
+	var htmlBody strings.Builder
+	for _, p := range paragraphs {
+		htmlBody.WriteString("<p>" + html.EscapeString(p) + "</p>")
+	}
+
+	meta := app.Settings().Meta
+
+	return app.NewMailClient().Send(&mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      to,
+		Subject: subject,
+		HTML:    htmlBody.String(),
+		Text:    strings.Join(paragraphs, "\n\n") + "\n",
+	})
+}
+
+func postWebhook(url string, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package alerts_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/alerts"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestAlerts(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	fixed := testutil.FreezeClock(app, testutil.Start)
+
+	testutil.CreateUser(t, app, map[string]any{"role": "staff"})
+
+	received := make(chan alerts.Notification, 10)
+	var unavailable atomic.Bool
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var n alerts.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- n
+	}))
+	defer webhook.Close()
+
+	record, err := settings.FindRecord(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Set("alert_webhook_url", webhook.URL)
+	record.Set("alert_bounce_rate_percent", 50)
+	record.Set("alert_bounce_min_failures", 3)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := app.FindCollectionByNameOrId(cronjobs.RunsCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRun := func(status string, at time.Time) {
+		t.Helper()
+		run := core.NewRecord(runs)
+		run.Set("job", "invoices.reminders")
+		run.Set("trigger", cronjobs.TriggerSchedule)
+		run.Set("status", status)
+		run.Set("started_at", at)
+		run.Set("finished_at", at)
+		run.Set("error", "smtp unreachable")
+		if err := app.Save(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	emails, err := app.FindCollectionByNameOrId("email_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addEmail := func(status string) {
+		t.Helper()
+		email := core.NewRecord(emails)
+		email.Set("recipient", "family@example.com")
+		email.Set("subject", "Invoice")
+		email.Set("template", "invoice")
+		email.Set("status", status)
+		if err := app.Save(email); err != nil {
+			t.Fatal(err)
+		}
+		created := testutil.Start.Add(-10 * time.Minute).Format(types.DefaultDateLayout)
+		if _, err := app.DB().Update("email_queue", dbx.Params{"created": created}, dbx.HashExp{"id": email.Id}).Execute(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a simulated cron failure and a bounce spike of 3 failed out of 4
+	addRun(cronjobs.StatusFailed, testutil.Start.Add(-time.Hour))
+	addEmail("sent")
+	for i := 0; i < 3; i++ {
+		addEmail("failed")
+	}
+
+	evaluate := func() alerts.Report {
+		t.Helper()
+		report, err := alerts.Evaluate(app, clock.Now(app), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.NotifyFailed != 0 && !unavailable.Load() {
+			t.Fatalf("expected all notifications to be delivered, got %+v", report)
+		}
+		return report
+	}
+	notified := func(want map[string]string) {
+		t.Helper()
+		got := map[string]string{}
+		for range want {
+			select {
+			case n := <-received:
+				got[n.Key] = n.Status
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected webhook notifications %v, got %v", want, got)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected webhook notifications %v, got %v", want, got)
+		}
+	}
+
+	if report := evaluate(); report.Fired != 2 {
+		t.Fatalf("expected 2 alerts to fire, got %+v", report)
+	}
+	notified(map[string]string{
+		"email_bounces":                 alerts.StatusFiring,
+		"job_failed:invoices.reminders": alerts.StatusFiring,
+	})
+	if total := app.TestMailer.TotalSend(); total != 2 {
+		t.Fatalf("expected 2 staff emails, got %d", total)
+	}
+
+	// still firing alerts are not notified again
+	if report := evaluate(); report.Fired != 0 || report.Resolved != 0 {
+		t.Fatalf("expected the alerts to be deduplicated, got %+v", report)
+	}
+	if len(received) != 0 || app.TestMailer.TotalSend() != 2 {
+		t.Fatal("expected no repeated notifications")
+	}
+
+	// the next successful run resolves the job alert, its notification is
+	// retried while the webhook is unavailable
+	fixed.Set(testutil.Start.Add(10 * time.Minute))
+	addRun(cronjobs.StatusSuccess, testutil.Start)
+
+	unavailable.Store(true)
+	if report := evaluate(); report.Resolved != 1 || report.NotifyFailed != 1 {
+		t.Fatalf("expected the job alert to resolve with a failed notification, got %+v", report)
+	}
+	pending, err := app.FindFirstRecordByData(alerts.Collection, "key", "job_failed:invoices.reminders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.GetInt("notify_attempts") != 1 || pending.GetString("notify_error") == "" {
+		t.Fatalf("expected a failed notification attempt, got %v", pending.PublicExport())
+	}
+	testutil.AssertString(t, "emailed status", pending.GetString("notified_email_status"), alerts.StatusResolved)
+	testutil.AssertString(t, "posted status", pending.GetString("notified_webhook_status"), alerts.StatusFiring)
+	if total := app.TestMailer.TotalSend(); total != 3 {
+		t.Fatalf("expected the resolved email to be sent, got %d emails", total)
+	}
+
+	unavailable.Store(false)
+	fixed.Set(testutil.Start.Add(15 * time.Minute))
+	if report := evaluate(); report.Resolved != 0 || report.Notified != 1 {
+		t.Fatalf("expected the resolved notification to be retried, got %+v", report)
+	}
+	notified(map[string]string{"job_failed:invoices.reminders": alerts.StatusResolved})
+	if report := evaluate(); report.Notified != 0 {
+		t.Fatalf("expected no further notifications, got %+v", report)
+	}
+
+	resolved, err := app.FindFirstRecordByData(alerts.Collection, "key", "job_failed:invoices.reminders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "alert status", resolved.GetString("status"), alerts.StatusResolved)
+	testutil.AssertString(t, "posted status", resolved.GetString("notified_webhook_status"), alerts.StatusResolved)
+	// only the failed webhook was retried
+	if total := app.TestMailer.TotalSend(); total != 3 {
+		t.Fatalf("expected staff not to be emailed again, got %d emails", total)
+	}
+	if got := resolved.GetDateTime("resolved_at").Time(); !got.Equal(testutil.Start.Add(10 * time.Minute)) {
+		t.Fatalf("expected resolved_at %s, got %s", testutil.Start.Add(10*time.Minute), got)
+	}
+}
@@ -0,0 +1,60 @@
+// Package app wires the Spindit hooks and routes into a PocketBase app.
+package app
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/hooks/autoreserve"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/routes/dashboard"
+	"github.com/jryannel/spindit/internal/app/routes/devclock"
+	"github.com/jryannel/spindit/internal/app/routes/erasure"
+	"github.com/jryannel/spindit/internal/app/routes/export"
+	"github.com/jryannel/spindit/internal/app/routes/girocode"
+	"github.com/jryannel/spindit/internal/app/routes/health"
+	"github.com/jryannel/spindit/internal/app/routes/jobs"
+	"github.com/jryannel/spindit/internal/app/routes/layout"
+	"github.com/jryannel/spindit/internal/app/routes/legacy"
+	"github.com/jryannel/spindit/internal/app/routes/metrics"
+	"github.com/jryannel/spindit/internal/app/routes/payments"
+	"github.com/jryannel/spindit/internal/app/routes/reports"
+	"github.com/jryannel/spindit/internal/app/routes/statements"
+	"github.com/jryannel/spindit/internal/app/routes/webhooks"
+	"github.com/jryannel/spindit/internal/pbext/pdf"
+)
+
+// Register binds the Spindit hooks and routes to app.
+//
+// The server and the test harness share it, so tests run against the same
+// hooks and routes. The cron schedule and the operator commands are only
+// registered by the server.
+func Register(app core.App) error {
+	if err := pdf.Register(app); err != nil {
+		return err
+	}
+
+	access.Register(app)
+	lockers.Register(app)
+	requests.Register(app)
+	autoreserve.Register(app)
+	invoices.Register(app)
+	dashboard.Register(app)
+	devclock.Register(app)
+	erasure.Register(app)
+	export.Register(app)
+	girocode.Register(app)
+	health.Register(app)
+	jobs.Register(app)
+	layout.Register(app)
+	legacy.Register(app)
+	metrics.Register(app)
+	payments.Register(app)
+	reports.Register(app)
+	statements.Register(app)
+	webhooks.Register(app)
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package assignments manages the final locker assignments of paid requests,
+// their release and the school year rollover.
+package assignments
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/requests"
+)
+
+// Collection is the name of the assignments collection.
+const Collection = "assignments"
+
+// ErrNotAssigned is returned when releasing an assignment whose request is
+// not assigned yet, e.g. a reservation awaiting payment.
+var ErrNotAssigned = errors.New("the request is not assigned, cancel the request instead")
+
+// Resolve finds an assignment by its record id or by the id or label of the
+// assigned locker.
+func Resolve(app core.App, value string) (*core.Record, error) {
+	if assignment, err := app.FindRecordById(Collection, value); err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return assignment, err
+	}
+
+	locker, err := lockers.ResolveLocker(app, value, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return query.FindFirst(app, Collection, query.Eq("locker", locker.Id))
+}
+
+// Release ends an assignment before or at the end of its school year: the
+// locker is freed, the assignment (with its renewals) deleted and the
+// request marked as expired.
+func Release(app core.App, assignment *core.Record) error {
+	return app.RunInTransaction(func(txApp core.App) error {
+		request, err := txApp.FindRecordById(requests.Collection, assignment.GetString("request"))
+		if err != nil {
+			return err
+		}
+		if request.GetString("status") != requests.StatusAssigned {
+			return fmt.Errorf("%w (request %s is %s)", ErrNotAssigned, request.Id, request.GetString("status"))
+		}
+
+		locker, err := txApp.FindRecordById("lockers", assignment.GetString("locker"))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if err := txApp.Delete(assignment); err != nil {
+			return err
+		}
+
+		if locker != nil && locker.GetString("status") != lockers.StatusMaintenance {
+			if err := lockers.Free(txApp, locker); err != nil {
+				return err
+			}
+		}
+
+		request.Set("status", requests.StatusExpired)
+
+		return txApp.Save(request)
+	})
+}
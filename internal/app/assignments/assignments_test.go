@@ -0,0 +1,122 @@
+package assignments_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/assignments"
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestAssignmentRelease(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	reserved, reservedLocker := testutil.ReservedRequest(t, app)
+	assigned, assignedLocker := testutil.AssignedRequest(t, app)
+
+	if err := lockers.SetMaintenance(app, assignedLocker, "broken"); !errors.Is(err, lockers.ErrInUse) {
+		t.Fatalf("expected ErrInUse for an assigned locker, got %v", err)
+	}
+
+	pending, err := assignments.Resolve(app, reservedLocker.GetString("label"))
+	if err != nil {
+		t.Fatalf("failed to resolve the assignment by locker label: %v", err)
+	}
+	if err := assignments.Release(app, pending); !errors.Is(err, assignments.ErrNotAssigned) {
+		t.Fatalf("expected ErrNotAssigned for a reservation, got %v", err)
+	}
+	testutil.AssertString(t, "reserved request status", testutil.Reload(t, app, reserved).GetString("status"), "reserved")
+
+	assignment, err := assignments.Resolve(app, assignedLocker.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assignments.Release(app, assignment); err != nil {
+		t.Fatalf("failed to release the assignment: %v", err)
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, assigned).GetString("status"), "expired")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, assignedLocker).GetString("status"), "free")
+	testutil.AssertCount(t, app, assignments.Collection, dbx.HashExp{"request": assigned.Id}, 0)
+
+	if err := lockers.SetMaintenance(app, testutil.Reload(t, app, assignedLocker), "broken"); err != nil {
+		t.Fatalf("failed to take the released locker out of service: %v", err)
+	}
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, assignedLocker).GetString("status"), "maintenance")
+}
+
+func TestYearRollover(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	now := testutil.FreezeClock(app, testutil.Start)
+
+	renewed, renewedLocker := testutil.AssignedRequest(t, app)
+	released, releasedLocker := testutil.AssignedRequest(t, app)
+	open, openLocker := testutil.ReservedRequest(t, app)
+
+	assignment, err := app.FindFirstRecordByData(assignments.Collection, "request", renewed.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewalsCollection, err := app.FindCollectionByNameOrId("renewals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewal := core.NewRecord(renewalsCollection)
+	renewal.Load(map[string]any{"assignment": assignment.Id, "school_year": "2025/26", "status": "confirmed"})
+	if err := app.Save(renewal); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := assignments.NextSchoolYear("2024/25")
+	if err != nil || next != "2025/26" {
+		t.Fatalf("expected 2025/26 after 2024/25, got %q (%v)", next, err)
+	}
+
+	plan, err := assignments.PlanRollover(app, "2024/25", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Renewed) != 1 || len(plan.Released) != 1 || len(plan.Cancelled) != 1 || len(plan.Conflicts) != 0 || plan.Applied {
+		t.Fatalf("unexpected rollover plan %+v", plan)
+	}
+	testutil.AssertString(t, "planned request status", testutil.Reload(t, app, released).GetString("status"), "assigned")
+
+	now.Set(time.Date(2025, time.August, 1, 8, 0, 0, 0, time.UTC))
+	if _, err := assignments.ApplyRollover(app, "2024/25", next); err != nil {
+		t.Fatalf("rollover failed: %v", err)
+	}
+
+	// renewed: the assignment moved to a new assigned request of the next year
+	testutil.AssertString(t, "renewed request status", testutil.Reload(t, app, renewed).GetString("status"), "expired")
+	assignment = testutil.Reload(t, app, assignment)
+	successor, err := app.FindRecordById("requests", assignment.GetString("request"))
+	if err != nil {
+		t.Fatalf("expected the assignment to reference the new request: %v", err)
+	}
+	testutil.AssertString(t, "successor school year", successor.GetString("school_year"), "2025/26")
+	testutil.AssertString(t, "successor status", successor.GetString("status"), "assigned")
+	testutil.AssertString(t, "renewed locker status", testutil.Reload(t, app, renewedLocker).GetString("status"), "occupied")
+	testutil.AssertCount(t, app, "renewals", dbx.HashExp{"id": renewal.Id}, 1)
+	testutil.AssertCount(t, app, assignments.Collection, dbx.HashExp{"request": successor.Id}, 1)
+
+	// released: no renewal
+	testutil.AssertString(t, "released request status", testutil.Reload(t, app, released).GetString("status"), "expired")
+	testutil.AssertString(t, "released locker status", testutil.Reload(t, app, releasedLocker).GetString("status"), "free")
+
+	// cancelled: still open from the closed year
+	testutil.AssertString(t, "open request status", testutil.Reload(t, app, open).GetString("status"), "cancelled")
+	testutil.AssertString(t, "open locker status", testutil.Reload(t, app, openLocker).GetString("status"), "free")
+
+	plan, err = assignments.PlanRollover(app, "2024/25", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Renewed)+len(plan.Released)+len(plan.Cancelled) != 0 {
+		t.Fatalf("expected nothing left to roll over, got %+v", plan)
+	}
+}
@@ -0,0 +1,260 @@
+package assignments
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/requests"
+)
+
+const renewalsCollection = "renewals"
+
+var schoolYearPattern = regexp.MustCompile(`^([0-9]{4})/([0-9]{2})$`)
+
+// RolloverItem is a request affected by the school year rollover.
+type RolloverItem struct {
+	Request  string `json:"request"`
+	Student  string `json:"student"`
+	Locker   string `json:"locker,omitempty"`
+	Renewal  string `json:"renewal,omitempty"`
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// Rollover lists the changes of closing school year From and moving the
+// renewed assignments to school year To.
+type Rollover struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Renewed assignments move to a new request for To, keeping their locker.
+	Renewed []RolloverItem `json:"renewed"`
+	// Released assignments without a confirmed renewal free their locker.
+	Released []RolloverItem `json:"released"`
+	// Cancelled are the still pending or reserved requests of From.
+	Cancelled []RolloverItem `json:"cancelled"`
+	// Conflicts are renewals whose family already has an active request
+	// for the student in To. They are left for staff to resolve.
+	Conflicts []RolloverItem `json:"conflicts"`
+
+	Applied bool `json:"applied"`
+}
+
+type rolloverEntry struct {
+	item       RolloverItem
+	request    *core.Record
+	assignment *core.Record
+}
+
+// NextSchoolYear returns the school year following year, e.g. "2025/26" for "2024/25".
+func NextSchoolYear(year string) (string, error) {
+	m := schoolYearPattern.FindStringSubmatch(year)
+	if m == nil {
+		return "", fmt.Errorf("invalid school year %q, expected YYYY/YY", year)
+	}
+
+	start, _ := strconv.Atoi(m[1])
+
+	return fmt.Sprintf("%d/%02d", start+1, (start+2)%100), nil
+}
+
+// PlanRollover reports the changes [ApplyRollover] would make without writing them.
+func PlanRollover(app core.App, from string, to string) (*Rollover, error) {
+	r, _, err := plan(app, from, to)
+
+	return r, err
+}
+
+// ApplyRollover closes school year from in one transaction, see [Rollover].
+func ApplyRollover(app core.App, from string, to string) (*Rollover, error) {
+	var result *Rollover
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		r, entries, err := plan(txApp, from, to)
+		if err != nil {
+			return err
+		}
+
+		now := clock.Now(txApp)
+
+		for _, entry := range entries.renewed {
+			if err := carryOver(txApp, entry, to, now); err != nil {
+				return fmt.Errorf("failed to renew request %s: %w", entry.request.Id, err)
+			}
+		}
+
+		for _, entry := range entries.released {
+			if err := Release(txApp, entry.assignment); err != nil {
+				return fmt.Errorf("failed to release request %s: %w", entry.request.Id, err)
+			}
+		}
+
+		for _, entry := range entries.cancelled {
+			if err := requests.Cancel(txApp, entry.request); err != nil {
+				return fmt.Errorf("failed to cancel request %s: %w", entry.request.Id, err)
+			}
+		}
+
+		r.Applied = true
+		result = r
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type rolloverEntries struct {
+	renewed   []rolloverEntry
+	released  []rolloverEntry
+	cancelled []rolloverEntry
+}
+
+func plan(app core.App, from string, to string) (*Rollover, *rolloverEntries, error) {
+	if !schoolYearPattern.MatchString(from) {
+		return nil, nil, fmt.Errorf("invalid school year %q, expected YYYY/YY", from)
+	}
+	if !schoolYearPattern.MatchString(to) || to <= from {
+		return nil, nil, fmt.Errorf("invalid target school year %q, expected a YYYY/YY after %s", to, from)
+	}
+
+	r := &Rollover{
+		From:      from,
+		To:        to,
+		Renewed:   []RolloverItem{},
+		Released:  []RolloverItem{},
+		Cancelled: []RolloverItem{},
+		Conflicts: []RolloverItem{},
+	}
+	entries := &rolloverEntries{}
+
+	assigned, err := query.FindAll(app, requests.Collection, query.And(
+		query.Eq("school_year", from),
+		query.Eq("status", requests.StatusAssigned),
+	), "submitted_at", 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, request := range assigned {
+		entry := rolloverEntry{
+			request: request,
+			item:    RolloverItem{Request: request.Id, Student: request.GetString("student_name")},
+		}
+
+		entry.assignment, err = query.FindFirst(app, Collection, query.Eq("request", request.Id))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if locker, err := app.FindRecordById("lockers", entry.assignment.GetString("locker")); err == nil {
+			entry.item.Locker = locker.GetString("label")
+		}
+
+		renewal, err := query.FindFirst(app, renewalsCollection, query.And(
+			query.Eq("assignment", entry.assignment.Id),
+			query.Eq("school_year", to),
+			query.Eq("status", "confirmed"),
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			r.Released = append(r.Released, entry.item)
+			entries.released = append(entries.released, entry)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		entry.item.Renewal = renewal.Id
+
+		next := request.Clone()
+		next.Set("school_year", to)
+		duplicate, err := requests.FindActiveDuplicate(app, next)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+		if duplicate != nil {
+			entry.item.Conflict = duplicate.Id
+			r.Conflicts = append(r.Conflicts, entry.item)
+			continue
+		}
+
+		r.Renewed = append(r.Renewed, entry.item)
+		entries.renewed = append(entries.renewed, entry)
+	}
+
+	open, err := query.FindAll(app, requests.Collection, query.And(
+		query.Eq("school_year", from),
+		query.Or(
+			query.Eq("status", requests.StatusPending),
+			query.Eq("status", requests.StatusReserved),
+		),
+	), "submitted_at", 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, request := range open {
+		entry := rolloverEntry{
+			request: request,
+			item:    RolloverItem{Request: request.Id, Student: request.GetString("student_name")},
+		}
+		r.Cancelled = append(r.Cancelled, entry.item)
+		entries.cancelled = append(entries.cancelled, entry)
+	}
+
+	return r, entries, nil
+}
+
+// carryOver creates the request of the renewed school year and moves the
+// assignment to it, so the locker stays occupied. The previous request is
+// marked as expired.
+func carryOver(app core.App, entry rolloverEntry, to string, now time.Time) error {
+	collection, err := app.FindCollectionByNameOrId(requests.Collection)
+	if err != nil {
+		return err
+	}
+
+	previous := entry.request
+
+	next := core.NewRecord(collection)
+	for _, field := range []string{
+		"user",
+		"requester_name",
+		"requester_address",
+		"requester_phone",
+		"student_name",
+		"student_class",
+		"preferred_zone",
+	} {
+		next.Set(field, previous.Get(field))
+	}
+	next.Set("preferred_locker", entry.item.Locker)
+	next.Set("school_year", to)
+	next.Set("status", requests.StatusAssigned)
+	next.Set("submitted_at", now)
+	if err := app.Save(next); err != nil {
+		return err
+	}
+
+	entry.assignment.Set("request", next.Id)
+	if err := app.Save(entry.assignment); err != nil {
+		return err
+	}
+
+	previous.Set("status", requests.StatusExpired)
+
+	return app.Save(previous)
+}
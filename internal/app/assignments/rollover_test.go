@@ -0,0 +1,33 @@
+package assignments_test
+
+import (
+	"testing"
+
+	"github.com/jryannel/spindit/internal/app/assignments"
+)
+
+func TestNextSchoolYear(t *testing.T) {
+	for _, tc := range []struct {
+		year    string
+		want    string
+		wantErr bool
+	}{
+		{year: "2024/25", want: "2025/26"},
+		{year: "2098/99", want: "2099/00"},
+		{year: "2099/00", want: "2100/01"},
+		{year: "2024-25", wantErr: true},
+		{year: "24/25", wantErr: true},
+		{year: "", wantErr: true},
+	} {
+		got, err := assignments.NextSchoolYear(tc.year)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("NextSchoolYear(%q): expected an error, got %q", tc.year, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("NextSchoolYear(%q) = %q, %v; want %q", tc.year, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package backup creates scheduled snapshots of pb_data (database and
+// uploaded files such as invoice PDFs), copies them to a local target
+// directory, optionally encrypted, prunes them by a daily, weekly and monthly
+// retention and verifies them by restoring them into a temporary directory.
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+const (
+	// DirEnv names the environment variable with the local target directory
+	// backups are copied to, e.g. a mounted volume. Unset keeps the backups
+	// in pb_data/backups (or the S3 backups storage) only.
+	DirEnv = "SPINDIT_BACKUP_DIR"
+	// KeyEnv names the environment variable with the passphrase that
+	// encrypts the copies in the target directory. It is not stored in
+	// app_settings because the database is part of every backup.
+	KeyEnv = "SPINDIT_BACKUP_KEY"
+
+	namePrefix      = "spindit_"
+	nameTimeLayout  = "20060102_150405"
+	archiveExt      = ".zip"
+	encryptedExt    = ".zip.enc"
+	filePermissions = 0o600
+)
+
+// Config is the backup configuration read from the environment.
+type Config struct {
+	Dir string
+	Key string
+}
+
+// ConfigFromEnv reads [DirEnv] and [KeyEnv].
+func ConfigFromEnv() Config {
+	return Config{Dir: os.Getenv(DirEnv), Key: os.Getenv(KeyEnv)}
+}
+
+// Result summarizes a backup run.
+type Result struct {
+	// Name is the created backup, empty in a dry run.
+	Name string `json:"name,omitempty"`
+	// Copy is the path of the copy in the target directory.
+	Copy string `json:"copy,omitempty"`
+	// Pruned lists the backups removed by the retention.
+	Pruned []string `json:"pruned"`
+}
+
+// Retention is the number of daily, weekly and monthly backups to keep.
+// The newest backup of each day, ISO week and month counts.
+type Retention struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// RetentionFromSettings returns the retention of s.
+func RetentionFromSettings(s settings.Settings) Retention {
+	return Retention{Daily: s.BackupKeepDaily, Weekly: s.BackupKeepWeekly, Monthly: s.BackupKeepMonthly}
+}
+
+// Name returns the backup name created at now.
+func Name(now time.Time) string {
+	return namePrefix + now.UTC().Format(nameTimeLayout) + archiveExt
+}
+
+// Run creates a backup at now through the PocketBase backup API, copies it
+// to the target directory of cfg (encrypted when cfg.Key is set) and prunes
+// both locations by the retention in app_settings.
+//
+// With dryRun only the pruning is reported.
+func Run(app core.App, cfg Config, now time.Time, dryRun bool) (Result, error) {
+	result := Result{Pruned: []string{}}
+
+	s, err := settings.Load(app)
+	if err != nil {
+		return result, err
+	}
+	retention := RetentionFromSettings(s)
+
+	if !dryRun {
+		result.Name = Name(now)
+		if err := app.CreateBackup(context.Background(), result.Name); err != nil {
+			return result, fmt.Errorf("failed to create backup %s: %w", result.Name, err)
+		}
+
+		if cfg.Dir != "" {
+			result.Copy, err = copyToDir(app, cfg, result.Name)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	pruned, err := pruneBackups(app, retention, dryRun)
+	result.Pruned = append(result.Pruned, pruned...)
+	if err != nil {
+		return result, err
+	}
+
+	if cfg.Dir != "" {
+		pruned, err := pruneDir(cfg.Dir, retention, dryRun)
+		result.Pruned = append(result.Pruned, pruned...)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// Keep returns the names to keep by retention. Names that are no Spindit
+// backups are always kept; an all zero retention keeps everything.
+func Keep(names []string, retention Retention) map[string]bool {
+	keep := map[string]bool{}
+
+	type backup struct {
+		name string
+		time time.Time
+	}
+	backups := []backup{}
+	for _, name := range names {
+		t, ok := parseName(name)
+		if !ok || retention == (Retention{}) {
+			keep[name] = true
+			continue
+		}
+		backups = append(backups, backup{name, t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+	if len(backups) > 0 {
+		keep[backups[0].name] = true
+	}
+
+	for _, tier := range []struct {
+		count  int
+		bucket func(time.Time) string
+	}{
+		{retention.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{retention.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{retention.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	} {
+		seen := map[string]bool{}
+		for _, b := range backups {
+			if len(seen) >= tier.count {
+				break
+			}
+			bucket := tier.bucket(b.time)
+			if !seen[bucket] {
+				seen[bucket] = true
+				keep[b.name] = true
+			}
+		}
+	}
+
+	return keep
+}
+
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, namePrefix) {
+		return time.Time{}, false
+	}
+
+	stamp := strings.TrimPrefix(name, namePrefix)
+	switch {
+	case strings.HasSuffix(stamp, encryptedExt):
+		stamp = strings.TrimSuffix(stamp, encryptedExt)
+	case strings.HasSuffix(stamp, archiveExt):
+		stamp = strings.TrimSuffix(stamp, archiveExt)
+	default:
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(nameTimeLayout, stamp)
+
+	return t, err == nil
+}
+
+func copyToDir(app core.App, cfg Config, name string) (string, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return "", err
+	}
+
+	fsys, err := app.NewBackupsFilesystem()
+	if err != nil {
+		return "", err
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	target := filepath.Join(cfg.Dir, name)
+	if cfg.Key != "" {
+		target = filepath.Join(cfg.Dir, strings.TrimSuffix(name, archiveExt)+encryptedExt)
+	}
+
+	// write to a temporary file first, so an interrupted copy never looks
+	// like a complete backup
+	tmp, err := os.OpenFile(target+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, filePermissions)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.Key != "" {
+		err = Encrypt(tmp, r, cfg.Key)
+	} else {
+		_, err = io.Copy(tmp, r)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to copy backup %s to %s: %w", name, cfg.Dir, err)
+	}
+
+	return target, os.Rename(tmp.Name(), target)
+}
+
+func pruneBackups(app core.App, retention Retention, dryRun bool) ([]string, error) {
+	fsys, err := app.NewBackupsFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	objects, err := fsys.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		names = append(names, object.Key)
+	}
+
+	pruned := []string{}
+	keep := Keep(names, retention)
+	for _, name := range names {
+		if keep[name] {
+			continue
+		}
+		if !dryRun {
+			if err := fsys.Delete(name); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, name)
+	}
+
+	return pruned, nil
+}
+
+func pruneDir(dir string, retention Retention, dryRun bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	pruned := []string{}
+	keep := Keep(names, retention)
+	for _, name := range names {
+		if keep[name] {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, filepath.Join(dir, name))
+	}
+
+	return pruned, nil
+}
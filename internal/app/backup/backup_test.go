@@ -0,0 +1,135 @@
+package backup_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	"github.com/jryannel/spindit/internal/app/backup"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestBackupCreateVerifyAndPrune(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	request, _ := testutil.ReservedRequest(t, app)
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+	pdf, err := filesystem.NewFileFromBytes([]byte("%PDF-1.4\n%test invoice\n"), "invoice.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoice.Set("pdf", pdf)
+	if err := app.Save(invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := settings.FindRecord(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Set("backup_keep_daily", 1)
+	record.Set("backup_keep_weekly", 0)
+	record.Set("backup_keep_monthly", 0)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := backup.Config{Dir: t.TempDir(), Key: "correct horse battery staple"}
+
+	first, err := backup.Run(app, cfg, testutil.Start, false)
+	if err != nil {
+		t.Fatalf("failed to create the backup: %v", err)
+	}
+	if !strings.HasSuffix(first.Copy, ".zip.enc") {
+		t.Fatalf("expected an encrypted copy, got %q", first.Copy)
+	}
+
+	v, err := backup.Verify(app, cfg, first.Copy)
+	if err != nil {
+		t.Fatalf("failed to restore the backup: %v", err)
+	}
+	if !v.OK() {
+		t.Fatalf("expected the backup to verify, got %+v", v.Checks)
+	}
+	if v.Collections["invoices"] != 1 {
+		t.Fatalf("expected 1 restored invoice, got %d", v.Collections["invoices"])
+	}
+
+	if _, err := backup.Verify(app, backup.Config{Key: "wrong"}, first.Copy); err == nil {
+		t.Fatal("expected the wrong key to fail")
+	}
+
+	second, err := backup.Run(app, cfg, testutil.Start.Add(24*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Pruned) != 2 {
+		t.Fatalf("expected the previous backup and its copy to be pruned, got %v", second.Pruned)
+	}
+	if _, err := os.Stat(first.Copy); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be pruned, got %v", first.Copy, err)
+	}
+}
+
+func TestKeep(t *testing.T) {
+	names := []string{
+		"spindit_20240803_100000.zip",
+		"spindit_20240803_090000.zip",
+		"spindit_20240802_100000.zip.enc",
+		"spindit_20240801_100000.zip",
+		"spindit_20240715_100000.zip",
+		"spindit_20240614_100000.zip",
+		"manual.zip",
+	}
+
+	for _, tc := range []struct {
+		name      string
+		retention backup.Retention
+		want      []string
+	}{
+		{
+			name:      "zero retention keeps everything",
+			retention: backup.Retention{},
+			want:      names,
+		},
+		{
+			name:      "daily",
+			retention: backup.Retention{Daily: 2},
+			want:      []string{"spindit_20240803_100000.zip", "spindit_20240802_100000.zip.enc", "manual.zip"},
+		},
+		{
+			name:      "monthly",
+			retention: backup.Retention{Monthly: 2},
+			want:      []string{"spindit_20240803_100000.zip", "spindit_20240715_100000.zip", "manual.zip"},
+		},
+		{
+			name:      "combined",
+			retention: backup.Retention{Daily: 1, Weekly: 2, Monthly: 3},
+			want: []string{
+				"spindit_20240803_100000.zip",
+				"spindit_20240715_100000.zip",
+				"spindit_20240614_100000.zip",
+				"manual.zip",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			keep := backup.Keep(names, tc.retention)
+
+			got := []string{}
+			for _, name := range names {
+				if keep[name] {
+					got = append(got, name)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected to keep %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted backups are written in chunks, so archives with many invoice
+// files never have to fit in memory:
+//
+//	magic | salt (16) | nonce prefix (8) | chunk...
+//	chunk = length (uint32) | AES-256-GCM sealed data
+//
+// The nonce of a chunk is the prefix followed by the chunk counter, and the
+// last chunk is sealed with a different additional data byte so a truncated
+// file fails to decrypt.
+const (
+	magic     = "SPINDITBK1"
+	saltSize  = 16
+	chunkSize = 1 << 20
+)
+
+var (
+	errTruncated = errors.New("the encrypted backup is truncated")
+	errBadKey    = errors.New("failed to decrypt the backup, wrong key or corrupted file")
+)
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[8:], counter)
+
+	return nonce
+}
+
+// Encrypt writes the encrypted form of src to dst using passphrase.
+func Encrypt(dst io.Writer, src io.Reader, passphrase string) error {
+	header := make([]byte, len(magic)+saltSize+8)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return err
+	}
+	salt := header[len(magic) : len(magic)+saltSize]
+	prefix := header[len(magic)+saltSize:]
+
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	next := make([]byte, chunkSize)
+	n, err := io.ReadFull(src, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	for counter := uint32(0); ; counter++ {
+		// read ahead to know whether the current chunk is the last one
+		m, err := io.ReadFull(src, next)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		last := m == 0
+
+		aad := []byte{0}
+		if last {
+			aad[0] = 1
+		}
+		sealed := aead.Seal(nil, chunkNonce(prefix, counter), buf[:n], aad)
+
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(sealed)))
+		if _, err := dst.Write(length); err != nil {
+			return err
+		}
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+
+		buf, next = next, buf
+		n = m
+	}
+}
+
+// Decrypt writes the plain content of the encrypted src to dst.
+func Decrypt(dst io.Writer, src io.Reader, passphrase string) error {
+	header := make([]byte, len(magic)+saltSize+8)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return fmt.Errorf("failed to read the backup header: %w", err)
+	}
+	if !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return errors.New("the file is not an encrypted Spindit backup")
+	}
+
+	aead, err := deriveKey(passphrase, header[len(magic):len(magic)+saltSize])
+	if err != nil {
+		return err
+	}
+	prefix := header[len(magic)+saltSize:]
+
+	length := make([]byte, 4)
+	for counter := uint32(0); ; counter++ {
+		if _, err := io.ReadFull(src, length); err != nil {
+			return errTruncated
+		}
+
+		size := binary.BigEndian.Uint32(length)
+		if size > chunkSize+uint32(aead.Overhead()) {
+			return errBadKey
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(src, sealed); err != nil {
+			return errTruncated
+		}
+
+		nonce := chunkNonce(prefix, counter)
+		plain, err := aead.Open(nil, nonce, sealed, []byte{0})
+		last := false
+		if err != nil {
+			plain, err = aead.Open(nil, nonce, sealed, []byte{1})
+			last = true
+		}
+		if err != nil {
+			return errBadKey
+		}
+
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package backup_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/jryannel/spindit/internal/app/backup"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	// spans more than one chunk, so the chunk counter and the last chunk
+	// marker are both exercised
+	plain := make([]byte, 1<<20+123)
+	if _, err := rand.Read(plain); err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := &bytes.Buffer{}
+	if err := backup.Encrypt(encrypted, bytes.NewReader(plain), "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted.Bytes(), plain[:64]) {
+		t.Fatal("expected the encrypted backup not to contain the plain content")
+	}
+
+	decrypted := &bytes.Buffer{}
+	if err := backup.Decrypt(decrypted, bytes.NewReader(encrypted.Bytes()), "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), plain) {
+		t.Fatal("expected the decrypted backup to match the original")
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		encrypted := &bytes.Buffer{}
+		if err := backup.Encrypt(encrypted, bytes.NewReader(nil), "secret"); err != nil {
+			t.Fatal(err)
+		}
+		decrypted := &bytes.Buffer{}
+		if err := backup.Decrypt(decrypted, encrypted, "secret"); err != nil {
+			t.Fatal(err)
+		}
+		if decrypted.Len() != 0 {
+			t.Fatalf("expected an empty backup, got %d bytes", decrypted.Len())
+		}
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		if err := backup.Decrypt(&bytes.Buffer{}, bytes.NewReader(encrypted.Bytes()), "other"); err == nil {
+			t.Fatal("expected a wrong passphrase to fail")
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Clone(encrypted.Bytes())
+		tampered[len(tampered)-1] ^= 1
+		if err := backup.Decrypt(&bytes.Buffer{}, bytes.NewReader(tampered), "secret"); err == nil {
+			t.Fatal("expected a modified backup to fail")
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		// cut off the last chunk entirely, the remaining chunks are intact
+		truncated := encrypted.Bytes()[:encrypted.Len()-(123+16+4)]
+		if err := backup.Decrypt(&bytes.Buffer{}, bytes.NewReader(truncated), "secret"); err == nil {
+			t.Fatal("expected a truncated backup to fail")
+		}
+	})
+
+	t.Run("not a backup", func(t *testing.T) {
+		if err := backup.Decrypt(&bytes.Buffer{}, bytes.NewReader([]byte("PK\x03\x04 plain zip")), "secret"); err == nil {
+			t.Fatal("expected a plain archive to fail")
+		}
+	})
+}
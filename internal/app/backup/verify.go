@@ -0,0 +1,305 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/archive"
+)
+
+// requiredCollections must exist in every restored backup.
+var requiredCollections = []string{
+	"users",
+	"app_settings",
+	"zones",
+	"lockers",
+	"requests",
+	"reservations",
+	"invoices",
+	"assignments",
+	"renewals",
+	"email_queue",
+	"audit_logs",
+	"job_runs",
+}
+
+// Check is the outcome of a single verification step.
+type Check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// Verification is the outcome of restoring a backup.
+type Verification struct {
+	Backup      string         `json:"backup"`
+	Collections map[string]int `json:"collections"`
+	Checks      []Check        `json:"checks"`
+}
+
+// OK reports whether all checks passed.
+func (v *Verification) OK() bool {
+	for _, check := range v.Checks {
+		if !check.OK {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (v *Verification) add(name string, err error, message string) {
+	if err != nil {
+		message = err.Error()
+	}
+	v.Checks = append(v.Checks, Check{Name: name, OK: err == nil, Message: message})
+}
+
+// Verify restores a backup into a temporary directory and checks it: the
+// SQLite integrity, the applied migrations, the Spindit collections with
+// their record counts, dangling relations and the invoice PDFs.
+//
+// backup is either a file path (a .zip or an encrypted .zip.enc, which
+// requires cfg.Key) or the name of a backup in the PocketBase backups
+// storage of app. The running app is not changed.
+func Verify(app core.App, cfg Config, backup string) (*Verification, error) {
+	tmp, err := os.MkdirTemp("", "spindit-backup-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	zipPath := filepath.Join(tmp, "backup.zip")
+	if err := fetch(app, cfg, backup, zipPath); err != nil {
+		return nil, err
+	}
+
+	dataDir := filepath.Join(tmp, "pb_data")
+	if err := archive.Extract(zipPath, dataDir); err != nil {
+		return nil, fmt.Errorf("failed to extract the backup: %w", err)
+	}
+	os.Remove(zipPath)
+
+	if _, err := os.Stat(filepath.Join(dataDir, "data.db")); err != nil {
+		return nil, fmt.Errorf("the backup contains no data.db: %w", err)
+	}
+
+	restored := core.NewBaseApp(core.BaseAppConfig{DataDir: dataDir})
+	if err := restored.Bootstrap(); err != nil {
+		return nil, fmt.Errorf("failed to open the restored backup: %w", err)
+	}
+	defer restored.ResetBootstrapState()
+
+	v := &Verification{Backup: backup, Collections: map[string]int{}, Checks: []Check{}}
+
+	v.add("sqlite integrity", checkIntegrity(restored), "ok")
+	v.add("migrations", checkMigrations(restored), fmt.Sprintf("%d migrations applied", len(core.AppMigrations.Items())))
+	v.add("collections", checkCollections(restored, v.Collections), fmt.Sprintf("%d collections readable", len(v.Collections)))
+	v.add("relations", checkRelations(restored), "no dangling relations")
+
+	pdfs, err := checkInvoicePDFs(restored)
+	v.add("invoice pdfs", err, pdfs)
+
+	return v, nil
+}
+
+// fetch writes the plain zip archive of backup to dst.
+func fetch(app core.App, cfg Config, backup string, dst string) error {
+	var src io.ReadCloser
+
+	if _, err := os.Stat(backup); err == nil {
+		file, err := os.Open(backup)
+		if err != nil {
+			return err
+		}
+		src = file
+	} else {
+		fsys, err := app.NewBackupsFilesystem()
+		if err != nil {
+			return err
+		}
+		defer fsys.Close()
+
+		reader, err := fsys.GetReader(backup)
+		if err != nil {
+			return fmt.Errorf("backup %q is neither a file nor a stored backup: %w", backup, err)
+		}
+		src = reader
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, filePermissions)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(backup, encryptedExt) {
+		if cfg.Key == "" {
+			out.Close()
+			return fmt.Errorf("backup %s is encrypted, set %s", backup, KeyEnv)
+		}
+		err = Decrypt(out, src, cfg.Key)
+	} else {
+		_, err = io.Copy(out, src)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func checkIntegrity(app core.App) error {
+	var result string
+	if err := app.DB().NewQuery("PRAGMA integrity_check").Row(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	return nil
+}
+
+func checkMigrations(app core.App) error {
+	applied := map[string]bool{}
+
+	rows := []struct {
+		File string `db:"file"`
+	}{}
+	if err := app.DB().Select("file").From(core.DefaultMigrationsTable).All(&rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		applied[row.File] = true
+	}
+
+	missing := []string{}
+	for _, migration := range core.AppMigrations.Items() {
+		if !applied[migration.File] {
+			missing = append(missing, migration.File)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("migrations not applied in the backup: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func checkCollections(app core.App, counts map[string]int) error {
+	missing := []string{}
+
+	for _, name := range requiredCollections {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			missing = append(missing, name)
+			continue
+		}
+
+		total, err := app.CountRecords(collection)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		counts[name] = int(total)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing collections: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// checkRelations looks for single relations pointing to deleted records.
+func checkRelations(app core.App) error {
+	collections, err := app.FindAllCollections(core.CollectionTypeBase, core.CollectionTypeAuth)
+	if err != nil {
+		return err
+	}
+
+	dangling := []string{}
+	for _, collection := range collections {
+		if collection.System {
+			continue
+		}
+
+		for _, field := range collection.Fields {
+			relation, ok := field.(*core.RelationField)
+			if !ok || relation.IsMultiple() {
+				continue
+			}
+
+			target, err := app.FindCollectionByNameOrId(relation.CollectionId)
+			if err != nil {
+				dangling = append(dangling, fmt.Sprintf("%s.%s targets a missing collection", collection.Name, relation.Name))
+				continue
+			}
+
+			var total int
+			err = app.DB().Select("COUNT(*)").
+				From(collection.Name).
+				Where(dbx.NewExp(fmt.Sprintf(
+					"[[%s]] != '' AND [[%s]] NOT IN (SELECT [[id]] FROM {{%s}})",
+					relation.Name, relation.Name, target.Name,
+				))).
+				Row(&total)
+			if err != nil {
+				return err
+			}
+			if total > 0 {
+				dangling = append(dangling, fmt.Sprintf("%d %s.%s", total, collection.Name, relation.Name))
+			}
+		}
+	}
+	if len(dangling) > 0 {
+		return fmt.Errorf("dangling relations: %s", strings.Join(dangling, ", "))
+	}
+
+	return nil
+}
+
+func checkInvoicePDFs(app core.App) (string, error) {
+	if app.Settings().S3.Enabled {
+		return "skipped, files are stored in S3 and not part of the backup", nil
+	}
+
+	invoices, err := app.FindAllRecords("invoices", dbx.NewExp("[[pdf]] != ''"))
+	if err != nil {
+		return "", err
+	}
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return "", err
+	}
+	defer fsys.Close()
+
+	broken := []string{}
+	for _, invoice := range invoices {
+		r, err := fsys.GetReader(path.Join(invoice.BaseFilesPath(), invoice.GetString("pdf")))
+		if err != nil {
+			broken = append(broken, invoice.GetString("number")+" (missing)")
+			continue
+		}
+
+		header := make([]byte, 5)
+		_, err = io.ReadFull(r, header)
+		r.Close()
+		if err != nil || !bytes.Equal(header, []byte("%PDF-")) {
+			broken = append(broken, invoice.GetString("number")+" (not a pdf)")
+		}
+	}
+	if len(broken) > 0 {
+		return "", errors.New("broken invoice pdfs: " + strings.Join(broken, ", "))
+	}
+
+	return fmt.Sprintf("%d invoice pdfs readable", len(invoices)), nil
+}
@@ -0,0 +1,133 @@
+// Package clock provides the injectable "now" used by all hooks and jobs.
+//
+// The clock is stored per app instance, so tests and staging servers can
+// move time without affecting other apps in the same process.
+package clock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const storeKey = "spindit.clock"
+
+// Timezone is the school's local timezone used for schedules and date input.
+const Timezone = "Europe/Berlin"
+
+// Location returns the [Timezone] location, falling back to UTC when the
+// timezone database is unavailable.
+func Location() *time.Location {
+	if loc, err := time.LoadLocation(Timezone); err == nil {
+		return loc
+	}
+
+	return time.UTC
+}
+
+// Clock returns the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the system clock.
+type Real struct{}
+
+// Now implements [Clock].
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Now returns the current time of the clock configured for app.
+func Now(app core.App) time.Time {
+	return Get(app).Now()
+}
+
+// Get returns the clock configured for app, defaulting to [Real].
+func Get(app core.App) Clock {
+	if c, ok := app.Store().Get(storeKey).(Clock); ok {
+		return c
+	}
+
+	return Real{}
+}
+
+// Set replaces the clock of app. Passing nil restores the system clock.
+func Set(app core.App, c Clock) {
+	if c == nil {
+		app.Store().Remove(storeKey)
+		return
+	}
+
+	app.Store().Set(storeKey, c)
+}
+
+// Fixed is a manually controlled clock for tests. It only moves when Set or Advance is called.
+type Fixed struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFixed returns a clock frozen at now.
+func NewFixed(now time.Time) *Fixed {
+	return &Fixed{now: now}
+}
+
+// Now implements [Clock].
+func (c *Fixed) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *Fixed) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *Fixed) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Offset is a running clock shifted by a fixed duration, used to time travel a dev or staging server.
+type Offset struct {
+	offset time.Duration
+}
+
+// NewOffset returns a running clock that starts at now.
+func NewOffset(now time.Time) *Offset {
+	return &Offset{offset: time.Until(now)}
+}
+
+// Now implements [Clock].
+func (c *Offset) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// Offset returns the difference to the system clock.
+func (c *Offset) Offset() time.Duration {
+	return c.offset
+}
+
+// Parse reads a fake time given as RFC 3339 timestamp, "2006-01-02 15:04" or
+// "2006-01-02" (midnight), interpreting local forms in loc.
+func Parse(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, loc); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation(time.DateOnly, value, loc)
+}
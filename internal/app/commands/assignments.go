@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/assignments"
+	"github.com/jryannel/spindit/internal/app/legacy"
+)
+
+func newAssignmentsCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "assignments",
+		Short: "Manage locker assignments",
+	}
+
+	command.AddCommand(assignmentsImportCommand(app))
+	command.AddCommand(assignmentsReleaseCommand(app))
+
+	return command
+}
+
+func assignmentsReleaseCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "release <assignment|locker>",
+		Example:      "spindit assignments release A-012",
+		Short:        "Ends an assignment, freeing the locker and expiring the request",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			assignment, err := assignments.Resolve(app, args[0])
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("no assignment found for %q", args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := assignments.Release(app, assignment); err != nil {
+				return err
+			}
+
+			color.Green("Released the assignment of request %s.", assignment.GetString("request"))
+
+			return nil
+		},
+	}
+}
+
+func assignmentsImportCommand(app core.App) *cobra.Command {
+	var apply bool
+	var opts legacy.Options
+	var errorsFile string
+
+	command := &cobra.Command{
+		Use:     "import <file.csv>",
+		Example: "spindit assignments import legacy.csv --amount 20 --errors unmatched.csv --apply",
+		Short:   "Imports legacy locker assignments from a spreadsheet export",
+		Long: "Imports legacy locker assignments from a CSV export with the columns email, student, class, locker, paid and year " +
+			"(optional: name, phone, address, zone, amount). Families are matched by email or created without invitation, " +
+			"and each row becomes a request with assignment and invoice. Without --apply the outcome is only reported.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			rows, err := legacy.ParseCSV(file)
+			if err != nil {
+				return err
+			}
+
+			var report *legacy.Report
+			if apply {
+				report, err = legacy.Apply(app, rows, opts)
+			} else {
+				report, err = legacy.Plan(app, rows, opts)
+			}
+			if err != nil {
+				return err
+			}
+
+			printLegacyReport(command.OutOrStdout(), report)
+
+			if errorsFile != "" && report.HasErrors() {
+				out, err := os.Create(errorsFile)
+				if err != nil {
+					return err
+				}
+				defer out.Close()
+
+				if err := legacy.WriteErrors(out, report); err != nil {
+					return err
+				}
+				color.Yellow("Wrote %d unmatched rows to %s.", report.Errors, errorsFile)
+			}
+
+			switch {
+			case report.Applied:
+				color.Green("Imported %d legacy assignments.", report.Imported)
+			default:
+				color.Yellow("Dry run only, rerun with --apply to import the rows.")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&apply, "apply", false, "import the valid rows instead of only reporting them")
+	command.Flags().Float64Var(&opts.Amount, "amount", 0, "invoice amount for rows without an amount column value")
+	command.Flags().StringVar(&opts.Currency, "currency", "EUR", "invoice currency")
+	command.Flags().StringVar(&errorsFile, "errors", "", "write the rows that cannot be imported to this CSV file")
+
+	return command
+}
+
+func printLegacyReport(w io.Writer, report *legacy.Report) {
+	for _, row := range report.Rows {
+		switch row.Status {
+		case legacy.StatusError:
+			fmt.Fprintf(w, "! line %d  %s  %s  locker %s: %s\n", row.Line, row.Email, row.Student, row.Locker, row.Error)
+		case legacy.StatusExists:
+			fmt.Fprintf(w, "= line %d  %s  %s  locker %s (request %s)\n", row.Line, row.Email, row.Student, row.Locker, row.Request)
+		default:
+			marker := "+"
+			if row.NewUser {
+				marker = "+ new family"
+			}
+			fmt.Fprintf(w, "%s line %d  %s  %s  locker %s  %s\n", marker, row.Line, row.Email, row.Student, row.Locker, row.Year)
+		}
+	}
+
+	fmt.Fprintf(
+		w,
+		"\nrows: %d to import, %d already imported, %d errors | new families: %d\n",
+		report.Imported,
+		report.Existing,
+		report.Errors,
+		report.UsersCreated,
+	)
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/backup"
+)
+
+func newBackupCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "backup",
+		Short: "Verify and decrypt pb_data backups",
+		Long: "Backups are created by the backup.create job (spindit jobs run backup.create). " +
+			"Set " + backup.DirEnv + " to copy them to a local directory and " + backup.KeyEnv + " to encrypt these copies.",
+	}
+
+	command.AddCommand(backupVerifyCommand(app))
+	command.AddCommand(backupDecryptCommand())
+
+	return command
+}
+
+func backupVerifyCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:     "verify <backup>",
+		Example: "spindit backup verify /backups/spindit_20250801_020000.zip.enc",
+		Short:   "Restores a backup into a temporary directory and checks its database and invoice files",
+		Long: "Restores a backup (a file path or the name of a backup in pb_data/backups) into a temporary directory " +
+			"and checks the SQLite integrity, the applied migrations, the collections, dangling relations and the invoice PDFs. " +
+			"Encrypted backups require " + backup.KeyEnv + ". The running data is not changed.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			v, err := backup.Verify(app, backup.ConfigFromEnv(), args[0])
+			if err != nil {
+				return err
+			}
+
+			out := command.OutOrStdout()
+			for _, check := range v.Checks {
+				mark := "ok  "
+				if !check.OK {
+					mark = "FAIL"
+				}
+				fmt.Fprintf(out, "%s  %-18s %s\n", mark, check.Name, check.Message)
+			}
+			for _, name := range []string{"users", "lockers", "requests", "invoices", "assignments"} {
+				fmt.Fprintf(out, "      %-18s %d records\n", name, v.Collections[name])
+			}
+
+			if !v.OK() {
+				return errors.New("backup verification failed")
+			}
+
+			color.Green("Backup %s verified.", args[0])
+
+			return nil
+		},
+	}
+}
+
+func backupDecryptCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "decrypt <backup.zip.enc> <backup.zip>",
+		Example:      "spindit backup decrypt spindit_20250801_020000.zip.enc pb_data/backups/spindit_20250801_020000.zip",
+		Short:        "Decrypts an encrypted backup copy, e.g. to restore it from the dashboard",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			key := backup.ConfigFromEnv().Key
+			if key == "" {
+				return fmt.Errorf("set %s to decrypt the backup", backup.KeyEnv)
+			}
+
+			src, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			dst, err := os.OpenFile(args[1], os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+
+			err = backup.Decrypt(dst, src, key)
+			if closeErr := dst.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(args[1])
+				return err
+			}
+
+			color.Green("Decrypted %s to %s.", args[0], args[1])
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/spf13/cobra"
+)
+
+// Register adds the "spindit" operator command group to the PocketBase CLI.
+//
+// Commands change records through the same services and record hooks as the
+// API, so reservations, invoices and lockers stay consistent.
+func Register(app *pocketbase.PocketBase) {
+	command := &cobra.Command{
+		Use:   "spindit",
+		Short: "Spindit operator commands",
+	}
+
+	command.AddCommand(newAssignmentsCommand(app))
+	command.AddCommand(newBackupCommand(app))
+	command.AddCommand(newInvoicesCommand(app))
+	command.AddCommand(newJobsCommand(app))
+	command.AddCommand(newLockersCommand(app))
+	command.AddCommand(newRequestsCommand(app))
+	command.AddCommand(newUsersCommand(app))
+	command.AddCommand(newYearCommand(app))
+
+	app.RootCmd.AddCommand(command)
+}
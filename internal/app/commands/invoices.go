@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/statements"
+)
+
+func newInvoicesCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "invoices",
+		Short: "Manage locker invoices",
+	}
+
+	command.AddCommand(invoicesMarkPaidCommand(app))
+	command.AddCommand(invoicesReconcileCommand(app))
+
+	return command
+}
+
+func invoicesMarkPaidCommand(app core.App) *cobra.Command {
+	var paidAt string
+
+	command := &cobra.Command{
+		Use:          "mark-paid <invoice>",
+		Example:      `spindit invoices mark-paid INV-000042 --paid-at "2024-08-05"`,
+		Short:        "Marks an invoice as paid, which assigns the reserved locker",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			invoice, err := invoices.Find(app, args[0])
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("invoice %q not found", args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			at := clock.Now(app)
+			if paidAt != "" {
+				if at, err = clock.Parse(paidAt, clock.Location()); err != nil {
+					return fmt.Errorf("invalid --paid-at value: %w", err)
+				}
+			}
+
+			if invoice.GetString("status") == invoices.StatusPaid {
+				color.Yellow("Invoice %s is already paid.", invoice.GetString("number"))
+				return nil
+			}
+
+			if err := invoices.MarkPaid(app, invoice, at); err != nil {
+				return err
+			}
+
+			color.Green("Marked invoice %s as paid.", invoice.GetString("number"))
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&paidAt, "paid-at", "", "payment date (RFC 3339 or YYYY-MM-DD [HH:MM]), defaults to now")
+
+	return command
+}
+
+func invoicesReconcileCommand(app core.App) *cobra.Command {
+	var apply bool
+
+	command := &cobra.Command{
+		Use:     "reconcile <statement>",
+		Example: "spindit invoices reconcile camt053-2024-08.xml --apply",
+		Short:   "Matches a bank statement against the open invoices",
+		Long: "Matches the incoming transfers of a CAMT.053 XML or CSV bank statement against the invoices by the invoice number " +
+			"in the reference and the amount. Exact matches are marked paid, partial, over- and unmatched payments are queued " +
+			"for review in bank_transactions. Without --apply the outcome is only reported.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			transactions, err := statements.Parse(data)
+			if err != nil {
+				return err
+			}
+
+			name := filepath.Base(args[0])
+
+			var report *statements.Report
+			if apply {
+				report, err = statements.Apply(app, name, transactions, nil)
+			} else {
+				report, err = statements.Plan(app, name, transactions)
+			}
+			if err != nil {
+				return err
+			}
+
+			printStatementReport(command.OutOrStdout(), report)
+
+			switch {
+			case report.Applied:
+				color.Green("Marked %d invoices as paid, queued %d transactions for review.", report.Matched, report.Review)
+			default:
+				color.Yellow("Dry run only, rerun with --apply to reconcile the statement.")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&apply, "apply", false, "mark the matched invoices paid and queue the rest for review")
+
+	return command
+}
+
+func printStatementReport(w io.Writer, report *statements.Report) {
+	for _, tx := range report.Transactions {
+		marker := "?"
+		switch tx.Status {
+		case statements.StatusMatched:
+			marker = "+"
+		case statements.StatusDuplicate, statements.StatusIgnored:
+			marker = "="
+		case statements.StatusError:
+			marker = "!"
+		}
+
+		fmt.Fprintf(w, "%s line %d  %s  %10.2f %s  %s  %s", marker, tx.Line, tx.BookedAt.In(clock.Location()).Format("2006-01-02"), tx.Amount, tx.Currency, tx.Status, tx.Invoice)
+		if tx.Note != "" {
+			fmt.Fprintf(w, "  (%s)", tx.Note)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(
+		w,
+		"\ntransactions: %d matched, %d to review, %d already imported, %d outgoing, %d errors\n",
+		report.Matched,
+		report.Review,
+		report.Duplicates,
+		report.Ignored,
+		report.Errors,
+	)
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+func newJobsCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "jobs",
+		Short: "Inspect and trigger background jobs",
+	}
+
+	command.AddCommand(jobsListCommand(app))
+	command.AddCommand(jobsRunCommand(app))
+
+	return command
+}
+
+func jobsListCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Example:      "spindit jobs list",
+		Short:        "Lists the background jobs with their schedule and latest run",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			out := command.OutOrStdout()
+			for _, job := range cronjobs.Jobs() {
+				runs, err := query.FindAll(app, cronjobs.RunsCollection, query.Eq("job", job.Id), "-started_at", 1, 0)
+				if err != nil {
+					return err
+				}
+
+				last := "never run"
+				if len(runs) > 0 {
+					last = fmt.Sprintf("%s %s (%d affected)",
+						runs[0].GetDateTime("started_at").Time().Format("2006-01-02 15:04:05"),
+						runs[0].GetString("status"),
+						runs[0].GetInt("affected"),
+					)
+				}
+				fmt.Fprintf(out, "%-22s  %-12s  %s\n", job.Id, job.Schedule, last)
+			}
+
+			return nil
+		},
+	}
+}
+
+func jobsRunCommand(app core.App) *cobra.Command {
+	var dryRun bool
+
+	command := &cobra.Command{
+		Use:          "run <job>",
+		Example:      "spindit jobs run reservations.expire --dry-run",
+		Short:        "Runs a background job immediately and records the run",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			triggeredBy := "cli"
+			if user := os.Getenv("USER"); user != "" {
+				triggeredBy = "cli:" + user
+			}
+
+			run, err := cronjobs.Run(app, args[0], cronjobs.RunOptions{
+				DryRun:      dryRun,
+				Trigger:     cronjobs.TriggerManual,
+				TriggeredBy: triggeredBy,
+			})
+			if err != nil {
+				return err
+			}
+
+			if details := run.Get("details"); details != nil {
+				fmt.Fprintf(command.OutOrStdout(), "details: %s\n", details)
+			}
+
+			if dryRun {
+				color.Yellow("Dry run of %s would affect %d records, nothing was changed.", args[0], run.GetInt("affected"))
+			} else {
+				color.Green("Successfully ran %s, %d records affected.", args[0], run.GetInt("affected"))
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&dryRun, "dry-run", false, "roll back all changes and only report what would be affected")
+
+	return command
+}
@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/layout"
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+func newLockersCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "lockers",
+		Short: "Manage lockers and zones",
+	}
+
+	command.AddCommand(lockersImportCommand(app))
+	command.AddCommand(lockersListCommand(app))
+	command.AddCommand(lockersFreeCommand(app))
+	command.AddCommand(lockersMaintenanceCommand(app))
+
+	return command
+}
+
+func lockersImportCommand(app core.App) *cobra.Command {
+	var apply bool
+	var format string
+
+	command := &cobra.Command{
+		Use:          "import <file>",
+		Example:      "spindit lockers import layout.yaml --apply",
+		Short:        "Validates a CSV or YAML locker layout and applies it with --apply",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if format == "" {
+				format = layout.FormatFromFilename(args[0])
+			}
+
+			l, err := layout.Parse(file, format)
+			if err != nil {
+				return err
+			}
+
+			var diff *layout.Diff
+			if apply {
+				diff, err = layout.Apply(app, l)
+			} else {
+				diff, err = layout.Plan(app, l)
+			}
+			if diff != nil {
+				printLayoutDiff(command.OutOrStdout(), diff)
+			}
+			if errors.Is(err, layout.ErrConflicts) {
+				return errors.New("the layout was not applied, resolve the conflicts listed above first")
+			}
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case diff.Applied:
+				color.Green("Successfully applied the locker layout.")
+			case diff.HasConflicts():
+				color.Yellow("The layout has conflicts and cannot be applied.")
+			default:
+				color.Yellow("Dry run only, rerun with --apply to write the changes.")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&apply, "apply", false, "write the changes instead of only reporting the diff")
+	command.Flags().StringVar(&format, "format", "", "layout format (csv or yaml), detected from the file extension by default")
+
+	return command
+}
+
+func lockersListCommand(app core.App) *cobra.Command {
+	var zone string
+	var status string
+
+	command := &cobra.Command{
+		Use:          "list",
+		Example:      "spindit lockers list --zone A --status maintenance",
+		Short:        "Lists lockers with their zone, status and note",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			filter := query.Filter{}
+			if zone != "" {
+				record, err := findZone(app, zone)
+				if err != nil {
+					return err
+				}
+				filter = filter.And(query.Eq("zone", record.Id))
+			}
+			if status != "" {
+				filter = filter.And(query.Eq("status", status))
+			}
+
+			records, err := query.FindAll(app, "lockers", filter, "zone,number", 0, 0)
+			if err != nil {
+				return err
+			}
+			if errs := app.ExpandRecords(records, []string{"zone"}, nil); len(errs) > 0 {
+				return fmt.Errorf("failed to expand the locker zones: %v", errs)
+			}
+
+			out := command.OutOrStdout()
+			for _, record := range records {
+				zoneName := ""
+				if z := record.ExpandedOne("zone"); z != nil {
+					zoneName = z.GetString("name")
+				}
+				fmt.Fprintf(out, "%-8s  %-15s  %-11s  %s\n",
+					record.GetString("label"),
+					zoneName,
+					record.GetString("status"),
+					record.GetString("note"),
+				)
+			}
+
+			color.Green("%d lockers.", len(records))
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&zone, "zone", "", "limit the list to a zone code, name or id")
+	command.Flags().StringVar(&status, "status", "", "limit the list to a status (free, reserved, occupied, maintenance)")
+
+	return command
+}
+
+func lockersFreeCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "free <locker>",
+		Example:      "spindit lockers free A-012",
+		Short:        "Marks an unassigned locker as free, e.g. after maintenance",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			locker, err := findLocker(app, args[0])
+			if err != nil {
+				return err
+			}
+
+			if err := lockers.Free(app, locker); err != nil {
+				if errors.Is(err, lockers.ErrInUse) {
+					return fmt.Errorf("%w, use \"spindit assignments release\" or \"spindit requests cancel\"", err)
+				}
+				return err
+			}
+
+			color.Green("Locker %s is free.", locker.GetString("label"))
+
+			return nil
+		},
+	}
+}
+
+func lockersMaintenanceCommand(app core.App) *cobra.Command {
+	var note string
+
+	command := &cobra.Command{
+		Use:          "maintenance <locker>",
+		Example:      `spindit lockers maintenance A-012 --note "broken hinge"`,
+		Short:        "Takes an unassigned locker out of service",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			locker, err := findLocker(app, args[0])
+			if err != nil {
+				return err
+			}
+
+			if err := lockers.SetMaintenance(app, locker, note); err != nil {
+				return err
+			}
+
+			color.Green("Locker %s is in maintenance.", locker.GetString("label"))
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&note, "note", "", "replace the locker note, e.g. with the maintenance reason")
+
+	return command
+}
+
+func findLocker(app core.App, value string) (*core.Record, error) {
+	locker, err := lockers.ResolveLocker(app, value, "")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("locker %q not found", value)
+	}
+
+	return locker, err
+}
+
+func findZone(app core.App, value string) (*core.Record, error) {
+	zone, err := query.FindFirst(app, "zones", query.Or(
+		query.Eq("id", value),
+		query.Eq("code", strings.ToUpper(value)),
+		query.Eq("name", value),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("zone %q not found", value)
+	}
+
+	return zone, err
+}
+
+func printLayoutDiff(w io.Writer, diff *layout.Diff) {
+	for _, zone := range diff.ZonesCreated {
+		fmt.Fprintf(w, "+ zone %q\n", zone.Name)
+	}
+	for _, zone := range diff.ZonesUpdated {
+		fmt.Fprintf(w, "~ zone %q: %s\n", zone.Name, strings.Join(zone.Changes, ", "))
+	}
+	for _, locker := range diff.LockersCreated {
+		fmt.Fprintf(w, "+ locker %d (%s, %s)\n", locker.Number, locker.Zone, locker.Status)
+	}
+	for _, locker := range diff.LockersUpdated {
+		fmt.Fprintf(w, "~ locker %d: %s\n", locker.Number, strings.Join(locker.Changes, ", "))
+	}
+	for _, conflict := range diff.Conflicts {
+		fmt.Fprintf(w, "! %s\n", conflict)
+	}
+
+	fmt.Fprintf(
+		w,
+		"\nzones: %d new, %d updated | lockers: %d new, %d updated, %d unchanged, %d not in layout | conflicts: %d\n",
+		len(diff.ZonesCreated),
+		len(diff.ZonesUpdated),
+		len(diff.LockersCreated),
+		len(diff.LockersUpdated),
+		diff.LockersUnchanged,
+		diff.LockersNotInLayout,
+		len(diff.Conflicts),
+	)
+}
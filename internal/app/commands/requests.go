@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/reservations"
+)
+
+func newRequestsCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "requests",
+		Short: "Inspect and cancel locker requests",
+	}
+
+	command.AddCommand(requestsDuplicatesCommand(app))
+	command.AddCommand(requestsShowCommand(app))
+	command.AddCommand(requestsCancelCommand(app))
+
+	return command
+}
+
+func requestsDuplicatesCommand(app core.App) *cobra.Command {
+	var schoolYear string
+	var activeOnly bool
+
+	command := &cobra.Command{
+		Use:          "duplicates",
+		Example:      "spindit requests duplicates --year 2024/25 --active",
+		Short:        "Lists families with multiple requests for the same student and school year",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			groups, err := requests.FindDuplicateGroups(app, schoolYear, activeOnly)
+			if err != nil {
+				return err
+			}
+
+			out := command.OutOrStdout()
+			for _, group := range groups {
+				fmt.Fprintf(out, "%s  %s  %q (%d requests, %d active)\n",
+					group.SchoolYear,
+					group.UserEmail,
+					group.StudentKey,
+					len(group.Requests),
+					group.ActiveCount(),
+				)
+				for _, r := range group.Requests {
+					marker := " "
+					if requests.IsActiveStatus(r.GetString("status")) {
+						marker = "*"
+					}
+					fmt.Fprintf(out, "  %s %s  %-9s  %s  %s\n",
+						marker,
+						r.Id,
+						r.GetString("status"),
+						r.GetDateTime("submitted_at").Time().Format("2006-01-02"),
+						r.GetString("student_name"),
+					)
+				}
+			}
+
+			if len(groups) == 0 {
+				color.Green("No duplicate requests found.")
+			} else {
+				color.Yellow("Found %d duplicate groups (* = active request).", len(groups))
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&schoolYear, "year", "", "limit the report to a school year, e.g. 2024/25")
+	command.Flags().BoolVar(&activeOnly, "active", false, "only list groups with more than one active request")
+
+	return command
+}
+
+func requestsShowCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "show <request>",
+		Example:      "spindit requests show r4nd0m1d000000",
+		Short:        "Shows a request with its reservation, assignment and invoices",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			request, err := findRequest(app, args[0])
+			if err != nil {
+				return err
+			}
+
+			out := command.OutOrStdout()
+			fmt.Fprintf(out, "request      %s (%s)\n", request.Id, request.GetString("status"))
+			fmt.Fprintf(out, "school year  %s\n", request.GetString("school_year"))
+			fmt.Fprintf(out, "student      %s, class %s\n", request.GetString("student_name"), request.GetString("student_class"))
+			fmt.Fprintf(out, "requester    %s, %s, %s\n", request.GetString("requester_name"), request.GetString("requester_phone"), request.GetString("requester_address"))
+			if user, err := app.FindRecordById("users", request.GetString("user")); err == nil {
+				fmt.Fprintf(out, "account      %s\n", user.Email())
+			}
+			fmt.Fprintf(out, "submitted    %s\n", formatDate(request.GetDateTime("submitted_at").Time()))
+
+			held, err := query.FindAll(app, reservations.Collection, query.Eq("request", request.Id), "expires_at", 0, 0)
+			if err != nil {
+				return err
+			}
+			for _, reservation := range held {
+				fmt.Fprintf(out, "reservation  %s until %s\n", lockerLabel(app, reservation.GetString("locker")), formatDate(reservation.GetDateTime("expires_at").Time()))
+			}
+
+			assignment, err := query.FindFirst(app, "assignments", query.Eq("request", request.Id))
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if assignment != nil {
+				fmt.Fprintf(out, "assignment   %s since %s\n", lockerLabel(app, assignment.GetString("locker")), formatDate(assignment.GetDateTime("assigned_at").Time()))
+			}
+
+			billed, err := query.FindAll(app, invoices.Collection, query.Eq("request", request.Id), "number", 0, 0)
+			if err != nil {
+				return err
+			}
+			for _, invoice := range billed {
+				fmt.Fprintf(out, "invoice      %s  %.2f %s  %s", invoice.GetString("number"), invoice.GetFloat("amount"), invoice.GetString("currency"), invoice.GetString("status"))
+				if paidAt := invoice.GetDateTime("paid_at"); !paidAt.IsZero() {
+					fmt.Fprintf(out, " (%s)", formatDate(paidAt.Time()))
+				}
+				fmt.Fprintln(out)
+			}
+
+			return nil
+		},
+	}
+}
+
+func requestsCancelCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "cancel <request>",
+		Example:      "spindit requests cancel r4nd0m1d000000",
+		Short:        "Cancels a request, freeing its locker and cancelling open invoices",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			request, err := findRequest(app, args[0])
+			if err != nil {
+				return err
+			}
+
+			if err := requests.Cancel(app, request); err != nil {
+				return err
+			}
+
+			color.Green("Cancelled request %s.", request.Id)
+
+			return nil
+		},
+	}
+}
+
+func findRequest(app core.App, id string) (*core.Record, error) {
+	request, err := app.FindRecordById(requests.Collection, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("request %q not found", id)
+	}
+
+	return request, err
+}
+
+func lockerLabel(app core.App, id string) string {
+	locker, err := app.FindRecordById("lockers", id)
+	if err != nil {
+		return id
+	}
+
+	return "locker " + locker.GetString("label")
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.In(clock.Location()).Format("2006-01-02 15:04")
+}
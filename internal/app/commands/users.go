@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/erasure"
+)
+
+func newUsersCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "users",
+		Short: "Manage family accounts",
+	}
+
+	command.AddCommand(usersEraseCommand(app))
+
+	return command
+}
+
+func usersEraseCommand(app core.App) *cobra.Command {
+	var apply bool
+
+	command := &cobra.Command{
+		Use:     "erase <user|email>",
+		Example: "spindit users erase family@example.com --apply",
+		Short:   "Erases a family account and its personal data (right to erasure)",
+		Long: "Erases a family account: requests without invoice and queued emails are deleted, requests with invoices " +
+			"are pseudonymized and the account is deleted or, if invoices still reference it, anonymized. " +
+			"Occupied lockers, unpaid and recently paid invoices block the erasure. " +
+			"Without --apply the outcome is only reported.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			user, err := findUser(app, args[0])
+			if err != nil {
+				return err
+			}
+
+			now := clock.Now(app)
+
+			var plan *erasure.Plan
+			if apply {
+				plan, err = erasure.Erase(app, user, nil, now)
+			} else {
+				plan, err = erasure.Check(app, user, now)
+			}
+			if err != nil && !errors.Is(err, erasure.ErrBlocked) {
+				return err
+			}
+
+			printErasure(command.OutOrStdout(), plan)
+
+			switch {
+			case len(plan.Blockers) > 0:
+				return erasure.ErrBlocked
+			case plan.Applied && !plan.ConfirmationSent:
+				color.Yellow("Erased user %s, but the confirmation email could not be sent.", plan.User)
+			case plan.Applied:
+				color.Green("Erased user %s.", plan.User)
+			default:
+				color.Yellow("Dry run only, rerun with --apply to erase the account.")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&apply, "apply", false, "erase the account instead of only reporting the outcome")
+
+	return command
+}
+
+func printErasure(w io.Writer, plan *erasure.Plan) {
+	for _, blocker := range plan.Blockers {
+		fmt.Fprintf(w, "! %-16s %s %s: %s\n", blocker.Kind, blocker.Collection, blocker.RecordId, blocker.Message)
+	}
+
+	for _, counts := range []struct {
+		label  string
+		values map[string]int
+	}{{"delete", plan.Deleted}, {"anonymize", plan.Anonymized}} {
+		names := make([]string, 0, len(counts.values))
+		for name := range counts.values {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%-10s %-12s %d\n", counts.label, name, counts.values[name])
+		}
+	}
+}
+
+// findUser resolves a user by record id or email.
+func findUser(app core.App, value string) (*core.Record, error) {
+	user, err := app.FindRecordById("users", value)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = app.FindAuthRecordByEmail("users", value)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no user found for %q", value)
+	}
+
+	return user, err
+}
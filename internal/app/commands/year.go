@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/jryannel/spindit/internal/app/assignments"
+)
+
+func newYearCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "year",
+		Short: "School year operations",
+	}
+
+	command.AddCommand(yearRolloverCommand(app))
+
+	return command
+}
+
+func yearRolloverCommand(app core.App) *cobra.Command {
+	var from string
+	var to string
+	var apply bool
+
+	command := &cobra.Command{
+		Use:     "rollover",
+		Example: "spindit year rollover --from 2024/25 --apply",
+		Short:   "Closes a school year: renewed assignments move to the next year, all others are released",
+		Long: "Closes a school year: assignments with a confirmed renewal move to a new request of the next year " +
+			"and keep their locker, all other assignments are released and still open requests are cancelled. " +
+			"Without --apply the changes are only reported.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			if to == "" {
+				next, err := assignments.NextSchoolYear(from)
+				if err != nil {
+					return err
+				}
+				to = next
+			}
+
+			var r *assignments.Rollover
+			var err error
+			if apply {
+				r, err = assignments.ApplyRollover(app, from, to)
+			} else {
+				r, err = assignments.PlanRollover(app, from, to)
+			}
+			if err != nil {
+				return err
+			}
+
+			printRollover(command.OutOrStdout(), r)
+
+			if r.Applied {
+				color.Green("Successfully rolled over %s to %s.", r.From, r.To)
+			} else {
+				color.Yellow("Dry run only, rerun with --apply to write the changes.")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&from, "from", "", "the school year to close, e.g. 2024/25")
+	command.Flags().StringVar(&to, "to", "", "the following school year, defaults to the year after --from")
+	command.Flags().BoolVar(&apply, "apply", false, "write the changes instead of only reporting them")
+	_ = command.MarkFlagRequired("from")
+
+	return command
+}
+
+func printRollover(w io.Writer, r *assignments.Rollover) {
+	for _, item := range r.Renewed {
+		fmt.Fprintf(w, "> renew    %s  locker %s  %s\n", item.Request, item.Locker, item.Student)
+	}
+	for _, item := range r.Released {
+		fmt.Fprintf(w, "- release  %s  locker %s  %s\n", item.Request, item.Locker, item.Student)
+	}
+	for _, item := range r.Cancelled {
+		fmt.Fprintf(w, "x cancel   %s  %s\n", item.Request, item.Student)
+	}
+	for _, item := range r.Conflicts {
+		fmt.Fprintf(w, "! conflict %s  locker %s  %s already has active request %s in %s\n", item.Request, item.Locker, item.Student, item.Conflict, r.To)
+	}
+
+	fmt.Fprintf(
+		w,
+		"\n%s -> %s: %d renewed, %d released, %d cancelled, %d conflicts\n",
+		r.From,
+		r.To,
+		len(r.Renewed),
+		len(r.Released),
+		len(r.Cancelled),
+		len(r.Conflicts),
+	)
+}
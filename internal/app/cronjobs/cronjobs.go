@@ -1,39 +1,34 @@
 package cronjobs
 
 import (
-	"time"
+	"errors"
 
 	"github.com/pocketbase/pocketbase/core"
-)
 
-const (
-	jobReservationExpire = "reservations.expire"
-	jobInvoiceReminders  = "invoices.reminders"
-	jobRenewalsOpen      = "renewals.open"
-	jobAssignmentsClose  = "assignments.close"
+	"github.com/jryannel/spindit/internal/app/clock"
 )
 
-// Register configures the baseline cron jobs defined in the PRD. Each job currently logs
-// its execution as a placeholder; business logic will be implemented in later milestones.
+// Register schedules the [Jobs] with the app cron. Every scheduled execution
+// is recorded in the job_runs collection like a manual run and is skipped
+// while a previous run of the job, on this or another instance, is active.
 func Register(app core.App) {
-	if loc, err := time.LoadLocation("Europe/Berlin"); err == nil {
-		app.Cron().SetTimezone(loc)
-	}
-
-	jobs := []struct {
-		id   string
-		expr string
-	}{
-		{jobReservationExpire, "*/1 * * * *"},
-		{jobInvoiceReminders, "0 8 * * *"},
-		{jobRenewalsOpen, "0 9 * * *"},
-		{jobAssignmentsClose, "0 9 1 8 *"},
-	}
+	app.Cron().SetTimezone(clock.Location())
 
-	for _, job := range jobs {
-		job := job
-		app.Cron().MustAdd(job.id, job.expr, func() {
-			app.Logger().Info("cron stub executed", "job", job.id)
+	for _, job := range Jobs() {
+		id := job.Id
+		app.Cron().MustAdd(id, job.Schedule, func() {
+			run, err := Run(app, id, RunOptions{Trigger: TriggerSchedule})
+			if errors.Is(err, ErrLocked) {
+				app.Logger().Warn("cron job skipped, a previous run is still holding the lease", "job", id)
+				return
+			}
+			if err != nil {
+				app.Logger().Error("cron job failed", "job", id, "error", err)
+				return
+			}
+			if affected := run.GetInt("affected"); affected > 0 {
+				app.Logger().Info("cron job finished", "job", id, "affected", affected)
+			}
 		})
 	}
 }
@@ -0,0 +1,129 @@
+package cronjobs_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestManualJobRunWithDryRun(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	now := testutil.FreezeClock(app, testutil.Start)
+
+	request, locker := testutil.ReservedRequest(t, app)
+	now.Advance(8 * 24 * time.Hour)
+
+	// the dry run reports the expiry but rolls it back
+	run, err := cronjobs.Run(app, "reservations.expire", cronjobs.RunOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	testutil.AssertString(t, "dry run status", run.GetString("status"), cronjobs.StatusSuccess)
+	if !run.GetBool("dry_run") || run.GetInt("affected") != 1 {
+		t.Fatalf("expected a dry run affecting 1 record, got dry_run=%v affected=%d", run.GetBool("dry_run"), run.GetInt("affected"))
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "reserved")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "reserved")
+
+	// the real run persists it
+	run, err = cronjobs.Run(app, "reservations.expire", cronjobs.RunOptions{TriggeredBy: "tester"})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testutil.AssertString(t, "run trigger", run.GetString("trigger"), cronjobs.TriggerManual)
+	if run.GetInt("affected") != 1 {
+		t.Fatalf("expected 1 affected record, got %d", run.GetInt("affected"))
+	}
+	if !run.GetDateTime("finished_at").Time().Equal(now.Now()) {
+		t.Fatalf("expected finished_at %s, got %s", now.Now(), run.GetDateTime("finished_at"))
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "expired")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "free")
+
+	testutil.AssertCount(t, app, cronjobs.RunsCollection, dbx.HashExp{"job": "reservations.expire"}, 2)
+
+	if _, err := cronjobs.Run(app, "unknown.job", cronjobs.RunOptions{}); !errors.Is(err, cronjobs.ErrUnknownJob) {
+		t.Fatalf("expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestJobLeaseSkipsOverlappingRuns(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	lease, err := cronjobs.Acquire(app, "reservations.expire", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to acquire the lease: %v", err)
+	}
+	if _, err := cronjobs.Acquire(app, "reservations.expire", time.Minute); !errors.Is(err, cronjobs.ErrLocked) {
+		t.Fatalf("expected ErrLocked for a held lease, got %v", err)
+	}
+
+	run, err := cronjobs.Run(app, "reservations.expire", cronjobs.RunOptions{Trigger: cronjobs.TriggerSchedule})
+	if !errors.Is(err, cronjobs.ErrLocked) {
+		t.Fatalf("expected the overlapping run to be skipped, got %v", err)
+	}
+	testutil.AssertString(t, "skipped run status", run.GetString("status"), cronjobs.StatusSkipped)
+
+	if err := lease.Release(); err != nil {
+		t.Fatal(err)
+	}
+	run, err = cronjobs.Run(app, "reservations.expire", cronjobs.RunOptions{Trigger: cronjobs.TriggerSchedule})
+	if err != nil {
+		t.Fatalf("expected the run to succeed after the release, got %v", err)
+	}
+	testutil.AssertString(t, "run status", run.GetString("status"), cronjobs.StatusSuccess)
+
+	// an expired lease is taken over, and releasing it later keeps the new holder
+	stale, err := cronjobs.Acquire(app, "renewals.open", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := cronjobs.Acquire(app, "renewals.open", time.Minute); err != nil {
+		t.Fatalf("expected the expired lease to be taken over, got %v", err)
+	}
+	if err := stale.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cronjobs.Acquire(app, "renewals.open", time.Minute); !errors.Is(err, cronjobs.ErrLocked) {
+		t.Fatalf("expected the new lease to survive the stale release, got %v", err)
+	}
+}
+
+func TestInterruptedRunIsFailed(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	collection, err := app.FindCollectionByNameOrId(cronjobs.RunsCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed := core.NewRecord(collection)
+	crashed.Load(map[string]any{
+		"job":        "reservations.expire",
+		"trigger":    cronjobs.TriggerSchedule,
+		"status":     cronjobs.StatusRunning,
+		"started_at": testutil.Start.Add(-time.Hour),
+	})
+	if err := app.Save(crashed); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := cronjobs.Run(app, "reservations.expire", cronjobs.RunOptions{Trigger: cronjobs.TriggerSchedule})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testutil.AssertString(t, "run status", run.GetString("status"), cronjobs.StatusSuccess)
+
+	crashed = testutil.Reload(t, app, crashed)
+	testutil.AssertString(t, "interrupted run status", crashed.GetString("status"), cronjobs.StatusFailed)
+	if crashed.GetDateTime("finished_at").IsZero() || crashed.GetString("error") == "" {
+		t.Fatalf("expected the interrupted run to be finished with an error, got %v", crashed.PublicExport())
+	}
+}
@@ -0,0 +1,315 @@
+package cronjobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/alerts"
+	"github.com/jryannel/spindit/internal/app/backup"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/reservations"
+	"github.com/jryannel/spindit/internal/app/retention"
+	"github.com/jryannel/spindit/internal/app/text"
+	"github.com/jryannel/spindit/internal/app/webhooks"
+)
+
+const (
+	// RunsCollection is the name of the job run history collection.
+	RunsCollection = "job_runs"
+
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+
+	jobReservationExpire = "reservations.expire"
+	jobInvoiceReminders  = "invoices.reminders"
+	jobRenewalsOpen      = "renewals.open"
+	jobAssignmentsClose  = "assignments.close"
+	jobRetention         = "retention.anonymize"
+	jobBackup            = "backup.create"
+	jobAlerts            = "alerts.evaluate"
+	jobWebhooks          = "webhooks.deliver"
+)
+
+// ErrUnknownJob is returned by [Run] for job ids that are not registered.
+var ErrUnknownJob = errors.New("unknown job")
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// Result summarizes the records a job run changed.
+type Result struct {
+	// Affected is the total number of changed records.
+	Affected int
+	// Details optionally breaks Affected down, e.g. per collection.
+	Details map[string]int
+}
+
+// Job is a named background job with its cron schedule.
+//
+// Run receives whether it executes as a dry run, so jobs with side effects
+// outside the database (notifications, HTTP calls, files) can skip them.
+type Job struct {
+	Id       string
+	Schedule string
+	Run      func(app core.App, now time.Time, dryRun bool) (Result, error)
+	// LockTTL is the lease duration of a run, [DefaultLockTTL] if zero.
+	LockTTL time.Duration
+}
+
+// Jobs returns the baseline jobs defined in the PRD. Jobs without business
+// logic yet log their execution as a placeholder.
+func Jobs() []Job {
+	return []Job{
+		{Id: jobReservationExpire, Schedule: "*/1 * * * *", Run: expireReservations, LockTTL: 5 * time.Minute},
+		{Id: jobInvoiceReminders, Schedule: "0 8 * * *", Run: stub(jobInvoiceReminders)},
+		{Id: jobRenewalsOpen, Schedule: "0 9 * * *", Run: stub(jobRenewalsOpen)},
+		{Id: jobAssignmentsClose, Schedule: "0 9 1 8 *", Run: stub(jobAssignmentsClose)},
+		{Id: jobRetention, Schedule: "0 3 * * *", Run: anonymize, LockTTL: time.Hour},
+		{Id: jobBackup, Schedule: "0 2 * * *", Run: createBackup, LockTTL: 2 * time.Hour},
+		{Id: jobAlerts, Schedule: "*/5 * * * *", Run: evaluateAlerts, LockTTL: 5 * time.Minute},
+		{Id: jobWebhooks, Schedule: "*/1 * * * *", Run: deliverWebhooks, LockTTL: 30 * time.Minute},
+	}
+}
+
+// Find returns the job with the given id.
+func Find(id string) (Job, bool) {
+	for _, job := range Jobs() {
+		if job.Id == id {
+			return job, true
+		}
+	}
+
+	return Job{}, false
+}
+
+// RunOptions configures a single job execution.
+type RunOptions struct {
+	// DryRun executes the job in a transaction that is rolled back, so the
+	// run reports what would change without persisting it.
+	DryRun bool
+	// Trigger is either [TriggerSchedule] or [TriggerManual].
+	Trigger string
+	// TriggeredBy identifies the operator of a manual run.
+	TriggeredBy string
+}
+
+// Run executes the job with the given id and records the execution in the
+// job_runs collection. The returned run record is also returned when the
+// job itself failed, together with the job error.
+//
+// Runs hold the job lease while executing. When a previous run still holds
+// it, the run is recorded as skipped and [ErrLocked] is returned; other
+// failures to acquire the lease are recorded as failed runs. The lease
+// is renewed while the job executes, so slow runs keep it. Runs still
+// recorded as running when the lease is acquired were interrupted, e.g. by a
+// crash, and are marked as failed.
+func Run(app core.App, id string, opts RunOptions) (*core.Record, error) {
+	job, ok := Find(id)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownJob, id)
+	}
+
+	return runJob(app, job, opts)
+}
+
+func runJob(app core.App, job Job, opts RunOptions) (*core.Record, error) {
+	if opts.Trigger == "" {
+		opts.Trigger = TriggerManual
+	}
+
+	collection, err := app.FindCollectionByNameOrId(RunsCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	run := core.NewRecord(collection)
+	run.Set("job", job.Id)
+	run.Set("trigger", opts.Trigger)
+	run.Set("triggered_by", opts.TriggeredBy)
+	run.Set("dry_run", opts.DryRun)
+	run.Set("started_at", clock.Now(app))
+
+	ttl := job.LockTTL
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+
+	lease, err := Acquire(app, job.Id, ttl)
+	if err != nil {
+		// only runs skipped for a held lease are not failures
+		if errors.Is(err, ErrLocked) {
+			run.Set("status", StatusSkipped)
+		} else {
+			run.Set("status", StatusFailed)
+		}
+		run.Set("finished_at", clock.Now(app))
+		run.Set("error", text.Truncate(err.Error(), 2000))
+		if saveErr := app.Save(run); saveErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to record the %s run: %w", job.Id, saveErr))
+		}
+
+		return run, err
+	}
+	defer func() {
+		if err := lease.Release(); err != nil {
+			app.Logger().Error("failed to release the job lease", "job", job.Id, "error", err)
+		}
+	}()
+
+	if err := failInterrupted(app, job.Id); err != nil {
+		return nil, err
+	}
+
+	run.Set("status", StatusRunning)
+	if err := app.Save(run); err != nil {
+		return nil, fmt.Errorf("failed to record the %s run: %w", job.Id, err)
+	}
+
+	stop := lease.KeepAlive(ttl)
+	result, jobErr := execute(app, job, opts.DryRun)
+	stop()
+
+	run.Set("finished_at", clock.Now(app))
+	run.Set("affected", result.Affected)
+	run.Set("details", result.Details)
+	if jobErr != nil {
+		run.Set("status", StatusFailed)
+		run.Set("error", text.Truncate(jobErr.Error(), 2000))
+	} else {
+		run.Set("status", StatusSuccess)
+	}
+
+	if err := app.Save(run); err != nil {
+		return run, errors.Join(jobErr, fmt.Errorf("failed to record the %s run: %w", job.Id, err))
+	}
+
+	return run, jobErr
+}
+
+func execute(app core.App, job Job, dryRun bool) (result Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	if !dryRun {
+		return job.Run(app, clock.Now(app), false)
+	}
+
+	err = app.RunInTransaction(func(txApp core.App) error {
+		var runErr error
+		result, runErr = job.Run(txApp, clock.Now(txApp), true)
+		if runErr != nil {
+			return runErr
+		}
+
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+
+	return result, err
+}
+
+// failInterrupted marks the runs of job that are still recorded as running
+// as failed. It is called while holding the lease, so none of them can
+// still be executing.
+func failInterrupted(app core.App, job string) error {
+	interrupted, err := app.FindAllRecords(RunsCollection, dbx.HashExp{"job": job, "status": StatusRunning})
+	if err != nil {
+		return err
+	}
+
+	for _, run := range interrupted {
+		run.Set("status", StatusFailed)
+		run.Set("finished_at", clock.Now(app))
+		run.Set("error", "interrupted: the run did not finish before its lease expired")
+		if err := app.Save(run); err != nil {
+			return fmt.Errorf("failed to mark the interrupted %s run %s as failed: %w", job, run.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func expireReservations(app core.App, now time.Time, _ bool) (Result, error) {
+	expired, err := reservations.Expire(app, now)
+
+	return Result{Affected: expired, Details: map[string]int{"reservations": expired}}, err
+}
+
+func anonymize(app core.App, now time.Time, _ bool) (Result, error) {
+	report, err := retention.Anonymize(app, now)
+
+	return Result{
+		Affected: report.Requests + report.Transactions + report.Emails,
+		Details: map[string]int{
+			"requests":          report.Requests,
+			"bank_transactions": report.Transactions,
+			"email_queue":       report.Emails,
+			"skipped_active":    report.Skipped,
+		},
+	}, err
+}
+
+// createBackup names and prunes backups by the real time, so a fake clock
+// on a staging copy cannot prune current backups. A dry run only reports the
+// backups it would prune.
+func createBackup(app core.App, _ time.Time, dryRun bool) (Result, error) {
+	result, err := backup.Run(app, backup.ConfigFromEnv(), time.Now(), dryRun)
+
+	created := 0
+	if result.Name != "" && err == nil {
+		created = 1
+	}
+
+	return Result{
+		Affected: created + len(result.Pruned),
+		Details:  map[string]int{"created": created, "pruned": len(result.Pruned)},
+	}, err
+}
+
+func evaluateAlerts(app core.App, now time.Time, dryRun bool) (Result, error) {
+	report, err := alerts.Evaluate(app, now, dryRun)
+
+	return Result{
+		Affected: report.Fired + report.Resolved,
+		Details: map[string]int{
+			"fired":         report.Fired,
+			"resolved":      report.Resolved,
+			"notified":      report.Notified,
+			"notify_failed": report.NotifyFailed,
+		},
+	}, err
+}
+
+func deliverWebhooks(app core.App, now time.Time, dryRun bool) (Result, error) {
+	report, err := webhooks.Deliver(app, now, dryRun)
+
+	return Result{
+		Affected: report.Delivered + report.Retried + report.Failed,
+		Details: map[string]int{
+			"delivered": report.Delivered,
+			"retried":   report.Retried,
+			"failed":    report.Failed,
+		},
+	}, err
+}
+
+func stub(id string) func(core.App, time.Time, bool) (Result, error) {
+	return func(app core.App, _ time.Time, _ bool) (Result, error) {
+		app.Logger().Info("cron stub executed", "job", id)
+		return Result{}, nil
+	}
+}
@@ -0,0 +1,83 @@
+package cronjobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	// testutil registers the job routes, which import this package
+	_ "github.com/jryannel/spindit/migrations"
+)
+
+func TestRunLongerThanItsLease(t *testing.T) {
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	job := Job{
+		Id:      jobReservationExpire,
+		LockTTL: 60 * time.Millisecond,
+		Run: func(app core.App, _ time.Time, _ bool) (Result, error) {
+			time.Sleep(300 * time.Millisecond)
+			return Result{Affected: 1}, nil
+		},
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		run, err := runJob(app, job, RunOptions{Trigger: TriggerSchedule})
+		if err == nil && run.GetString("status") != StatusSuccess {
+			err = errors.New("expected the slow run to succeed, got " + run.GetString("status"))
+		}
+		done <- err
+	}()
+
+	// the next ticks find the lease renewed past its TTL
+	for i := 0; i < 3; i++ {
+		time.Sleep(80 * time.Millisecond)
+		if _, err := Acquire(app, job.Id, job.LockTTL); !errors.Is(err, ErrLocked) {
+			t.Fatalf("tick %d: expected the running job to keep its lease, got %v", i+1, err)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Acquire(app, job.Id, job.LockTTL); err != nil {
+		t.Fatalf("expected the lease to be released after the run, got %v", err)
+	}
+}
+
+func TestRunFailsWithoutLease(t *testing.T) {
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	// the lease cannot be written at all
+	if _, err := app.DB().DropTable(LocksCollection).Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	job := Job{
+		Id: jobReservationExpire,
+		Run: func(app core.App, _ time.Time, _ bool) (Result, error) {
+			t.Error("expected the job not to run without its lease")
+			return Result{}, nil
+		},
+	}
+
+	run, err := runJob(app, job, RunOptions{Trigger: TriggerSchedule})
+	if err == nil || errors.Is(err, ErrLocked) {
+		t.Fatalf("expected the lease error, got %v", err)
+	}
+	if status := run.GetString("status"); status != StatusFailed {
+		t.Fatalf("expected the run to be recorded as failed, got %s", status)
+	}
+}
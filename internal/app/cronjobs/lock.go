@@ -0,0 +1,152 @@
+package cronjobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// LocksCollection is the name of the job lease collection.
+const LocksCollection = "job_locks"
+
+// DefaultLockTTL is the lease duration of jobs without an explicit LockTTL.
+// A crashed instance blocks the job for at most this long.
+const DefaultLockTTL = 15 * time.Minute
+
+// ErrLocked is returned when another run still holds the lease of a job.
+var ErrLocked = errors.New("job is locked by another run")
+
+// instanceId identifies this server process in the lease holder.
+var instanceId = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}()
+
+// Lease is a held job lock.
+type Lease struct {
+	app       core.App
+	Job       string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// Acquire takes the lease of job for ttl. It returns [ErrLocked] while an
+// unexpired lease of another run exists.
+//
+// The lease is written with a single conditional upsert, so concurrent
+// instances sharing the database cannot both acquire it. Leases use the
+// system time rather than the app clock, since they guard real executions.
+func Acquire(app core.App, job string, ttl time.Duration) (*Lease, error) {
+	now := time.Now().UTC()
+	lease := &Lease{
+		app:       app,
+		Job:       job,
+		Holder:    instanceId + "/" + randomToken(),
+		ExpiresAt: now.Add(ttl),
+	}
+
+	result, err := app.DB().NewQuery(
+		"INSERT INTO {{" + LocksCollection + "}} ([[id]], [[job]], [[holder]], [[acquired_at]], [[expires_at]]) " +
+			"VALUES ({:id}, {:job}, {:holder}, {:now}, {:expires}) " +
+			"ON CONFLICT ([[job]]) DO UPDATE SET " +
+			"[[holder]] = excluded.[[holder]], [[acquired_at]] = excluded.[[acquired_at]], [[expires_at]] = excluded.[[expires_at]] " +
+			"WHERE {{" + LocksCollection + "}}.[[expires_at]] <= excluded.[[acquired_at]]",
+	).Bind(dbx.Params{
+		"id":      core.GenerateDefaultRandomId(),
+		"job":     job,
+		"holder":  lease.Holder,
+		"now":     now.Format(types.DefaultDateLayout),
+		"expires": lease.ExpiresAt.Format(types.DefaultDateLayout),
+	}).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire the %s lease: %w", job, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, ErrLocked
+	}
+
+	return lease, nil
+}
+
+// Renew extends the lease to ttl from now. It returns [ErrLocked] when the
+// lease expired and was taken over by another run meanwhile.
+func (l *Lease) Renew(ttl time.Duration) error {
+	expires := time.Now().UTC().Add(ttl)
+
+	result, err := l.app.DB().Update(LocksCollection, dbx.Params{
+		"expires_at": expires.Format(types.DefaultDateLayout),
+	}, dbx.HashExp{"job": l.Job, "holder": l.Holder}).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to renew the %s lease: %w", l.Job, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrLocked
+	}
+
+	l.ExpiresAt = expires
+
+	return nil
+}
+
+// KeepAlive renews the lease for ttl every third of ttl until the returned
+// stop function is called, so a run that takes longer than ttl keeps it.
+// Failed renewals are logged and retried with the next tick.
+func (l *Lease) KeepAlive(ttl time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := l.Renew(ttl); err != nil {
+					l.app.Logger().Error("failed to renew the job lease", "job", l.Job, "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Release gives up the lease. Leases that expired and were taken over by
+// another run are left untouched.
+func (l *Lease) Release() error {
+	_, err := l.app.DB().Delete(LocksCollection, dbx.HashExp{"job": l.Job, "holder": l.Holder}).Execute()
+
+	return err
+}
+
+func randomToken() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
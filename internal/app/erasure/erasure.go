@@ -0,0 +1,326 @@
+// Package erasure implements the right to erasure of a family account.
+package erasure
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/retention"
+)
+
+// PaymentHoldDays is the period after a payment during which the account
+// cannot be erased, so refunds and chargebacks can still be matched.
+const PaymentHoldDays = 30
+
+// AuditAction is the audit_logs action of the compliance record.
+const AuditAction = "gdpr.erasure"
+
+// Blocker kinds.
+const (
+	BlockerStaffAccount   = "staff_account"
+	BlockerOccupiedLocker = "occupied_locker"
+	BlockerUnpaidInvoice  = "unpaid_invoice"
+	BlockerRecentPayment  = "recent_payment"
+)
+
+const (
+	usersCollection        = "users"
+	emailsCollection       = "email_queue"
+	transactionsCollection = "bank_transactions"
+	auditLogsCollection    = "audit_logs"
+)
+
+// ErrBlocked is returned by [Erase] when the account has [Blocker]s.
+var ErrBlocked = errors.New("the account cannot be erased yet")
+
+// Blocker is a record that prevents the erasure until staff resolved it.
+type Blocker struct {
+	Kind       string `json:"kind"`
+	Collection string `json:"collection"`
+	RecordId   string `json:"record_id"`
+	Message    string `json:"message"`
+}
+
+// Plan lists the outcome of erasing an account.
+//
+// Requests with an invoice are kept for accounting and pseudonymized, all
+// other requests (with their reservations, assignments and renewals) and the
+// queued emails are deleted. Bank transfers paying the retained invoices lose
+// payer and remittance text. The account itself is deleted when no retained
+// request references it, otherwise it is anonymized and locked.
+type Plan struct {
+	User     string    `json:"user"`
+	Blockers []Blocker `json:"blockers"`
+
+	Deleted    map[string]int `json:"deleted"`
+	Anonymized map[string]int `json:"anonymized"`
+
+	// AccountDeleted is false when the account is kept anonymized.
+	AccountDeleted bool `json:"account_deleted"`
+
+	Applied bool `json:"applied"`
+	// ConfirmationSent reports whether the confirmation email was delivered.
+	ConfirmationSent bool `json:"confirmation_sent"`
+
+	deleteRequests    []*core.Record
+	anonymizeRequests []*core.Record
+	transactions      []*core.Record
+	emails            []*core.Record
+}
+
+// Check reports the blockers and the planned changes of erasing user at now
+// without writing them.
+func Check(app core.App, user *core.Record, now time.Time) (*Plan, error) {
+	plan := &Plan{
+		User:       user.Id,
+		Blockers:   []Blocker{},
+		Deleted:    map[string]int{},
+		Anonymized: map[string]int{},
+	}
+
+	if access.Role(user) != access.RoleFamily {
+		plan.Blockers = append(plan.Blockers, Blocker{
+			Kind:       BlockerStaffAccount,
+			Collection: usersCollection,
+			RecordId:   user.Id,
+			Message:    fmt.Sprintf("the account has the %s role, change it to family first", access.Role(user)),
+		})
+	}
+
+	userRequests, err := app.FindAllRecords(requests.Collection, dbx.HashExp{"user": user.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, request := range userRequests {
+		if request.GetString("status") == requests.StatusAssigned {
+			plan.Blockers = append(plan.Blockers, Blocker{
+				Kind:       BlockerOccupiedLocker,
+				Collection: requests.Collection,
+				RecordId:   request.Id,
+				Message:    fmt.Sprintf("%s still holds a locker in %s, release the assignment first", request.GetString("student_name"), request.GetString("school_year")),
+			})
+		}
+
+		requestInvoices, err := app.FindAllRecords(invoices.Collection, dbx.HashExp{"request": request.Id})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, invoice := range requestInvoices {
+			transactions, err := app.FindAllRecords(transactionsCollection, dbx.HashExp{
+				"invoice":       invoice.Id,
+				"anonymized_at": "",
+			})
+			if err != nil {
+				return nil, err
+			}
+			plan.transactions = append(plan.transactions, transactions...)
+
+			switch invoice.GetString("status") {
+			case invoices.StatusDraft, invoices.StatusSent:
+				plan.Blockers = append(plan.Blockers, Blocker{
+					Kind:       BlockerUnpaidInvoice,
+					Collection: invoices.Collection,
+					RecordId:   invoice.Id,
+					Message:    fmt.Sprintf("invoice %s is not paid, cancel the request or record the payment first", invoice.GetString("number")),
+				})
+			case invoices.StatusPaid:
+				paidAt := invoice.GetDateTime("paid_at").Time()
+				if until := paidAt.AddDate(0, 0, PaymentHoldDays); now.Before(until) {
+					plan.Blockers = append(plan.Blockers, Blocker{
+						Kind:       BlockerRecentPayment,
+						Collection: invoices.Collection,
+						RecordId:   invoice.Id,
+						Message:    fmt.Sprintf("invoice %s was paid on %s and may still be refunded, retry after %s", invoice.GetString("number"), paidAt.Format("2006-01-02"), until.Format("2006-01-02")),
+					})
+				}
+			}
+		}
+
+		switch {
+		case len(requestInvoices) == 0:
+			plan.deleteRequests = append(plan.deleteRequests, request)
+		case request.GetDateTime("anonymized_at").IsZero():
+			plan.anonymizeRequests = append(plan.anonymizeRequests, request)
+		}
+	}
+
+	plan.emails, err = app.FindAllRecords(emailsCollection, dbx.NewExp(
+		"LOWER([[recipient]]) = LOWER({:email})",
+		dbx.Params{"email": user.Email()},
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	retained := len(userRequests) - len(plan.deleteRequests)
+	plan.AccountDeleted = retained == 0
+
+	plan.Deleted[requests.Collection] = len(plan.deleteRequests)
+	plan.Deleted[emailsCollection] = len(plan.emails)
+	plan.Anonymized[requests.Collection] = len(plan.anonymizeRequests)
+	plan.Anonymized[transactionsCollection] = len(plan.transactions)
+	if plan.AccountDeleted {
+		plan.Deleted[usersCollection] = 1
+	} else {
+		plan.Anonymized[usersCollection] = 1
+	}
+
+	return plan, nil
+}
+
+// Erase removes the personal data of user as described by [Plan] and writes
+// a compliance record to audit_logs, attributed to actor (nil or the user
+// itself for the family, nil for a superuser). The family is emailed a confirmation afterwards;
+// a failed delivery is logged and reported in Plan.ConfirmationSent.
+//
+// It returns the plan and [ErrBlocked] without changes while the account has
+// blockers.
+func Erase(app core.App, user *core.Record, actor *core.Record, now time.Time) (*Plan, error) {
+	plan, err := Check(app, user, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Blockers) > 0 {
+		return plan, ErrBlocked
+	}
+
+	recipient := mail.Address{Name: user.GetString("full_name"), Address: user.Email()}
+	language := user.GetString("language")
+
+	err = app.RunInTransaction(func(txApp core.App) error {
+		for _, request := range plan.deleteRequests {
+			if err := txApp.Delete(request); err != nil {
+				return fmt.Errorf("failed to delete request %s: %w", request.Id, err)
+			}
+		}
+
+		for _, request := range plan.anonymizeRequests {
+			if err := retention.PseudonymizeRequest(txApp, request, now); err != nil {
+				return fmt.Errorf("failed to anonymize request %s: %w", request.Id, err)
+			}
+		}
+
+		for _, transaction := range plan.transactions {
+			if err := retention.AnonymizeTransaction(txApp, transaction, now); err != nil {
+				return fmt.Errorf("failed to anonymize bank transaction %s: %w", transaction.Id, err)
+			}
+		}
+
+		for _, email := range plan.emails {
+			if err := txApp.Delete(email); err != nil {
+				return fmt.Errorf("failed to delete email %s: %w", email.Id, err)
+			}
+		}
+
+		if plan.AccountDeleted {
+			if err := txApp.Delete(user); err != nil {
+				return fmt.Errorf("failed to delete the account: %w", err)
+			}
+		} else if err := anonymizeAccount(txApp, user); err != nil {
+			return fmt.Errorf("failed to anonymize the account: %w", err)
+		}
+
+		return writeAuditLog(txApp, plan, actor, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Applied = true
+
+	if err := sendConfirmation(app, recipient, language); err != nil {
+		app.Logger().Error("failed to send the erasure confirmation", "user", user.Id, "error", err)
+	} else {
+		plan.ConfirmationSent = true
+	}
+
+	return plan, nil
+}
+
+// anonymizeAccount keeps the account referenced by retained requests but
+// removes its profile and makes it unusable for sign in.
+func anonymizeAccount(app core.App, user *core.Record) error {
+	user.SetEmail(fmt.Sprintf("erased-%s@example.invalid", user.Id))
+	user.SetEmailVisibility(false)
+	user.SetVerified(false)
+	user.SetRandomPassword()
+	user.RefreshTokenKey()
+	user.Set("full_name", "")
+	user.Set("address", "")
+	user.Set("phone", "")
+	user.Set("language", "")
+
+	return app.Save(user)
+}
+
+func writeAuditLog(app core.App, plan *Plan, actor *core.Record, now time.Time) error {
+	collection, err := app.FindCollectionByNameOrId(auditLogsCollection)
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	// a family erasing itself is recorded without actor, its account may
+	// already be deleted within this transaction
+	if actor != nil && actor.Collection().Name == usersCollection && actor.Id != plan.User {
+		record.Set("actor", actor.Id)
+	}
+	record.Set("action", AuditAction)
+	record.Set("collection", usersCollection)
+	record.Set("record_id", plan.User)
+	record.Set("diff", map[string]any{
+		"erased_at":       now.UTC().Format(time.RFC3339),
+		"account_deleted": plan.AccountDeleted,
+		"deleted":         plan.Deleted,
+		"anonymized":      plan.Anonymized,
+	})
+
+	return app.Save(record)
+}
+
+func sendConfirmation(app core.App, to mail.Address, language string) error {
+	subject := "Your Spindit data has been erased"
+	paragraphs := []string{
+		"Hello,",
+		"as requested, we erased your Spindit account and the personal data of your locker requests. " +
+			"Invoices we are legally required to keep were anonymized.",
+		"Your Spindit team",
+	}
+	if language == "de" {
+		subject = "Ihre Spindit-Daten wurden gelöscht"
+		paragraphs = []string{
+			"Guten Tag,",
+			"wie gewünscht haben wir Ihr Spindit-Konto und die persönlichen Daten Ihrer Spindanträge gelöscht. " +
+				"Rechnungen, die wir gesetzlich aufbewahren müssen, wurden anonymisiert.",
+			"Ihr Spindit-Team",
+		}
+	}
+
+	var htmlBody strings.Builder
+	for _, p := range paragraphs {
+		htmlBody.WriteString("<p>" + html.EscapeString(p) + "</p>")
+	}
+
+	meta := app.Settings().Meta
+
+	return app.NewMailClient().Send(&mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      []mail.Address{to},
+		Subject: subject,
+		HTML:    htmlBody.String(),
+		Text:    strings.Join(paragraphs, "\n\n") + "\n",
+	})
+}
@@ -0,0 +1,116 @@
+package erasure_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/assignments"
+	"github.com/jryannel/spindit/internal/app/erasure"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestRightToErasure(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	now := testutil.FreezeClock(app, testutil.Start)
+
+	request, locker := testutil.AssignedRequest(t, app)
+	family, err := app.FindRecordById("users", request.GetString("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := erasure.Erase(app, family, nil, now.Now())
+	if !errors.Is(err, erasure.ErrBlocked) {
+		t.Fatalf("expected ErrBlocked, got %v", err)
+	}
+	kinds := []string{}
+	for _, blocker := range plan.Blockers {
+		kinds = append(kinds, blocker.Kind)
+	}
+	if strings.Join(kinds, ",") != "occupied_locker,recent_payment" {
+		t.Fatalf("expected an occupied locker and a recent payment blocker, got %v", kinds)
+	}
+
+	assignment, err := assignments.Resolve(app, locker.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assignments.Release(app, assignment); err != nil {
+		t.Fatal(err)
+	}
+	now.Advance(31 * 24 * time.Hour)
+
+	invoice, err := app.FindFirstRecordByData("invoices", "request", request.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := app.FindCollectionByNameOrId("bank_transactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer := core.NewRecord(transactions)
+	transfer.Load(map[string]any{
+		"booked_at":   testutil.Start,
+		"amount":      20,
+		"payer":       family.GetString("full_name"),
+		"reference":   invoice.GetString("number"),
+		"fingerprint": "bank:erasure",
+		"status":      "matched",
+		"invoice":     invoice.Id,
+	})
+	if err := app.Save(transfer); err != nil {
+		t.Fatal(err)
+	}
+
+	email := family.Email()
+	plan, err = erasure.Erase(app, testutil.Reload(t, app, family), nil, now.Now())
+	if err != nil {
+		t.Fatalf("failed to erase the family with a retained invoice: %v", err)
+	}
+	if plan.AccountDeleted || !plan.ConfirmationSent {
+		t.Fatalf("expected an anonymized account and a confirmation, got %+v", plan)
+	}
+
+	family = testutil.Reload(t, app, family)
+	if family.Email() == email || family.GetString("full_name") != "" {
+		t.Fatalf("expected an anonymized account, got %q %q", family.Email(), family.GetString("full_name"))
+	}
+	testutil.AssertString(t, "retained requester name", testutil.Reload(t, app, request).GetString("requester_name"), "anonymized")
+	testutil.AssertCount(t, app, "invoices", dbx.HashExp{"request": request.Id}, 1)
+	transfer = testutil.Reload(t, app, transfer)
+	testutil.AssertString(t, "transfer payer", transfer.GetString("payer"), "anonymized")
+	testutil.AssertString(t, "transfer invoice", transfer.GetString("invoice"), invoice.Id)
+	testutil.AssertCount(t, app, "audit_logs", dbx.HashExp{"action": erasure.AuditAction, "record_id": family.Id}, 1)
+
+	// a family without invoices is deleted completely
+	other := testutil.CreateUser(t, app, nil)
+	emails, err := app.FindCollectionByNameOrId("email_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued := core.NewRecord(emails)
+	queued.Load(map[string]any{"recipient": other.Email(), "subject": "Welcome", "template": "welcome", "status": "sent"})
+	if err := app.Save(queued); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = erasure.Erase(app, other, nil, now.Now())
+	if err != nil {
+		t.Fatalf("failed to erase the family without invoices: %v", err)
+	}
+	if !plan.AccountDeleted {
+		t.Fatalf("expected the account to be deleted, got %+v", plan)
+	}
+	testutil.AssertCount(t, app, "users", dbx.HashExp{"id": other.Id}, 0)
+	testutil.AssertCount(t, app, "email_queue", dbx.HashExp{"id": queued.Id}, 0)
+	testutil.AssertCount(t, app, "audit_logs", dbx.HashExp{"action": erasure.AuditAction, "record_id": other.Id}, 1)
+
+	if sent := app.TestMailer.TotalSend(); sent != 2 {
+		t.Fatalf("expected 2 confirmation emails, got %d", sent)
+	}
+}
@@ -0,0 +1,289 @@
+// Package export bundles all personal data stored about a family into a ZIP
+// archive, for data subject access requests.
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+)
+
+// Collections with personal data, in the order they appear in the export.
+var collections = []string{
+	"requests",
+	"reservations",
+	"assignments",
+	"invoices",
+	"bank_transactions",
+	"payment_reviews",
+	"renewals",
+	"email_queue",
+	"audit_logs",
+}
+
+// Data is the personal data of a single user.
+type Data struct {
+	User    *core.Record
+	Records map[string][]*core.Record
+}
+
+// Collect loads the user profile and all records related to the user:
+// their requests with reservations, assignments, invoices and renewals, the
+// bank transfers and online payments booked on or reviewed for their
+// invoices, the emails queued for their address and the audit entries
+// written by them or about one of their records.
+func Collect(app core.App, user *core.Record) (*Data, error) {
+	data := &Data{User: user, Records: map[string][]*core.Record{}}
+
+	requests, err := app.FindAllRecords("requests", dbx.HashExp{"user": user.Id})
+	if err != nil {
+		return nil, err
+	}
+	data.Records["requests"] = requests
+
+	requestIds := recordIds(requests)
+	for _, name := range []string{"reservations", "assignments", "invoices"} {
+		records, err := findByRelation(app, name, "request", requestIds)
+		if err != nil {
+			return nil, err
+		}
+		data.Records[name] = records
+	}
+
+	invoiceIds := recordIds(data.Records["invoices"])
+	for _, name := range []string{"bank_transactions", "payment_reviews"} {
+		records, err := findByRelation(app, name, "invoice", invoiceIds)
+		if err != nil {
+			return nil, err
+		}
+		data.Records[name] = records
+	}
+
+	data.Records["renewals"], err = findByRelation(app, "renewals", "assignment", recordIds(data.Records["assignments"]))
+	if err != nil {
+		return nil, err
+	}
+
+	data.Records["email_queue"], err = app.FindAllRecords("email_queue", dbx.NewExp(
+		"LOWER([[recipient]]) = LOWER({:email})",
+		dbx.Params{"email": user.Email()},
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	related := []any{user.Id}
+	for _, name := range []string{"requests", "reservations", "assignments", "invoices", "bank_transactions", "payment_reviews", "renewals"} {
+		related = append(related, recordIds(data.Records[name])...)
+	}
+	data.Records["audit_logs"], err = app.FindAllRecords("audit_logs", dbx.Or(
+		dbx.HashExp{"actor": user.Id},
+		dbx.In("record_id", related...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Filename returns the download name of the export of user.
+func Filename(user *core.Record, now time.Time) string {
+	return fmt.Sprintf("spindit-export-%s-%s.zip", user.Id, now.Format("20060102"))
+}
+
+// WriteZip writes the export archive: data.json with all records,
+// summary.txt with a readable overview and the invoice PDFs.
+func WriteZip(app core.App, w io.Writer, data *Data, now time.Time) error {
+	archive := zip.NewWriter(w)
+
+	create := func(name string) (io.Writer, error) {
+		return archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+
+	if err := writeJSON(create, data, now); err != nil {
+		return err
+	}
+
+	if err := writeSummary(create, data, now); err != nil {
+		return err
+	}
+
+	if err := writeInvoicePDFs(app, create, data.Records["invoices"]); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func writeJSON(create func(string) (io.Writer, error), data *Data, now time.Time) error {
+	user := data.User.Fresh()
+	user.IgnoreEmailVisibility(true)
+
+	records := map[string]any{}
+	for _, name := range collections {
+		exported := make([]map[string]any, 0, len(data.Records[name]))
+		for _, record := range data.Records[name] {
+			exported = append(exported, record.PublicExport())
+		}
+		records[name] = exported
+	}
+
+	w, err := create("data.json")
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(map[string]any{
+		"exported_at": now.UTC().Format(time.RFC3339),
+		"user":        user.PublicExport(),
+		"records":     records,
+	})
+}
+
+func writeSummary(create func(string) (io.Writer, error), data *Data, now time.Time) error {
+	w, err := create("summary.txt")
+	if err != nil {
+		return err
+	}
+
+	user := data.User
+	loc := clock.Location()
+	date := func(r *core.Record, field string) string {
+		if value := r.GetDateTime(field); !value.IsZero() {
+			return value.Time().In(loc).Format("2006-01-02")
+		}
+		return "-"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Spindit data export\nCreated %s\n\n", now.In(loc).Format("2006-01-02 15:04"))
+
+	fmt.Fprintf(&b, "Account\n")
+	fmt.Fprintf(&b, "  Email:    %s\n", user.Email())
+	fmt.Fprintf(&b, "  Name:     %s\n", user.GetString("full_name"))
+	fmt.Fprintf(&b, "  Address:  %s\n", user.GetString("address"))
+	fmt.Fprintf(&b, "  Phone:    %s\n", user.GetString("phone"))
+	fmt.Fprintf(&b, "  Language: %s\n", user.GetString("language"))
+	fmt.Fprintf(&b, "  Role:     %s\n\n", user.GetString("role"))
+
+	fmt.Fprintf(&b, "Locker requests (%d)\n", len(data.Records["requests"]))
+	for _, r := range data.Records["requests"] {
+		fmt.Fprintf(&b, "  %s  %s, class %s  %s  submitted %s\n",
+			r.GetString("school_year"), r.GetString("student_name"), r.GetString("student_class"),
+			r.GetString("status"), date(r, "submitted_at"))
+	}
+
+	fmt.Fprintf(&b, "\nAssignments (%d)\n", len(data.Records["assignments"]))
+	for _, r := range data.Records["assignments"] {
+		fmt.Fprintf(&b, "  locker %s since %s\n", r.GetString("locker"), date(r, "assigned_at"))
+	}
+
+	fmt.Fprintf(&b, "\nInvoices (%d)\n", len(data.Records["invoices"]))
+	for _, r := range data.Records["invoices"] {
+		fmt.Fprintf(&b, "  %s  %.2f %s  %s  due %s  paid %s\n",
+			r.GetString("number"), r.GetFloat("amount"), r.GetString("currency"),
+			r.GetString("status"), date(r, "due_at"), date(r, "paid_at"))
+	}
+
+	fmt.Fprintf(&b, "\nBank transfers (%d)\n", len(data.Records["bank_transactions"]))
+	for _, r := range data.Records["bank_transactions"] {
+		fmt.Fprintf(&b, "  %s  %.2f %s  %s  %s\n",
+			date(r, "booked_at"), r.GetFloat("amount"), r.GetString("currency"),
+			r.GetString("status"), r.GetString("reference"))
+	}
+
+	fmt.Fprintf(&b, "\nOnline payments in review (%d)\n", len(data.Records["payment_reviews"]))
+	for _, r := range data.Records["payment_reviews"] {
+		fmt.Fprintf(&b, "  %s  %.2f %s  %s  %s  %s\n",
+			date(r, "paid_at"), r.GetFloat("amount"), r.GetString("currency"),
+			r.GetString("reference"), r.GetString("reason"), r.GetString("status"))
+	}
+
+	fmt.Fprintf(&b, "\nRenewals (%d)\n", len(data.Records["renewals"]))
+	for _, r := range data.Records["renewals"] {
+		fmt.Fprintf(&b, "  %s  %s\n", r.GetString("school_year"), r.GetString("status"))
+	}
+
+	fmt.Fprintf(&b, "\nEmails (%d)\n", len(data.Records["email_queue"]))
+	for _, r := range data.Records["email_queue"] {
+		fmt.Fprintf(&b, "  %s  %s  %s\n", date(r, "sent_at"), r.GetString("status"), r.GetString("subject"))
+	}
+
+	fmt.Fprintf(&b, "\nAudit entries (%d)\n", len(data.Records["audit_logs"]))
+	for _, r := range data.Records["audit_logs"] {
+		fmt.Fprintf(&b, "  %s  %s %s\n", r.GetString("action"), r.GetString("collection"), r.GetString("record_id"))
+	}
+
+	fmt.Fprintf(&b, "\nAll records are included in data.json, invoice documents in the invoices folder.\n")
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
+
+func writeInvoicePDFs(app core.App, create func(string) (io.Writer, error), invoices []*core.Record) error {
+	var fsys *filesystem.System
+
+	for _, invoice := range invoices {
+		name := invoice.GetString("pdf")
+		if name == "" {
+			continue
+		}
+
+		if fsys == nil {
+			var err error
+			fsys, err = app.NewFilesystem()
+			if err != nil {
+				return err
+			}
+			defer fsys.Close()
+		}
+
+		r, err := fsys.GetReader(path.Join(invoice.BaseFilesPath(), name))
+		if err != nil {
+			return fmt.Errorf("failed to read the pdf of invoice %s: %w", invoice.GetString("number"), err)
+		}
+
+		w, err := create("invoices/" + invoice.GetString("number") + ".pdf")
+		if err == nil {
+			_, err = io.Copy(w, r)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findByRelation(app core.App, collection string, field string, ids []any) ([]*core.Record, error) {
+	if len(ids) == 0 {
+		return []*core.Record{}, nil
+	}
+
+	return app.FindAllRecords(collection, dbx.In(field, ids...))
+}
+
+func recordIds(records []*core.Record) []any {
+	ids := make([]any, len(records))
+	for i, record := range records {
+		ids[i] = record.Id
+	}
+
+	return ids
+}
@@ -0,0 +1,143 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/export"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/payments"
+	"github.com/jryannel/spindit/internal/app/statements"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestPersonalDataExport(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	request, _ := testutil.AssignedRequest(t, app)
+	other, _ := testutil.ReservedRequest(t, app)
+
+	user, err := app.FindRecordById("users", request.GetString("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a bank transfer and an online payment in review for the invoices of
+	// the family and of the other family
+	invoice, err := app.FindFirstRecordByData(invoices.Collection, "request", request.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, invoice := range []*core.Record{invoice, testutil.CreateInvoice(t, app, other, nil)} {
+		_, err := statements.Apply(app, "camt.xml", []statements.Transaction{{
+			BookedAt:      testutil.Start,
+			Amount:        20,
+			Currency:      "EUR",
+			Reference:     "Spind " + invoice.GetString("number"),
+			BankReference: fmt.Sprintf("BANK-REF-%d", i+1),
+		}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reviews, err := app.FindCollectionByNameOrId(payments.ReviewsCollection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		review := core.NewRecord(reviews)
+		review.Set("provider", "stripe")
+		review.Set("session", fmt.Sprintf("cs_%d", i+1))
+		review.Set("reference", fmt.Sprintf("PAYMENT-REF-%d", i+1))
+		review.Set("invoice", invoice.Id)
+		review.Set("amount", 25)
+		review.Set("currency", "EUR")
+		review.Set("reason", payments.ReasonAmountMismatch)
+		review.Set("status", "open")
+		if err := app.Save(review); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := export.Collect(app, user)
+	if err != nil {
+		t.Fatalf("failed to collect the export: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteZip(app, &buf, data, testutil.Start); err != nil {
+		t.Fatalf("failed to write the export: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid export archive: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+
+	if !strings.Contains(files["summary.txt"], user.Email()) {
+		t.Fatalf("expected the summary to contain the account email, got:\n%s", files["summary.txt"])
+	}
+
+	var exported struct {
+		User    map[string]any              `json:"user"`
+		Records map[string][]map[string]any `json:"records"`
+	}
+	if err := json.Unmarshal([]byte(files["data.json"]), &exported); err != nil {
+		t.Fatalf("invalid data.json: %v", err)
+	}
+
+	if exported.User["email"] != user.Email() {
+		t.Fatalf("expected the exported email %q, got %v", user.Email(), exported.User["email"])
+	}
+	if _, ok := exported.User["password"]; ok {
+		t.Fatal("expected the password hash to be excluded from the export")
+	}
+
+	for name, want := range map[string]int{"requests": 1, "assignments": 1, "invoices": 1, "bank_transactions": 1, "payment_reviews": 1} {
+		if got := len(exported.Records[name]); got != want {
+			t.Fatalf("expected %d exported %s, got %d", want, name, got)
+		}
+	}
+	for _, reference := range []string{"BANK-REF-1", "PAYMENT-REF-1"} {
+		if !strings.Contains(files["data.json"], reference) {
+			t.Fatalf("expected the export to contain the payment %s", reference)
+		}
+	}
+	for _, section := range []string{"Bank transfers (1)", "Online payments in review (1)"} {
+		if !strings.Contains(files["summary.txt"], section) {
+			t.Fatalf("expected the summary to list %q, got:\n%s", section, files["summary.txt"])
+		}
+	}
+	if invoice.GetString("pdf") == "" {
+		t.Fatal("expected the invoice to have a pdf")
+	}
+	if pdf := files["invoices/"+invoice.GetString("number")+".pdf"]; !strings.HasPrefix(pdf, "%PDF-") {
+		t.Fatalf("expected the invoice pdf in the export, got the files %v", slices.Sorted(maps.Keys(files)))
+	}
+	if strings.Contains(files["data.json"], other.Id) || strings.Contains(files["data.json"], "REF-2") {
+		t.Fatal("expected the export to exclude the requests of other families")
+	}
+}
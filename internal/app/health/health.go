@@ -0,0 +1,241 @@
+// Package health implements the liveness and readiness checks used by the
+// deployment health probes.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/cron"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// StaleAfter is how long a scheduled job may be overdue before its last
+// run counts as stale, leaving room for the cron tick and a running job.
+const StaleAfter = 10 * time.Minute
+
+// Check is the outcome of a single health check.
+type Check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the outcome of all checks of a probe.
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// OK reports whether all checks passed.
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Public returns a copy of the report without the check messages, which may
+// reveal configuration and error details.
+func (r *Report) Public() *Report {
+	public := &Report{Status: r.Status, Checks: make([]Check, len(r.Checks))}
+	for i, check := range r.Checks {
+		public.Checks[i] = Check{Name: check.Name, OK: check.OK}
+	}
+
+	return public
+}
+
+func (r *Report) add(name string, err error, message string) {
+	if err != nil {
+		message = err.Error()
+		r.Status = StatusFail
+	}
+	r.Checks = append(r.Checks, Check{Name: name, OK: err == nil, Message: message})
+}
+
+// Live reports whether the process is able to serve requests, which only
+// requires the database to answer queries.
+func Live(app core.App) *Report {
+	r := &Report{Status: StatusOK, Checks: []Check{}}
+
+	var one int
+	r.add("database", app.DB().NewQuery("SELECT 1").Row(&one), "reachable")
+
+	return r
+}
+
+// Ready reports whether the app is able to do its work: the database is
+// writable, all migrations are applied, app_settings exist, a mailer is
+// configured, the cron jobs are registered and none of them missed a
+// scheduled run since the process started at started.
+func Ready(app core.App, now time.Time, started time.Time) *Report {
+	r := &Report{Status: StatusOK, Checks: []Check{}}
+
+	r.add("database", checkWritable(app), "writable")
+	r.add("migrations", checkMigrations(app), "all applied")
+	r.add("settings", checkSettings(app), "app_settings present")
+	r.add("mailer", checkMailer(app), "SMTP configured")
+	r.add("cron", checkCron(app), fmt.Sprintf("%d jobs registered", len(cronjobs.Jobs())))
+
+	stale, err := checkJobs(app, now, started)
+	r.add("jobs", err, stale)
+
+	return r
+}
+
+// checkWritable runs an update that matches no row. It needs the write lock
+// for the duration of the statement, so a read only or locked database
+// fails, but changes neither the schema nor any page.
+func checkWritable(app core.App) error {
+	_, err := app.DB().NewQuery("UPDATE {{_params}} SET [[id]] = [[id]] WHERE [[id]] = ''").Execute()
+
+	return err
+}
+
+func checkMigrations(app core.App) error {
+	applied := map[string]bool{}
+
+	rows := []struct {
+		File string `db:"file"`
+	}{}
+	if err := app.DB().Select("file").From(core.DefaultMigrationsTable).All(&rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		applied[row.File] = true
+	}
+
+	pending := []string{}
+	for _, migration := range core.AppMigrations.Items() {
+		if !applied[migration.File] {
+			pending = append(pending, migration.File)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+
+	return nil
+}
+
+func checkSettings(app core.App) error {
+	if _, err := settings.FindRecord(app); err != nil {
+		return fmt.Errorf("app_settings missing: %w", err)
+	}
+
+	return nil
+}
+
+func checkMailer(app core.App) error {
+	s := app.Settings()
+	if !s.SMTP.Enabled || s.SMTP.Host == "" {
+		return errors.New("SMTP is not enabled, emails would go through sendmail")
+	}
+	if s.Meta.SenderAddress == "" {
+		return errors.New("no sender address configured")
+	}
+
+	return nil
+}
+
+func checkCron(app core.App) error {
+	registered := map[string]bool{}
+	for _, job := range app.Cron().Jobs() {
+		registered[job.Id()] = true
+	}
+
+	missing := []string{}
+	for _, job := range cronjobs.Jobs() {
+		if !registered[job.Id] {
+			missing = append(missing, job.Id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("jobs not registered: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// checkJobs reports jobs whose latest scheduled time since started (and at
+// least [StaleAfter] ago) has no run, including failed and skipped runs.
+func checkJobs(app core.App, now time.Time, started time.Time) (string, error) {
+	stale := []string{}
+
+	for _, job := range cronjobs.Jobs() {
+		schedule, err := cron.NewSchedule(job.Schedule)
+		if err != nil {
+			return "", err
+		}
+
+		due, ok := lastDue(schedule, now.Add(-StaleAfter), started)
+		if !ok {
+			continue
+		}
+
+		var total int
+		err = app.DB().Select("COUNT(*)").
+			From(cronjobs.RunsCollection).
+			Where(dbx.HashExp{"job": job.Id}).
+			AndWhere(dbx.NewExp("dry_run = FALSE")).
+			AndWhere(dbx.NewExp("started_at >= {:due}", dbx.Params{
+				"due": due.UTC().Format(types.DefaultDateLayout),
+			})).
+			Row(&total)
+		if err != nil {
+			return "", err
+		}
+		if total == 0 {
+			stale = append(stale, fmt.Sprintf("%s (due %s)", job.Id, due.Format(time.RFC3339)))
+		}
+	}
+	if len(stale) > 0 {
+		return "", fmt.Errorf("jobs missed their schedule: %s", strings.Join(stale, ", "))
+	}
+
+	return "no missed runs", nil
+}
+
+// lastDue returns the latest minute in [since, until] at which schedule is
+// due, evaluated in the cron timezone. Non-matching months, days and hours
+// are skipped as a whole, so yearly schedules stay cheap.
+func lastDue(schedule *cron.Schedule, until time.Time, since time.Time) (time.Time, bool) {
+	t := until.In(clock.Location()).Truncate(time.Minute)
+
+	for !t.Before(since) {
+		m := cron.NewMoment(t)
+
+		if _, ok := schedule.Months[m.Month]; !ok {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		_, day := schedule.Days[m.Day]
+		_, weekday := schedule.DaysOfWeek[m.DayOfWeek]
+		if !day || !weekday {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		if _, ok := schedule.Hours[m.Hour]; !ok {
+			t = t.Truncate(time.Hour).Add(-time.Minute)
+			continue
+		}
+		if schedule.IsDue(m) {
+			return t, true
+		}
+
+		t = t.Add(-time.Minute)
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,62 @@
+package health_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/health"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestHealthAndReadiness(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	if live := health.Live(app); !live.OK() {
+		t.Fatalf("expected the app to be live, got %+v", live.Checks)
+	}
+
+	failed := func(report *health.Report) []string {
+		names := []string{}
+		for _, check := range report.Checks {
+			if !check.OK {
+				names = append(names, check.Name)
+			}
+		}
+		return names
+	}
+
+	started := testutil.Start.Add(-2 * time.Hour)
+
+	report := health.Ready(app, testutil.Start, started)
+	if report.OK() || fmt.Sprint(failed(report)) != "[mailer cron jobs]" {
+		t.Fatalf("expected the mailer, cron and jobs checks to fail, got %+v", report.Checks)
+	}
+
+	app.Settings().SMTP.Enabled = true
+	app.Settings().SMTP.Host = "smtp.example.com"
+	cronjobs.Register(app)
+
+	report = health.Ready(app, testutil.Start, started)
+	if fmt.Sprint(failed(report)) != "[jobs]" {
+		t.Fatalf("expected only the jobs check to fail, got %+v", report.Checks)
+	}
+	for _, check := range report.Checks {
+		if check.Name == "jobs" && !strings.Contains(check.Message, "reservations.expire") {
+			t.Fatalf("expected reservations.expire to be stale, got %q", check.Message)
+		}
+	}
+
+	for _, job := range []string{"reservations.expire", "alerts.evaluate", "webhooks.deliver"} {
+		if _, err := cronjobs.Run(app, job, cronjobs.RunOptions{Trigger: cronjobs.TriggerSchedule}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if report := health.Ready(app, testutil.Start, started); !report.OK() {
+		t.Fatalf("expected the app to be ready, got %+v", report.Checks)
+	}
+}
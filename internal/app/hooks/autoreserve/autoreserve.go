@@ -3,12 +3,15 @@ package autoreserve
 import (
 	"database/sql"
 	"errors"
-	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/reservations"
 )
 
 const (
@@ -22,12 +25,14 @@ func Register(app core.App) {
 	app.OnRecordAfterCreateSuccess(requestsCollection).BindFunc(func(e *core.RecordEvent) error {
 		record := e.Record
 		if record == nil {
-			return nil
+			return e.Next()
 		}
 
-		return app.RunInTransaction(func(txApp core.App) error {
+		now := clock.Now(app)
+
+		err := app.RunInTransaction(func(txApp core.App) error {
 			// Skip if an assignment already exists for this request.
-			if _, err := txApp.FindFirstRecordByFilter(assignmentsCollection, fmt.Sprintf(`request = "%s"`, record.Id)); err == nil {
+			if _, err := query.FindFirst(txApp, assignmentsCollection, query.Eq("request", record.Id)); err == nil {
 				return nil
 			} else if !errors.Is(err, sql.ErrNoRows) {
 				return err
@@ -37,37 +42,31 @@ func Register(app core.App) {
 			var locker *core.Record
 
 			if preferredLockerValue != "" {
-				if candidate, err := txApp.FindRecordById(lockersCollection, preferredLockerValue); err == nil {
-					if strings.EqualFold(candidate.GetString("status"), "free") {
-						locker = candidate
-					}
+				candidate, err := lockers.ResolveLocker(txApp, preferredLockerValue, record.GetString("preferred_zone"))
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return err
 				}
-
-				if locker == nil {
-					if lockerNumber, err := strconv.Atoi(preferredLockerValue); err == nil {
-						candidate, findErr := txApp.FindFirstRecordByFilter(lockersCollection, fmt.Sprintf(`number = %d`, lockerNumber))
-						if findErr == nil && strings.EqualFold(candidate.GetString("status"), "free") {
-							locker = candidate
-						}
-					}
+				if err == nil && strings.EqualFold(candidate.GetString("status"), "free") {
+					locker = candidate
 				}
 			}
 
 			if locker == nil {
-				filter := `status = "free"`
-				if zone := record.GetString("preferred_zone"); zone != "" {
-					filter = fmt.Sprintf(`status = "free" && zone = "%s"`, zone)
+				filter := query.Eq("status", "free")
+				zone := record.GetString("preferred_zone")
+				if zone != "" {
+					filter = filter.And(query.Eq("zone", zone))
 				}
 
-				lockers, err := txApp.FindRecordsByFilter(lockersCollection, filter, "number", 1, 0)
+				candidates, err := query.FindAll(txApp, lockersCollection, filter, "number", 1, 0)
 				if err != nil {
 					return err
 				}
-				if len(lockers) == 0 {
-					app.Logger().Warn("no available locker to auto-reserve", "request", record.Id, "filter", filter)
+				if len(candidates) == 0 {
+					app.Logger().Warn("no available locker to auto-reserve", "request", record.Id, "zone", zone)
 					return nil
 				}
-				locker = lockers[0]
+				locker = candidates[0]
 			}
 
 			locker.Set("status", "reserved")
@@ -83,12 +82,16 @@ func Register(app core.App) {
 			assignment := core.NewRecord(assignmentsColl)
 			assignment.Set("request", record.Id)
 			assignment.Set("locker", locker.Id)
-			assignment.Set("assigned_at", types.NowDateTime())
+			assignment.Set("assigned_at", now)
 
 			if err := txApp.Save(assignment); err != nil {
 				return err
 			}
 
+			if _, err := reservations.Create(txApp, record, locker, now); err != nil {
+				return err
+			}
+
 			if record.GetString("status") != "reserved" {
 				record.Set("status", "reserved")
 				if err := txApp.Save(record); err != nil {
@@ -98,24 +101,37 @@ func Register(app core.App) {
 
 			return nil
 		})
+		if err != nil {
+			return err
+		}
+
+		return e.Next()
 	})
 
 	app.OnRecordAfterUpdateSuccess(requestsCollection).BindFunc(func(e *core.RecordEvent) error {
 		record := e.Record
 		if record == nil {
-			return nil
+			return e.Next()
 		}
 
 		if record.GetString("status") != "cancelled" {
-			return nil
+			return e.Next()
 		}
 
 		if original := record.Original(); original != nil && strings.EqualFold(original.GetString("status"), "cancelled") {
-			return nil
+			return e.Next()
 		}
 
-		return app.RunInTransaction(func(txApp core.App) error {
-			assignment, err := txApp.FindFirstRecordByFilter(assignmentsCollection, fmt.Sprintf(`request = "%s"`, record.Id))
+		err := app.RunInTransaction(func(txApp core.App) error {
+			if err := reservations.DeleteForRequest(txApp, record.Id); err != nil {
+				return err
+			}
+
+			if err := invoices.CancelOpen(txApp, record.Id); err != nil {
+				return err
+			}
+
+			assignment, err := query.FindFirst(txApp, assignmentsCollection, query.Eq("request", record.Id))
 			if err != nil {
 				if errors.Is(err, sql.ErrNoRows) {
 					return nil
@@ -139,5 +155,10 @@ func Register(app core.App) {
 
 			return txApp.Delete(assignment)
 		})
+		if err != nil {
+			return err
+		}
+
+		return e.Next()
 	})
 }
@@ -0,0 +1,198 @@
+// Package invoices implements the payment confirmation of locker invoices.
+package invoices
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+)
+
+// Collection is the name of the invoices collection.
+const Collection = "invoices"
+
+// Invoice statuses.
+const (
+	StatusDraft     = "draft"
+	StatusSent      = "sent"
+	StatusPaid      = "paid"
+	StatusCancelled = "cancelled"
+)
+
+// numberFormat is the format of invoice numbers.
+const numberFormat = "INV-%06d"
+
+const (
+	requestsCollection     = "requests"
+	lockersCollection      = "lockers"
+	assignmentsCollection  = "assignments"
+	reservationsCollection = "reservations"
+)
+
+// Register confirms the locker assignment whenever an invoice becomes paid,
+// regardless of whether staff, a command or a payment integration changed it,
+// and attaches the invoice PDF once an invoice is sent.
+func Register(app core.App) {
+	app.OnRecordCreate(Collection).BindFunc(func(e *core.RecordEvent) error {
+		attachPDF(e.App, e.Record)
+		return e.Next()
+	})
+	app.OnRecordUpdate(Collection).BindFunc(func(e *core.RecordEvent) error {
+		attachPDF(e.App, e.Record)
+		return e.Next()
+	})
+
+	app.OnRecordUpdate(Collection).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		if e.Record.GetString("status") != StatusPaid || (original != nil && original.GetString("status") == StatusPaid) {
+			return e.Next()
+		}
+
+		if e.Record.GetDateTime("paid_at").IsZero() {
+			e.Record.Set("paid_at", clock.Now(e.App))
+		}
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			return confirmPayment(txApp, e.Record)
+		})
+	})
+}
+
+// MarkPaid marks invoice as paid at paidAt, which assigns the reserved locker.
+//
+// This is the shared payment confirmation path used by all payment sources.
+func MarkPaid(app core.App, invoice *core.Record, paidAt time.Time) error {
+	switch invoice.GetString("status") {
+	case StatusPaid:
+		return nil
+	case StatusCancelled:
+		return fmt.Errorf("invoice %s is cancelled", invoice.GetString("number"))
+	}
+
+	invoice.Set("status", StatusPaid)
+	invoice.Set("paid_at", paidAt)
+
+	return app.Save(invoice)
+}
+
+// confirmPayment turns the reservation of the invoiced request into a final assignment:
+// the request becomes assigned and the locker occupied.
+func confirmPayment(app core.App, invoice *core.Record) error {
+	request, err := app.FindRecordById(requestsCollection, invoice.GetString("request"))
+	if err != nil {
+		return err
+	}
+
+	assignment, err := app.FindFirstRecordByData(assignmentsCollection, "request", request.Id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if assignment == nil {
+		// the reservation expired before the payment arrived, staff has to assign a locker manually
+		app.Logger().Warn(
+			"paid invoice without a reserved locker",
+			"invoice", invoice.GetString("number"),
+			"request", request.Id,
+		)
+		return nil
+	}
+
+	paidAt := invoice.GetDateTime("paid_at")
+
+	locker, err := app.FindRecordById(lockersCollection, assignment.GetString("locker"))
+	if err != nil {
+		return err
+	}
+	if locker.GetString("status") != "occupied" {
+		locker.Set("status", "occupied")
+		if err := app.Save(locker); err != nil {
+			return err
+		}
+	}
+
+	assignment.Set("assigned_at", paidAt)
+	if err := app.Save(assignment); err != nil {
+		return err
+	}
+
+	if request.GetString("status") != "assigned" {
+		request.Set("status", "assigned")
+		if err := app.Save(request); err != nil {
+			return err
+		}
+	}
+
+	// the reservation is fulfilled
+	held, err := app.FindAllRecords(reservationsCollection, dbx.HashExp{"request": request.Id})
+	if err != nil {
+		return err
+	}
+	for _, reservation := range held {
+		if err := app.Delete(reservation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CancelOpen cancels the unpaid invoices of a request, e.g. when it expired or was cancelled.
+func CancelOpen(app core.App, requestId string) error {
+	open, err := app.FindAllRecords(Collection, dbx.HashExp{
+		"request": requestId,
+		"status":  []any{StatusDraft, StatusSent},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, invoice := range open {
+		invoice.Set("status", StatusCancelled)
+		if err := app.Save(invoice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Find returns the invoice with the given record id or invoice number.
+func Find(app core.App, value string) (*core.Record, error) {
+	if invoice, err := app.FindRecordById(Collection, value); err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return invoice, err
+	}
+
+	return app.FindFirstRecordByData(Collection, "number", value)
+}
+
+// NextNumber returns the invoice number following the highest existing one, e.g. "INV-000043".
+func NextNumber(app core.App) (string, error) {
+	last := &core.Record{}
+
+	err := app.RecordQuery(Collection).OrderBy("number DESC").Limit(1).One(last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Sprintf(numberFormat, 1), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(last.GetString("number"), "INV-"))
+	if err != nil {
+		return "", fmt.Errorf("unexpected invoice number %q: %w", last.GetString("number"), err)
+	}
+
+	return fmt.Sprintf(numberFormat, n+1), nil
+}
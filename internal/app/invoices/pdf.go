@@ -0,0 +1,120 @@
+package invoices
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/pbext/pdf"
+)
+
+// ErrNoPayeeAccount is returned by [GiroCode] while no bank account is
+// configured in the settings.
+var ErrNoPayeeAccount = errors.New("no payee bank account configured")
+
+// GiroCode returns the SEPA transfer paying invoice to the bank account of
+// the settings, with the invoice number as reference.
+func GiroCode(app core.App, invoice *core.Record) (pdf.GiroCode, error) {
+	s, err := settings.Load(app)
+	if err != nil {
+		return pdf.GiroCode{}, err
+	}
+	if s.PayeeIBAN == "" {
+		return pdf.GiroCode{}, ErrNoPayeeAccount
+	}
+
+	if currency := invoice.GetString("currency"); !strings.EqualFold(currency, "EUR") {
+		return pdf.GiroCode{}, fmt.Errorf("invoice %s is in %s, SEPA transfers are EUR only", invoice.GetString("number"), currency)
+	}
+
+	return pdf.GiroCode{
+		Name:       s.PayeeName,
+		IBAN:       s.PayeeIBAN,
+		BIC:        s.PayeeBIC,
+		Amount:     invoice.GetFloat("amount"),
+		Remittance: invoice.GetString("number"),
+	}, nil
+}
+
+// RenderPDF writes the invoice document to w. It includes the bank details
+// and GiroCode while a payee account is configured.
+func RenderPDF(app core.App, invoice *core.Record, w io.Writer) error {
+	request, err := app.FindRecordById(requestsCollection, invoice.GetString("request"))
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Locker rental %s – %s", request.GetString("school_year"), request.GetString("student_name"))
+	if class := request.GetString("student_class"); class != "" {
+		description += " (" + class + ")"
+	}
+	if label := lockerLabel(app, request.Id); label != "" {
+		description += ", locker " + label
+	}
+
+	doc := pdf.Invoice{
+		Number:    invoice.GetString("number"),
+		Recipient: append([]string{request.GetString("requester_name")}, strings.Split(strings.TrimSpace(request.GetString("requester_address")), "\n")...),
+		IssuedAt:  clock.Now(app).In(clock.Location()),
+		DueAt:     invoice.GetDateTime("due_at").Time().In(clock.Location()),
+		Items:     []pdf.InvoiceItem{{Description: description, Amount: invoice.GetFloat("amount")}},
+		Currency:  invoice.GetString("currency"),
+	}
+
+	code, err := GiroCode(app, invoice)
+	switch {
+	case err == nil:
+		doc.Issuer = code.Name
+		doc.Payment = &code
+	case !errors.Is(err, ErrNoPayeeAccount):
+		return err
+	}
+
+	return pdf.WriteInvoice(w, doc)
+}
+
+// attachPDF renders the document of a sent invoice without PDF, e.g. when
+// staff send a draft. Failures are logged, the invoice is saved anyway.
+func attachPDF(app core.App, invoice *core.Record) {
+	if invoice.GetString("status") != StatusSent || invoice.GetString("pdf") != "" {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := RenderPDF(app, invoice, &buf); err != nil {
+		app.Logger().Error("failed to render the invoice pdf", "invoice", invoice.GetString("number"), "error", err)
+		return
+	}
+
+	file, err := filesystem.NewFileFromBytes(buf.Bytes(), invoice.GetString("number")+".pdf")
+	if err != nil {
+		app.Logger().Error("failed to attach the invoice pdf", "invoice", invoice.GetString("number"), "error", err)
+		return
+	}
+
+	invoice.Set("pdf", file)
+}
+
+// lockerLabel returns the label of the locker assigned or reserved for the
+// request, empty if there is none.
+func lockerLabel(app core.App, requestId string) string {
+	for _, collection := range []string{assignmentsCollection, reservationsCollection} {
+		record, err := app.FindFirstRecordByData(collection, "request", requestId)
+		if err != nil {
+			continue
+		}
+
+		if locker, err := app.FindRecordById(lockersCollection, record.GetString("locker")); err == nil {
+			return locker.GetString("label")
+		}
+	}
+
+	return ""
+}
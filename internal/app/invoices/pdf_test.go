@@ -0,0 +1,130 @@
+package invoices_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/pbext/pdf"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestInvoiceGiroCode(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	request, _ := testutil.ReservedRequest(t, app)
+
+	// without a bank account the invoices carry no payment details
+	plain := testutil.CreateInvoice(t, app, request, nil)
+	if _, err := invoices.GiroCode(app, plain); !errors.Is(err, invoices.ErrNoPayeeAccount) {
+		t.Fatalf("expected ErrNoPayeeAccount, got %v", err)
+	}
+	if plain.GetString("pdf") == "" {
+		t.Fatal("expected the sent invoice to get a pdf")
+	}
+	if document := readInvoicePDF(t, app, plain); strings.Contains(document, "IBAN") {
+		t.Fatal("expected no payment details without a bank account")
+	}
+
+	record, err := settings.FindRecord(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Set("payee_name", "Förderverein Musterschule e.V.")
+	record.Set("payee_iban", "DE89 3704 0044 0532 0130 00")
+	record.Set("payee_bic", "COBADEFFXXX")
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	invoice := testutil.CreateInvoice(t, app, request, map[string]any{"amount": 25.5, "status": invoices.StatusDraft})
+	if invoice.GetString("pdf") != "" {
+		t.Fatal("expected drafts to stay without pdf")
+	}
+	invoice.Set("status", invoices.StatusSent)
+	if err := app.Save(invoice); err != nil {
+		t.Fatal(err)
+	}
+	invoice = testutil.Reload(t, app, invoice)
+
+	giro, err := invoices.GiroCode(app, invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := giro.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "BCD\n002\n1\nSCT\nCOBADEFFXXX\nFörderverein Musterschule e.V.\nDE89370400440532013000\nEUR25.50\n\n\n" + invoice.GetString("number")
+	testutil.AssertString(t, "GiroCode payload", payload, want)
+
+	document := readInvoicePDF(t, app, invoice)
+	for _, want := range []string{
+		"(Invoice " + invoice.GetString("number") + ")",
+		"(IBAN: DE89 3704 0044 0532 0130 00)",
+		"(Reference: " + invoice.GetString("number") + ")",
+		"(25.50 EUR)",
+		" re\n",
+	} {
+		if !strings.Contains(document, want) {
+			t.Fatalf("expected the invoice pdf to contain %q", want)
+		}
+	}
+
+	code, err := giro.QRCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded bytes.Buffer
+	if err := code.WritePNG(&encoded, 4); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Bounds().Dx(), (code.Size+8)*4; got != want {
+		t.Fatalf("expected a %d pixel wide image, got %d", want, got)
+	}
+
+	// the payee account is validated before encoding
+	giro.IBAN = "DE89 3704 0044 0532 0130 01"
+	if _, err := giro.Payload(); err == nil {
+		t.Fatal("expected an invalid IBAN check sum to be rejected")
+	}
+	if _, err := pdf.EncodeQR(make([]byte, 332)); !errors.Is(err, pdf.ErrQRTooLong) {
+		t.Fatalf("expected ErrQRTooLong, got %v", err)
+	}
+}
+
+func readInvoicePDF(t *testing.T, app core.App, invoice *core.Record) string {
+	t.Helper()
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(path.Join(invoice.BaseFilesPath(), invoice.GetString("pdf")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,345 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+const (
+	zonesCollection   = "zones"
+	lockersCollection = "lockers"
+)
+
+// ErrConflicts is returned by [Apply] when the layout cannot be applied as-is.
+var ErrConflicts = errors.New("layout has conflicts")
+
+// ErrTooManyLockers is returned by [Plan] and [Apply] for layouts with more
+// than maxLockers lockers.
+var ErrTooManyLockers = errors.New("layout has too many lockers")
+
+// Diff summarizes the changes a layout applies to the existing zones and lockers.
+type Diff struct {
+	ZonesCreated       []ZoneChange   `json:"zones_created"`
+	ZonesUpdated       []ZoneChange   `json:"zones_updated"`
+	LockersCreated     []LockerChange `json:"lockers_created"`
+	LockersUpdated     []LockerChange `json:"lockers_updated"`
+	LockersUnchanged   int            `json:"lockers_unchanged"`
+	LockersNotInLayout int            `json:"lockers_not_in_layout"`
+	Conflicts          []string       `json:"conflicts"`
+	Applied            bool           `json:"applied"`
+}
+
+// HasConflicts reports whether the layout contains blocking problems.
+func (d *Diff) HasConflicts() bool {
+	return len(d.Conflicts) > 0
+}
+
+// ZoneChange describes a zone that is created or updated.
+type ZoneChange struct {
+	Name    string   `json:"name"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+// LockerChange describes a locker that is created or updated.
+type LockerChange struct {
+	Number  int      `json:"number"`
+	Zone    string   `json:"zone"`
+	Status  string   `json:"status"`
+	Note    string   `json:"note"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+type plannedLocker struct {
+	record *core.Record
+	zone   *core.Record
+}
+
+type plan struct {
+	diff    *Diff
+	zones   []*core.Record
+	lockers []plannedLocker
+}
+
+// Plan validates the layout against the existing data and reports the resulting diff
+// without changing anything.
+func Plan(app core.App, l *Layout) (*Diff, error) {
+	p, err := buildPlan(app, l)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.diff, nil
+}
+
+// Apply validates the layout and creates or updates the zones and lockers in a single transaction.
+//
+// Nothing is written when the layout has conflicts; the returned diff lists them
+// together with [ErrConflicts].
+func Apply(app core.App, l *Layout) (*Diff, error) {
+	var diff *Diff
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		p, err := buildPlan(txApp, l)
+		if err != nil {
+			return err
+		}
+
+		diff = p.diff
+		if diff.HasConflicts() {
+			return ErrConflicts
+		}
+
+		for _, zone := range p.zones {
+			if err := txApp.Save(zone); err != nil {
+				return fmt.Errorf("failed to save zone %q: %w", zone.GetString("name"), err)
+			}
+		}
+
+		for _, locker := range p.lockers {
+			locker.record.Set("zone", locker.zone.Id)
+			if err := txApp.Save(locker.record); err != nil {
+				return fmt.Errorf("failed to save locker %d: %w", locker.record.GetInt("number"), err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return diff, err
+	}
+
+	diff.Applied = true
+
+	return diff, nil
+}
+
+func buildPlan(app core.App, l *Layout) (*plan, error) {
+	zonesCol, err := app.FindCollectionByNameOrId(zonesCollection)
+	if err != nil {
+		return nil, err
+	}
+	lockersCol, err := app.FindCollectionByNameOrId(lockersCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	existingZones, err := app.FindAllRecords(zonesCollection)
+	if err != nil {
+		return nil, err
+	}
+	zonesByName := map[string]*core.Record{}
+	zonesById := map[string]*core.Record{}
+	for _, zone := range existingZones {
+		zonesById[zone.Id] = zone
+		key := strings.ToLower(zone.GetString("name"))
+		if _, ok := zonesByName[key]; !ok {
+			zonesByName[key] = zone
+		}
+	}
+
+	s, err := settings.Load(app)
+	if err != nil {
+		return nil, err
+	}
+
+	// numbers are unique per school with global numbering and per zone otherwise
+	lockerKey := func(zoneKey string, number int) string {
+		if s.LockerNumbering == settings.NumberingZone {
+			return zoneKey + "/" + strconv.Itoa(number)
+		}
+		return strconv.Itoa(number)
+	}
+
+	existingLockers, err := app.FindAllRecords(lockersCollection)
+	if err != nil {
+		return nil, err
+	}
+	lockersByKey := map[string][]*core.Record{}
+	for _, locker := range existingLockers {
+		key := lockerKey(locker.GetString("zone"), locker.GetInt("number"))
+		lockersByKey[key] = append(lockersByKey[key], locker)
+	}
+
+	p := &plan{diff: &Diff{
+		ZonesCreated:   []ZoneChange{},
+		ZonesUpdated:   []ZoneChange{},
+		LockersCreated: []LockerChange{},
+		LockersUpdated: []LockerChange{},
+		Conflicts:      []string{},
+	}}
+
+	seenZones := map[string]bool{}
+	seenNumbers := map[string]string{}
+	touched := map[string]bool{}
+	total := 0
+
+	for _, zl := range l.Zones {
+		name := strings.TrimSpace(zl.Name)
+		key := strings.ToLower(name)
+
+		if len(name) < 2 || len(name) > 64 {
+			p.diff.Conflicts = append(p.diff.Conflicts, fmt.Sprintf("zone name %q must be between 2 and 64 characters", name))
+			continue
+		}
+		if seenZones[key] {
+			p.diff.Conflicts = append(p.diff.Conflicts, fmt.Sprintf("zone %q is listed more than once", name))
+			continue
+		}
+		seenZones[key] = true
+
+		zone := zonesByName[key]
+		if zone == nil {
+			zone = core.NewRecord(zonesCol)
+			zone.Set("name", name)
+			zone.Set("description", zl.Description)
+			zone.Set("class_tags", zl.ClassTags)
+			p.zones = append(p.zones, zone)
+			p.diff.ZonesCreated = append(p.diff.ZonesCreated, ZoneChange{Name: name})
+		} else {
+			changes := []string{}
+			if zl.Description != "" && zl.Description != zone.GetString("description") {
+				changes = append(changes, fmt.Sprintf("description: %q -> %q", zone.GetString("description"), zl.Description))
+				zone.Set("description", zl.Description)
+			}
+			if len(zl.ClassTags) > 0 {
+				current := []string{}
+				_ = zone.UnmarshalJSONField("class_tags", &current)
+				if !slices.Equal(current, zl.ClassTags) {
+					changes = append(changes, fmt.Sprintf("class_tags: %v -> %v", current, zl.ClassTags))
+					zone.Set("class_tags", zl.ClassTags)
+				}
+			}
+			if len(changes) > 0 {
+				p.zones = append(p.zones, zone)
+				p.diff.ZonesUpdated = append(p.diff.ZonesUpdated, ZoneChange{Name: name, Changes: changes})
+			}
+		}
+
+		for _, lr := range zl.Lockers {
+			first, last, err := lr.Bounds()
+			if err != nil {
+				p.diff.Conflicts = append(p.diff.Conflicts, fmt.Sprintf("zone %q: %v", name, err))
+				continue
+			}
+
+			total += last - first + 1
+			if total > maxLockers {
+				return nil, fmt.Errorf("%w: the maximum is %d", ErrTooManyLockers, maxLockers)
+			}
+
+			for number := first; number <= last; number++ {
+				seenKey := lockerKey(key, number)
+				if other, ok := seenNumbers[seenKey]; ok {
+					p.diff.Conflicts = append(p.diff.Conflicts, fmt.Sprintf("locker %d is listed in %q and %q", number, other, name))
+					continue
+				}
+				seenNumbers[seenKey] = name
+
+				existing := []*core.Record{}
+				if zone.Id != "" || s.LockerNumbering != settings.NumberingZone {
+					existing = lockersByKey[lockerKey(zone.Id, number)]
+				}
+				for _, record := range existing {
+					touched[record.Id] = true
+				}
+
+				p.planLocker(lockersCol, zone, zonesById, existing, number, lr)
+			}
+		}
+	}
+
+	p.diff.LockersNotInLayout = len(existingLockers) - len(touched)
+
+	sort.Strings(p.diff.Conflicts)
+
+	return p, nil
+}
+
+func (p *plan) planLocker(
+	lockersCol *core.Collection,
+	zone *core.Record,
+	zonesById map[string]*core.Record,
+	existing []*core.Record,
+	number int,
+	lr LockerRange,
+) {
+	zoneName := zone.GetString("name")
+
+	if len(existing) > 1 {
+		p.diff.Conflicts = append(p.diff.Conflicts, fmt.Sprintf("locker %d already exists %d times", number, len(existing)))
+		return
+	}
+
+	if len(existing) == 0 {
+		status := "free"
+		if lr.Maintenance {
+			status = "maintenance"
+		}
+
+		record := core.NewRecord(lockersCol)
+		record.Set("number", number)
+		record.Set("status", status)
+		record.Set("note", lr.Note)
+
+		p.lockers = append(p.lockers, plannedLocker{record: record, zone: zone})
+		p.diff.LockersCreated = append(p.diff.LockersCreated, LockerChange{
+			Number: number,
+			Zone:   zoneName,
+			Status: status,
+			Note:   lr.Note,
+		})
+		return
+	}
+
+	record := existing[0]
+	status := record.GetString("status")
+	changes := []string{}
+
+	if record.GetString("zone") != zone.Id {
+		from := record.GetString("zone")
+		if current, ok := zonesById[from]; ok {
+			from = current.GetString("name")
+		}
+		changes = append(changes, fmt.Sprintf("zone: %q -> %q", from, zoneName))
+	}
+
+	if record.GetString("note") != lr.Note {
+		changes = append(changes, fmt.Sprintf("note: %q -> %q", record.GetString("note"), lr.Note))
+		record.Set("note", lr.Note)
+	}
+
+	switch {
+	case lr.Maintenance && status != "maintenance":
+		if status == "reserved" || status == "occupied" {
+			p.diff.Conflicts = append(p.diff.Conflicts, fmt.Sprintf("locker %d is %s and cannot be flagged for maintenance", number, status))
+			return
+		}
+		changes = append(changes, fmt.Sprintf("status: %q -> %q", status, "maintenance"))
+		record.Set("status", "maintenance")
+	case !lr.Maintenance && status == "maintenance":
+		changes = append(changes, fmt.Sprintf("status: %q -> %q", status, "free"))
+		record.Set("status", "free")
+	}
+
+	if len(changes) == 0 {
+		p.diff.LockersUnchanged++
+		return
+	}
+
+	p.lockers = append(p.lockers, plannedLocker{record: record, zone: zone})
+	p.diff.LockersUpdated = append(p.diff.LockersUpdated, LockerChange{
+		Number:  number,
+		Zone:    zoneName,
+		Status:  record.GetString("status"),
+		Note:    lr.Note,
+		Changes: changes,
+	})
+}
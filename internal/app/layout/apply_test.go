@@ -0,0 +1,204 @@
+package layout_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/layout"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	north := testutil.CreateZone(t, app, map[string]any{"name": "North", "description": "ground floor"})
+	one := testutil.CreateLocker(t, app, north, map[string]any{"number": 2001})
+	two := testutil.CreateLocker(t, app, north, map[string]any{"number": 2002})
+	testutil.CreateLocker(t, app, north, map[string]any{"number": 2005})
+
+	zones, err := app.CountRecords("zones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockers, err := app.CountRecords("lockers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &layout.Layout{Zones: []layout.Zone{
+		{Name: "north", Description: "first floor", Lockers: []layout.LockerRange{
+			{Numbers: "2001"},
+			{Numbers: "2002", Maintenance: true},
+			{Numbers: "2003", Note: "next to the stairs"},
+		}},
+		{Name: "South", Lockers: []layout.LockerRange{{Numbers: "2010-2011"}}},
+	}}
+
+	diff, err := layout.Plan(app, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.HasConflicts() || diff.Applied {
+		t.Fatalf("unexpected plan %+v", diff)
+	}
+	if len(diff.ZonesCreated) != 1 || diff.ZonesCreated[0].Name != "South" {
+		t.Fatalf("expected the South zone to be created, got %+v", diff.ZonesCreated)
+	}
+	if len(diff.ZonesUpdated) != 1 || diff.ZonesUpdated[0].Changes[0] != `description: "ground floor" -> "first floor"` {
+		t.Fatalf("expected the North description to change, got %+v", diff.ZonesUpdated)
+	}
+	if len(diff.LockersCreated) != 3 || diff.LockersCreated[0].Number != 2003 || diff.LockersCreated[0].Note != "next to the stairs" {
+		t.Fatalf("expected lockers 2003, 2010 and 2011 to be created, got %+v", diff.LockersCreated)
+	}
+	if len(diff.LockersUpdated) != 1 || diff.LockersUpdated[0].Number != 2002 || diff.LockersUpdated[0].Changes[0] != `status: "free" -> "maintenance"` {
+		t.Fatalf("expected locker 2002 to be flagged for maintenance, got %+v", diff.LockersUpdated)
+	}
+	if diff.LockersUnchanged != 1 || diff.LockersNotInLayout != int(lockers)-2 {
+		t.Fatalf("unexpected locker counts %+v", diff)
+	}
+
+	// the plan changes nothing
+	testutil.AssertCount(t, app, "zones", nil, zones)
+	testutil.AssertCount(t, app, "lockers", nil, lockers)
+	testutil.AssertString(t, "planned locker status", testutil.Reload(t, app, two).GetString("status"), "free")
+
+	diff, err = layout.Apply(app, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Applied {
+		t.Fatal("expected the layout to be applied")
+	}
+
+	testutil.AssertCount(t, app, "zones", nil, zones+1)
+	testutil.AssertCount(t, app, "lockers", nil, lockers+3)
+	testutil.AssertString(t, "zone description", testutil.Reload(t, app, north).GetString("description"), "first floor")
+	testutil.AssertString(t, "unchanged locker status", testutil.Reload(t, app, one).GetString("status"), "free")
+	testutil.AssertString(t, "maintenance locker status", testutil.Reload(t, app, two).GetString("status"), "maintenance")
+
+	south, err := app.FindFirstRecordByData("zones", "name", "South")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertCount(t, app, "lockers", dbx.HashExp{"zone": south.Id}, 2)
+	testutil.AssertCount(t, app, "lockers", dbx.HashExp{"zone": north.Id, "number": 2003, "note": "next to the stairs"}, 1)
+
+	// applying the layout again changes nothing
+	diff, err = layout.Apply(app, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.ZonesCreated)+len(diff.ZonesUpdated)+len(diff.LockersCreated)+len(diff.LockersUpdated) != 0 || diff.LockersUnchanged != 5 {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestApplyConflicts(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	north := testutil.CreateZone(t, app, map[string]any{"name": "North"})
+	testutil.CreateLocker(t, app, north, map[string]any{"number": 2001})
+	occupied := testutil.CreateLocker(t, app, north, map[string]any{"number": 2002, "status": "occupied"})
+
+	zones, err := app.CountRecords("zones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockers, err := app.CountRecords("lockers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// numbers are unique per school with the default global numbering
+	l := &layout.Layout{Zones: []layout.Zone{
+		{Name: "North", Description: "first floor", Lockers: []layout.LockerRange{
+			{Numbers: "2001"},
+			{Numbers: "2002", Maintenance: true},
+			{Numbers: "2003"},
+		}},
+		{Name: "South", Lockers: []layout.LockerRange{{Numbers: "2001"}, {Numbers: "2020"}}},
+	}}
+
+	diff, err := layout.Apply(app, l)
+	if !errors.Is(err, layout.ErrConflicts) {
+		t.Fatalf("expected ErrConflicts, got %v", err)
+	}
+	want := []string{
+		`locker 2001 is listed in "North" and "South"`,
+		"locker 2002 is occupied and cannot be flagged for maintenance",
+	}
+	if diff == nil || len(diff.Conflicts) != len(want) || diff.Applied {
+		t.Fatalf("expected the conflicts %q, got %+v", want, diff)
+	}
+	for i := range want {
+		testutil.AssertString(t, "conflict", diff.Conflicts[i], want[i])
+	}
+
+	// nothing is written
+	testutil.AssertCount(t, app, "zones", nil, zones)
+	testutil.AssertCount(t, app, "lockers", nil, lockers)
+	testutil.AssertString(t, "zone description", testutil.Reload(t, app, north).GetString("description"), "")
+	testutil.AssertString(t, "occupied locker status", testutil.Reload(t, app, occupied).GetString("status"), "occupied")
+}
+
+func TestApplyRollsBackOnFailure(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	north := testutil.CreateZone(t, app, map[string]any{"name": "North"})
+	two := testutil.CreateLocker(t, app, north, map[string]any{"number": 2002})
+
+	zones, err := app.CountRecords("zones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockers, err := app.CountRecords("lockers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last locker fails to save after everything else was written
+	failure := errors.New("disk full")
+	app.OnRecordCreate("lockers").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetInt("number") == 2011 {
+			return failure
+		}
+		return e.Next()
+	})
+
+	l := &layout.Layout{Zones: []layout.Zone{
+		{Name: "North", Description: "first floor", Lockers: []layout.LockerRange{{Numbers: "2002", Maintenance: true}}},
+		{Name: "South", Lockers: []layout.LockerRange{{Numbers: "2010-2011"}}},
+	}}
+
+	diff, err := layout.Apply(app, l)
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the save failure, got %v", err)
+	}
+	if diff.Applied {
+		t.Fatal("expected the layout not to be applied")
+	}
+
+	testutil.AssertCount(t, app, "zones", nil, zones)
+	testutil.AssertCount(t, app, "lockers", nil, lockers)
+	testutil.AssertString(t, "zone description", testutil.Reload(t, app, north).GetString("description"), "")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, two).GetString("status"), "free")
+}
+
+func TestPlanTooManyLockers(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	l := &layout.Layout{Zones: []layout.Zone{
+		{Name: "Zone A", Lockers: []layout.LockerRange{{Numbers: "1-15000"}}},
+		{Name: "Zone B", Lockers: []layout.LockerRange{{Numbers: "1-15000"}}},
+	}}
+
+	if _, err := layout.Plan(app, l); !errors.Is(err, layout.ErrTooManyLockers) {
+		t.Fatalf("expected ErrTooManyLockers, got %v", err)
+	}
+	if _, err := layout.Apply(app, l); !errors.Is(err, layout.ErrTooManyLockers) {
+		t.Fatalf("expected ErrTooManyLockers, got %v", err)
+	}
+}
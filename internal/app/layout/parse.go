@@ -0,0 +1,194 @@
+package layout
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Supported layout file formats.
+const (
+	FormatCSV  = "csv"
+	FormatYAML = "yaml"
+)
+
+// maxLockers guards against accidental huge ranges such as "1-1000000".
+const maxLockers = 20000
+
+// Layout describes the desired zones and lockers of a school.
+type Layout struct {
+	Zones []Zone `yaml:"zones" json:"zones"`
+}
+
+// Zone describes a single zone and its locker ranges.
+type Zone struct {
+	Name        string        `yaml:"name" json:"name"`
+	Description string        `yaml:"description" json:"description"`
+	ClassTags   []string      `yaml:"class_tags" json:"class_tags"`
+	Lockers     []LockerRange `yaml:"lockers" json:"lockers"`
+}
+
+// LockerRange describes a block of consecutive locker numbers, e.g. "1-120" or "42".
+type LockerRange struct {
+	Numbers     string `yaml:"numbers" json:"numbers"`
+	Note        string `yaml:"note" json:"note"`
+	Maintenance bool   `yaml:"maintenance" json:"maintenance"`
+}
+
+// Bounds parses the range expression and returns its first and last number.
+func (r LockerRange) Bounds() (int, int, error) {
+	value := strings.TrimSpace(r.Numbers)
+	from, to, isRange := strings.Cut(value, "-")
+
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid locker range %q", r.Numbers)
+	}
+
+	last := first
+	if isRange {
+		last, err = strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid locker range %q", r.Numbers)
+		}
+	}
+
+	if first < 1 || last < first {
+		return 0, 0, fmt.Errorf("invalid locker range %q", r.Numbers)
+	}
+
+	return first, last, nil
+}
+
+// Parse reads a layout in the given format.
+func Parse(r io.Reader, format string) (*Layout, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return ParseCSV(r)
+	case FormatYAML, "yml":
+		return ParseYAML(r)
+	default:
+		return nil, fmt.Errorf("unsupported layout format %q", format)
+	}
+}
+
+// FormatFromFilename guesses the layout format from a file extension.
+func FormatFromFilename(name string) string {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+		return FormatYAML
+	}
+	return FormatCSV
+}
+
+// ParseYAML reads a YAML layout:
+//
+//	zones:
+//	  - name: Zone A
+//	    description: Ground floor left wing
+//	    class_tags: [5th, 6th]
+//	    lockers:
+//	      - numbers: 1-120
+//	      - numbers: 121-124
+//	        maintenance: true
+//	        note: Broken hinges
+func ParseYAML(r io.Reader) (*Layout, error) {
+	layout := &Layout{}
+	if err := yaml.NewDecoder(r).Decode(layout); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse yaml layout: %w", err)
+	}
+
+	return layout, nil
+}
+
+// ParseCSV reads a CSV layout with a header row.
+//
+// The zone and numbers columns are required; note, maintenance, description and
+// class_tags (separated by ";") are optional. Rows of the same zone are merged.
+func ParseCSV(r io.Reader) (*Layout, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"zone", "numbers"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing required csv column %q", required)
+		}
+	}
+
+	layout := &Layout{}
+	zoneIndexes := map[string]int{}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		name := get("zone")
+		if name == "" {
+			return nil, fmt.Errorf("line %d: missing zone", line)
+		}
+
+		maintenance := false
+		if value := get("maintenance"); value != "" {
+			maintenance, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid maintenance flag %q", line, value)
+			}
+		}
+
+		key := strings.ToLower(name)
+		idx, ok := zoneIndexes[key]
+		if !ok {
+			idx = len(layout.Zones)
+			zoneIndexes[key] = idx
+			layout.Zones = append(layout.Zones, Zone{Name: name})
+		}
+
+		zone := &layout.Zones[idx]
+		if description := get("description"); description != "" {
+			zone.Description = description
+		}
+		if tags := get("class_tags"); tags != "" {
+			zone.ClassTags = nil
+			for _, tag := range strings.Split(tags, ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					zone.ClassTags = append(zone.ClassTags, tag)
+				}
+			}
+		}
+
+		zone.Lockers = append(zone.Lockers, LockerRange{
+			Numbers:     get("numbers"),
+			Note:        get("note"),
+			Maintenance: maintenance,
+		})
+	}
+
+	return layout, nil
+}
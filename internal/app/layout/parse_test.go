@@ -0,0 +1,128 @@
+package layout_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jryannel/spindit/internal/app/layout"
+)
+
+func TestParseCSV(t *testing.T) {
+	csv := "Zone,Numbers,Note,Maintenance,Description,Class_Tags\n" +
+		"Zone A,1-120,,,Ground floor,5th; 6th\n" +
+		"Zone B,1-10,,,,\n" +
+		"zone a,121-124,Broken hinges,true,,\n"
+
+	got, err := layout.Parse(strings.NewReader(csv), layout.FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &layout.Layout{Zones: []layout.Zone{
+		{
+			Name:        "Zone A",
+			Description: "Ground floor",
+			ClassTags:   []string{"5th", "6th"},
+			Lockers: []layout.LockerRange{
+				{Numbers: "1-120"},
+				{Numbers: "121-124", Note: "Broken hinges", Maintenance: true},
+			},
+		},
+		{Name: "Zone B", Lockers: []layout.LockerRange{{Numbers: "1-10"}}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	for name, csv := range map[string]string{
+		"missing numbers column": "zone,note\nZone A,\n",
+		"missing zone":           "zone,numbers\n,1-10\n",
+		"invalid maintenance":    "zone,numbers,maintenance\nZone A,1-10,maybe\n",
+	} {
+		if _, err := layout.ParseCSV(strings.NewReader(csv)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	yaml := `
+zones:
+  - name: Zone A
+    description: Ground floor left wing
+    class_tags: [5th, 6th]
+    lockers:
+      - numbers: 1-120
+      - numbers: 121-124
+        maintenance: true
+        note: Broken hinges
+`
+	got, err := layout.Parse(strings.NewReader(yaml), "yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &layout.Layout{Zones: []layout.Zone{{
+		Name:        "Zone A",
+		Description: "Ground floor left wing",
+		ClassTags:   []string{"5th", "6th"},
+		Lockers: []layout.LockerRange{
+			{Numbers: "1-120"},
+			{Numbers: "121-124", Note: "Broken hinges", Maintenance: true},
+		},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	if _, err := layout.Parse(strings.NewReader("zones: [\n"), layout.FormatYAML); err == nil {
+		t.Fatal("expected invalid yaml to fail")
+	}
+	if _, err := layout.Parse(strings.NewReader(yaml), "json"); err == nil {
+		t.Fatal("expected an unsupported format to fail")
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	for name, want := range map[string]string{
+		"layout.yaml": layout.FormatYAML,
+		"LAYOUT.YML":  layout.FormatYAML,
+		"layout.csv":  layout.FormatCSV,
+		"layout":      layout.FormatCSV,
+	} {
+		if got := layout.FormatFromFilename(name); got != want {
+			t.Errorf("FormatFromFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLockerRangeBounds(t *testing.T) {
+	for _, tc := range []struct {
+		numbers     string
+		first, last int
+		wantErr     bool
+	}{
+		{numbers: "42", first: 42, last: 42},
+		{numbers: "1-120", first: 1, last: 120},
+		{numbers: " 5 - 7 ", first: 5, last: 7},
+		{numbers: "0", wantErr: true},
+		{numbers: "10-5", wantErr: true},
+		{numbers: "1-", wantErr: true},
+		{numbers: "a-b", wantErr: true},
+		{numbers: "", wantErr: true},
+	} {
+		first, last, err := layout.LockerRange{Numbers: tc.numbers}.Bounds()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Bounds(%q): expected an error", tc.numbers)
+			}
+			continue
+		}
+		if err != nil || first != tc.first || last != tc.last {
+			t.Errorf("Bounds(%q) = %d, %d, %v; want %d, %d", tc.numbers, first, last, err, tc.first, tc.last)
+		}
+	}
+}
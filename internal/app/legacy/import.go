@@ -0,0 +1,530 @@
+package legacy
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/reservations"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+// Row outcomes.
+const (
+	StatusImport   = "import"   // the row is (or would be) imported
+	StatusImported = "imported" // the row was imported
+	StatusExists   = "exists"   // the assignment was imported before
+	StatusError    = "error"    // the row cannot be matched
+)
+
+// Placeholders for request contact fields the legacy export does not contain
+// and the family profile does not provide either.
+const (
+	unknownAddress = "unknown (legacy import)"
+	unknownPhone   = "0000000"
+)
+
+const (
+	usersCollection       = "users"
+	zonesCollection       = "zones"
+	assignmentsCollection = "assignments"
+)
+
+var (
+	schoolYearPattern = regexp.MustCompile(`^[0-9]{4}/[0-9]{2}$`)
+	phonePattern      = regexp.MustCompile(`^[0-9+()-]{7,}$`)
+)
+
+// Options configures the invoices created for imported assignments.
+type Options struct {
+	// Amount is the invoice amount of rows without an amount column value.
+	Amount float64
+	// Currency is the invoice currency, EUR by default.
+	Currency string
+}
+
+// RowResult is the outcome of a single legacy row.
+type RowResult struct {
+	Row
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	NewUser bool   `json:"new_user,omitempty"`
+	Request string `json:"request,omitempty"`
+}
+
+// Report summarizes a legacy import.
+type Report struct {
+	Rows         []RowResult `json:"rows"`
+	Imported     int         `json:"imported"`
+	Existing     int         `json:"existing"`
+	Errors       int         `json:"errors"`
+	UsersCreated int         `json:"users_created"`
+	Applied      bool        `json:"applied"`
+}
+
+// HasErrors reports whether some rows cannot be imported.
+func (r *Report) HasErrors() bool {
+	return r.Errors > 0
+}
+
+// plannedRow is a validated row ready to be imported.
+type plannedRow struct {
+	result *RowResult
+	email  string
+	user   *core.Record
+	locker *core.Record
+	paid   bool
+	paidAt time.Time
+	amount float64
+}
+
+// Plan matches the rows against the existing users and lockers and reports
+// the outcome of every row without changing anything.
+func Plan(app core.App, rows []Row, opts Options) (*Report, error) {
+	report, _, err := plan(app, rows, opts)
+
+	return report, err
+}
+
+// Apply imports the valid rows. Each row is imported in its own transaction,
+// so rows that fail are reported without affecting the others.
+//
+// Users are created with a random password and without invitation email;
+// families sign in through the password reset. Every row goes through the
+// regular payment path: the request, assignment and invoice are created as
+// for a reserved request, paid rows are then confirmed with
+// [invoices.MarkPaid] at their paid_at date, or else the start of their
+// school year, and unpaid rows keep a reservation until the deadline.
+func Apply(app core.App, rows []Row, opts Options) (*Report, error) {
+	report, planned, err := plan(app, rows, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// accounts created by an earlier row of the same family
+	created := map[string]*core.Record{}
+	report.UsersCreated = 0
+
+	for _, p := range planned {
+		if p.user == nil {
+			p.user = created[p.email]
+		}
+		newUser := p.user == nil
+
+		err := app.RunInTransaction(func(txApp core.App) error {
+			return importRow(txApp, p, opts)
+		})
+		if err != nil {
+			if newUser {
+				p.user = nil
+			}
+			report.Imported--
+			report.Errors++
+			p.result.Status = StatusError
+			p.result.Error = err.Error()
+			p.result.Request = ""
+			continue
+		}
+
+		p.result.Status = StatusImported
+		if newUser {
+			created[p.email] = p.user
+			report.UsersCreated++
+		}
+	}
+
+	report.Applied = true
+
+	return report, nil
+}
+
+// WriteErrors writes the rows that could not be imported as CSV, with the
+// original columns and the reason, so they can be fixed and imported again.
+func WriteErrors(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"line", "email", "name", "phone", "address", "student", "class", "locker", "zone", "paid", "year", "amount", "paid_at", "error"}); err != nil {
+		return err
+	}
+
+	for _, r := range report.Rows {
+		if r.Status != StatusError {
+			continue
+		}
+		err := writer.Write([]string{
+			strconv.Itoa(r.Line), r.Email, r.Name, r.Phone, r.Address, r.Student, r.Class,
+			r.Locker, r.Zone, r.Paid, r.Year, r.Amount, r.PaidAt, r.Error,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func plan(app core.App, rows []Row, opts Options) (*Report, []*plannedRow, error) {
+	report := &Report{Rows: make([]RowResult, len(rows))}
+	planned := []*plannedRow{}
+
+	newUsers := map[string]bool{}
+	lockersInFile := map[string]int{}
+	studentsInFile := map[string]int{}
+
+	for i, row := range rows {
+		result := &report.Rows[i]
+		result.Row = row
+
+		p, err := planRow(app, row, opts, result)
+		if err == nil && p != nil {
+			if line, ok := lockersInFile[p.locker.Id]; ok {
+				err = fmt.Errorf("locker %s is already listed on line %d", p.locker.GetString("label"), line)
+			}
+
+			key := p.email + "/" + row.Year + "/" + requests.StudentKey(row.Student)
+			if line, ok := studentsInFile[key]; ok && err == nil {
+				err = fmt.Errorf("student %q of %s is already listed on line %d", row.Student, p.email, line)
+			}
+
+			if err == nil {
+				lockersInFile[p.locker.Id] = row.Line
+				studentsInFile[key] = row.Line
+			}
+		}
+
+		switch {
+		case err != nil:
+			var fatal fatalError
+			if errors.As(err, &fatal) {
+				return nil, nil, fatal.err
+			}
+			result.Status = StatusError
+			result.Error = err.Error()
+			report.Errors++
+		case p == nil:
+			result.Status = StatusExists
+			report.Existing++
+		default:
+			result.Status = StatusImport
+			report.Imported++
+			if p.user == nil {
+				result.NewUser = true
+				if !newUsers[p.email] {
+					newUsers[p.email] = true
+					report.UsersCreated++
+				}
+			}
+			planned = append(planned, p)
+		}
+	}
+
+	return report, planned, nil
+}
+
+// fatalError wraps database errors that abort the whole import instead of
+// being reported for a single row.
+type fatalError struct {
+	err error
+}
+
+func (e fatalError) Error() string {
+	return e.err.Error()
+}
+
+// planRow validates a row. It returns nil without error when the row was
+// already imported.
+func planRow(app core.App, row Row, opts Options, result *RowResult) (*plannedRow, error) {
+	email := strings.ToLower(strings.TrimSpace(row.Email))
+	if err := is.EmailFormat.Validate(email); err != nil || email == "" {
+		return nil, fmt.Errorf("invalid email %q", row.Email)
+	}
+	if n := len([]rune(row.Student)); n < 2 || n > 120 {
+		return nil, errors.New("the student name must be between 2 and 120 characters")
+	}
+	if n := len([]rune(row.Class)); n < 1 || n > 20 {
+		return nil, errors.New("the class must be between 1 and 20 characters")
+	}
+	if !schoolYearPattern.MatchString(row.Year) {
+		return nil, fmt.Errorf("invalid school year %q, expected YYYY/YY", row.Year)
+	}
+	if row.Phone != "" && !phonePattern.MatchString(row.Phone) {
+		return nil, fmt.Errorf("invalid phone number %q", row.Phone)
+	}
+	if row.Name != "" && len([]rune(row.Name)) < 2 {
+		return nil, fmt.Errorf("invalid family name %q", row.Name)
+	}
+
+	paid, err := parsePaid(row.Paid)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := opts.Amount
+	if row.Amount != "" {
+		amount, err = strconv.ParseFloat(strings.Replace(row.Amount, ",", ".", 1), 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("invalid amount %q", row.Amount)
+		}
+	}
+	if amount <= 0 {
+		return nil, errors.New("the invoice amount is 0, add an amount column or set the default amount")
+	}
+
+	var paidAt time.Time
+	if paid {
+		if paidAt, err = parsePaidAt(row.PaidAt, row.Year); err != nil {
+			return nil, err
+		}
+	}
+
+	zoneId := ""
+	if row.Zone != "" {
+		zone, err := query.FindFirst(app, zonesCollection, query.Or(
+			query.Eq("code", strings.ToUpper(row.Zone)),
+			query.Eq("name", row.Zone),
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("unknown zone %q", row.Zone)
+		}
+		if err != nil {
+			return nil, fatalError{err}
+		}
+		zoneId = zone.Id
+	}
+
+	locker, err := lockers.ResolveLocker(app, row.Locker, zoneId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("unknown locker %q", row.Locker)
+	}
+	if err != nil {
+		return nil, fatalError{err}
+	}
+
+	user, err := app.FindAuthRecordByEmail(usersCollection, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fatalError{err}
+	}
+
+	assignment, err := query.FindFirst(app, assignmentsCollection, query.Eq("locker", locker.Id))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fatalError{err}
+	}
+	if assignment != nil {
+		holder, err := app.FindRecordById(requests.Collection, assignment.GetString("request"))
+		if err != nil {
+			return nil, fatalError{err}
+		}
+		if user != nil &&
+			holder.GetString("user") == user.Id &&
+			holder.GetString("school_year") == row.Year &&
+			holder.GetString("student_key") == requests.StudentKey(row.Student) {
+			result.Request = holder.Id
+			return nil, nil
+		}
+		return nil, fmt.Errorf("locker %s is already held by request %s", locker.GetString("label"), holder.Id)
+	}
+	if status := locker.GetString("status"); status != lockers.StatusFree {
+		return nil, fmt.Errorf("locker %s is %s", locker.GetString("label"), status)
+	}
+
+	if user != nil {
+		candidate := core.NewRecord(core.NewBaseCollection(requests.Collection))
+		candidate.Set("user", user.Id)
+		candidate.Set("school_year", row.Year)
+		candidate.Set("student_name", row.Student)
+
+		duplicate, err := requests.FindActiveDuplicate(app, candidate)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fatalError{err}
+		}
+		if duplicate != nil {
+			return nil, fmt.Errorf("the family already has the active request %s for %q in %s", duplicate.Id, row.Student, row.Year)
+		}
+	}
+
+	return &plannedRow{
+		result: result,
+		email:  email,
+		user:   user,
+		locker: locker,
+		paid:   paid,
+		paidAt: paidAt,
+		amount: amount,
+	}, nil
+}
+
+func importRow(app core.App, p *plannedRow, opts Options) error {
+	row := p.result.Row
+	now := clock.Now(app)
+
+	if p.user == nil {
+		user, err := createUser(app, p.email, row)
+		if err != nil {
+			return fmt.Errorf("failed to create the user: %w", err)
+		}
+		p.user = user
+	}
+
+	// reload the locker, an earlier row may have changed it
+	locker, err := app.FindRecordById(p.locker.Collection(), p.locker.Id)
+	if err != nil {
+		return err
+	}
+	if locker.GetString("status") != lockers.StatusFree {
+		return fmt.Errorf("locker %s is %s", locker.GetString("label"), locker.GetString("status"))
+	}
+
+	requestsCollection, err := app.FindCollectionByNameOrId(requests.Collection)
+	if err != nil {
+		return err
+	}
+
+	// The automatic reservation runs after the commit and skips requests
+	// that already hold an assignment.
+	request := core.NewRecord(requestsCollection)
+	request.Set("user", p.user.Id)
+	request.Set("requester_name", requesterName(p.user, row))
+	request.Set("requester_address", firstNonEmpty(row.Address, p.user.GetString("address"), unknownAddress))
+	request.Set("requester_phone", firstNonEmpty(row.Phone, validPhone(p.user.GetString("phone")), unknownPhone))
+	request.Set("student_name", row.Student)
+	request.Set("student_class", row.Class)
+	request.Set("school_year", row.Year)
+	request.Set("preferred_zone", locker.GetString("zone"))
+	request.Set("preferred_locker", locker.GetString("label"))
+	request.Set("status", requests.StatusReserved)
+	request.Set("submitted_at", now)
+	if err := app.Save(request); err != nil {
+		return fmt.Errorf("failed to create the request: %w", err)
+	}
+
+	locker.Set("status", lockers.StatusReserved)
+	if err := app.Save(locker); err != nil {
+		return err
+	}
+
+	assignmentsCol, err := app.FindCollectionByNameOrId(assignmentsCollection)
+	if err != nil {
+		return err
+	}
+	assignment := core.NewRecord(assignmentsCol)
+	assignment.Set("request", request.Id)
+	assignment.Set("locker", locker.Id)
+	assignment.Set("assigned_at", now)
+	if err := app.Save(assignment); err != nil {
+		return err
+	}
+
+	s, err := settings.Load(app)
+	if err != nil {
+		return err
+	}
+
+	number, err := invoices.NextNumber(app)
+	if err != nil {
+		return err
+	}
+
+	invoicesCol, err := app.FindCollectionByNameOrId(invoices.Collection)
+	if err != nil {
+		return err
+	}
+	invoice := core.NewRecord(invoicesCol)
+	invoice.Set("request", request.Id)
+	invoice.Set("number", number)
+	invoice.Set("amount", p.amount)
+	invoice.Set("currency", firstNonEmpty(opts.Currency, "EUR"))
+	invoice.Set("status", invoices.StatusSent)
+	invoice.Set("due_at", now.AddDate(0, 0, s.ReservationDays))
+	if err := app.Save(invoice); err != nil {
+		return fmt.Errorf("failed to create the invoice: %w", err)
+	}
+
+	if p.paid {
+		if err := invoices.MarkPaid(app, invoice, p.paidAt); err != nil {
+			return err
+		}
+	} else if _, err := reservations.Create(app, request, locker, now); err != nil {
+		return err
+	}
+
+	p.result.Request = request.Id
+
+	return nil
+}
+
+// createUser creates an unverified family account with a random password.
+// No verification or invitation email is sent.
+func createUser(app core.App, email string, row Row) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId(usersCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	user := core.NewRecord(collection)
+	user.SetEmail(email)
+	user.SetRandomPassword()
+	user.SetVerified(false)
+	user.Set("role", access.RoleFamily)
+	if row.Name != "" {
+		user.Set("full_name", row.Name)
+	}
+	if row.Phone != "" {
+		user.Set("phone", row.Phone)
+	}
+	if len([]rune(row.Address)) >= 4 {
+		user.Set("address", row.Address)
+	}
+
+	if err := app.Save(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func requesterName(user *core.Record, row Row) string {
+	name := firstNonEmpty(row.Name, user.GetString("full_name"))
+	if name == "" {
+		name, _, _ = strings.Cut(user.Email(), "@")
+	}
+	if len([]rune(name)) < 2 {
+		name = user.Email()
+	}
+
+	return name
+}
+
+func validPhone(phone string) string {
+	if phonePattern.MatchString(phone) {
+		return phone
+	}
+
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,170 @@
+package legacy_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/legacy"
+	"github.com/jryannel/spindit/internal/app/reservations"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestLegacyImport(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	existing := testutil.CreateUser(t, app, nil)
+	zone := testutil.CreateZone(t, app, nil)
+	paidLocker := testutil.CreateLocker(t, app, zone, nil)
+	openLocker := testutil.CreateLocker(t, app, zone, nil)
+	newLocker := testutil.CreateLocker(t, app, zone, nil)
+	_, heldLocker := testutil.ReservedRequest(t, app)
+
+	label := func(locker *core.Record) string { return locker.GetString("label") }
+	csv := strings.Join([]string{
+		"Family Email,Student,Class,Locker,Paid,Year,Paid At",
+		fmt.Sprintf("%s,Anna Muster,5a,%s,ja,2024/25", strings.ToUpper(existing.Email()), label(paidLocker)),
+		fmt.Sprintf("new@example.com,Ben Neu,6b,%s,nein,2024/25", label(openLocker)),
+		fmt.Sprintf("new@example.com,Clara Neu,7c,%s,x,2024/25,15.09.2024", label(newLocker)),
+		"lost@example.com,Dora,5a,99999,ja,2024/25",
+		fmt.Sprintf("taken@example.com,Emil,5a,%s,ja,2024/25", label(heldLocker)),
+		fmt.Sprintf("twice@example.com,Fritz,5a,%s,ja,2024/25", label(paidLocker)),
+		fmt.Sprintf("bad@example.com,Greta,5a,%s,maybe,2024/25", label(newLocker)),
+	}, "\n")
+
+	rows, err := legacy.ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := legacy.Plan(app, rows, legacy.Options{Amount: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Imported != 3 || plan.Errors != 4 || plan.UsersCreated != 1 || plan.Applied {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	testutil.AssertString(t, "planned locker status", testutil.Reload(t, app, paidLocker).GetString("status"), "free")
+
+	report, err := legacy.Apply(app, rows, legacy.Options{Amount: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 3 || report.Errors != 4 || report.UsersCreated != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, line := range []int{5, 6, 7, 8} {
+		if row := report.Rows[line-2]; row.Status != legacy.StatusError || row.Error == "" {
+			t.Fatalf("expected line %d to be reported as error, got %+v", line, row)
+		}
+	}
+
+	// paid: assigned to the existing family
+	paid, err := app.FindRecordById("requests", report.Rows[0].Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "paid request user", paid.GetString("user"), existing.Id)
+	testutil.AssertString(t, "paid request status", paid.GetString("status"), "assigned")
+	testutil.AssertString(t, "paid locker status", testutil.Reload(t, app, paidLocker).GetString("status"), "occupied")
+	testutil.AssertCount(t, app, invoices.Collection, dbx.HashExp{"request": paid.Id, "status": "paid", "amount": 25}, 1)
+	// without a payment date the payment is dated to the start of the school year
+	assertPaidAt(t, app, paid.Id, time.Date(2024, time.August, 1, 0, 0, 0, 0, clock.Location()))
+	assertPaidAt(t, app, report.Rows[2].Request, time.Date(2024, time.September, 15, 0, 0, 0, 0, clock.Location()))
+
+	// unpaid: reserved until the payment deadline, for a new family without invitation
+	family, err := app.FindAuthRecordByEmail("users", "new@example.com")
+	if err != nil {
+		t.Fatalf("expected the family to be created: %v", err)
+	}
+	if family.Verified() {
+		t.Fatal("expected the imported family to be unverified")
+	}
+	open, err := app.FindRecordById("requests", report.Rows[1].Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "open request user", open.GetString("user"), family.Id)
+	testutil.AssertString(t, "open request status", open.GetString("status"), "reserved")
+	testutil.AssertString(t, "open locker status", testutil.Reload(t, app, openLocker).GetString("status"), "reserved")
+	testutil.AssertCount(t, app, reservations.Collection, dbx.HashExp{"request": open.Id}, 1)
+	testutil.AssertCount(t, app, "assignments", dbx.HashExp{"request": open.Id}, 1)
+	testutil.AssertCount(t, app, invoices.Collection, dbx.HashExp{"request": open.Id, "status": "sent"}, 1)
+	testutil.AssertCount(t, app, "requests", dbx.HashExp{"user": family.Id}, 2)
+
+	var unmatched strings.Builder
+	if err := legacy.WriteErrors(&unmatched, report); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(unmatched.String(), "\n"); lines != 5 {
+		t.Fatalf("expected a header and 4 unmatched rows, got:\n%s", unmatched.String())
+	}
+
+	// importing the same export again only reports the existing assignments
+	again, err := legacy.Apply(app, rows, legacy.Options{Amount: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Imported != 0 || again.Existing != 3 || again.UsersCreated != 0 {
+		t.Fatalf("expected the re-import to be a no-op, got %+v", again)
+	}
+}
+
+func TestLegacyImportRejectsZeroAmounts(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	zone := testutil.CreateZone(t, app, nil)
+	lockers := []*core.Record{
+		testutil.CreateLocker(t, app, zone, nil),
+		testutil.CreateLocker(t, app, zone, nil),
+		testutil.CreateLocker(t, app, zone, nil),
+	}
+
+	csv := strings.Join([]string{
+		"email,student,class,locker,paid,year,amount",
+		fmt.Sprintf("a@example.com,Anna,5a,%s,ja,2024/25,", lockers[0].GetString("label")),
+		fmt.Sprintf("b@example.com,Ben,5a,%s,ja,2024/25,0", lockers[1].GetString("label")),
+		fmt.Sprintf("c@example.com,Clara,5a,%s,ja,2024/25,20", lockers[2].GetString("label")),
+	}, "\n")
+
+	rows, err := legacy.ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no default amount
+	report, err := legacy.Apply(app, rows, legacy.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || report.Errors != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, row := range report.Rows[:2] {
+		if row.Status != legacy.StatusError || !strings.Contains(row.Error, "amount is 0") {
+			t.Fatalf("expected line %d to be rejected for its amount, got %+v", row.Line, row)
+		}
+	}
+	testutil.AssertCount(t, app, invoices.Collection, nil, 1)
+	testutil.AssertCount(t, app, invoices.Collection, dbx.HashExp{"amount": 0}, 0)
+}
+
+func assertPaidAt(t *testing.T, app core.App, requestId string, want time.Time) {
+	t.Helper()
+
+	invoice, err := app.FindFirstRecordByData(invoices.Collection, "request", requestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := invoice.GetDateTime("paid_at").Time(); !got.Equal(want) {
+		t.Fatalf("expected the invoice of request %s to be paid at %s, got %s", requestId, want, got)
+	}
+}
@@ -0,0 +1,171 @@
+// Package legacy imports the locker assignments of the previous, spreadsheet
+// based process.
+package legacy
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+)
+
+// Row is a single line of a legacy export. Values are kept as written and
+// validated when the import is planned, so every invalid row can be reported.
+type Row struct {
+	Line    int    `json:"line"`
+	Email   string `json:"email"`
+	Name    string `json:"name,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Address string `json:"address,omitempty"`
+	Student string `json:"student"`
+	Class   string `json:"class"`
+	Locker  string `json:"locker"`
+	Zone    string `json:"zone,omitempty"`
+	Paid    string `json:"paid"`
+	Year    string `json:"year"`
+	Amount  string `json:"amount,omitempty"`
+	PaidAt  string `json:"paid_at,omitempty"`
+}
+
+// columnAliases maps the accepted header names to the [Row] columns.
+var columnAliases = map[string]string{
+	"email":         "email",
+	"family_email":  "email",
+	"name":          "name",
+	"family_name":   "name",
+	"parent":        "name",
+	"phone":         "phone",
+	"address":       "address",
+	"student":       "student",
+	"student_name":  "student",
+	"class":         "class",
+	"student_class": "class",
+	"locker":        "locker",
+	"locker_number": "locker",
+	"zone":          "zone",
+	"paid":          "paid",
+	"year":          "year",
+	"school_year":   "year",
+	"amount":        "amount",
+	"paid_at":       "paid_at",
+	"paid_on":       "paid_at",
+	"payment_date":  "paid_at",
+}
+
+var requiredColumns = []string{"email", "student", "class", "locker", "paid", "year"}
+
+// ParseCSV reads a legacy export with a header row.
+//
+// The email, student, class, locker, paid and year columns are required;
+// name, phone, address, zone, amount and paid_at are optional. Header names are
+// case insensitive and may use spaces, e.g. "Family Email".
+func ParseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		key = strings.TrimPrefix(key, "\ufeff") // Excel byte order mark
+		key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+		if column, ok := columnAliases[key]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+	for _, required := range requiredColumns {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing required csv column %q", required)
+		}
+	}
+
+	rows := []Row{}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := Row{
+			Line:    line,
+			Email:   get("email"),
+			Name:    get("name"),
+			Phone:   strings.ReplaceAll(get("phone"), " ", ""), // spaces are not allowed in stored phone numbers
+			Address: get("address"),
+			Student: get("student"),
+			Class:   get("class"),
+			Locker:  get("locker"),
+			Zone:    get("zone"),
+			Paid:    get("paid"),
+			Year:    get("year"),
+			Amount:  get("amount"),
+			PaidAt:  get("paid_at"),
+		}
+		if row == (Row{Line: line}) {
+			continue // blank line
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parsePaid reads the paid flag in the spellings found in school spreadsheets.
+func parsePaid(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "y", "x", "ja", "j", "paid", "bezahlt":
+		return true, nil
+	case "", "0", "false", "no", "n", "nein", "open", "offen":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid paid flag %q", value)
+	}
+}
+
+// paidAtLayouts are the accepted spellings of the payment date.
+var paidAtLayouts = []string{"2006-01-02", "02.01.2006", "2.1.2006"}
+
+// parsePaidAt reads the payment date of a paid row. Rows without one are
+// dated to the start of their school year, August 1.
+func parsePaidAt(value string, year string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		start, err := strconv.Atoi(year[:4])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid school year %q", year)
+		}
+		return time.Date(start, time.August, 1, 0, 0, 0, 0, clock.Location()), nil
+	}
+
+	for _, layout := range paidAtLayouts {
+		if t, err := time.ParseInLocation(layout, value, clock.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid payment date %q, expected YYYY-MM-DD", value)
+}
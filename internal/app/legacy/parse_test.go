@@ -0,0 +1,45 @@
+package legacy_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jryannel/spindit/internal/app/legacy"
+)
+
+func TestParseCSV(t *testing.T) {
+	csv := "\ufeffFamily Email,Student Name,Class,Locker Number,Paid,School Year,Phone\n" +
+		"anna@example.com, Anna Muster ,5a,12,ja,2024/25,+49 171 123\n" +
+		",,,,,,\n" +
+		"ben@example.com,Ben Beispiel,6b,A-003,no,2024/25\n"
+
+	rows, err := legacy.ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []legacy.Row{
+		{Line: 2, Email: "anna@example.com", Student: "Anna Muster", Class: "5a", Locker: "12", Paid: "ja", Year: "2024/25", Phone: "+49171123"},
+		{Line: 4, Email: "ben@example.com", Student: "Ben Beispiel", Class: "6b", Locker: "A-003", Paid: "no", Year: "2024/25"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestParseCSVRequiresColumns(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"email,student,class,locker,paid",
+		"email,student,class,paid,year",
+	} {
+		if _, err := legacy.ParseCSV(strings.NewReader(header + "\n")); err == nil {
+			t.Errorf("expected an error for header %q", header)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package lockers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+const (
+	lockersCollection = "lockers"
+	zonesCollection   = "zones"
+)
+
+var (
+	dashedLabelPattern = regexp.MustCompile(`^([A-Z0-9]+)-0*([0-9]+)$`)
+	plainLabelPattern  = regexp.MustCompile(`^([A-Z]+)0*([0-9]+)$`)
+	numberPattern      = regexp.MustCompile(`^0*([0-9]+)$`)
+)
+
+// Register keeps zone codes and locker labels in sync with the configured numbering scheme.
+func Register(app core.App) {
+	app.OnRecordCreate(zonesCollection).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("code") == "" {
+			code, err := UniqueZoneCode(e.App, e.Record.GetString("name"), e.Record.Id)
+			if err != nil {
+				return err
+			}
+			e.Record.Set("code", code)
+		}
+
+		return e.Next()
+	})
+
+	app.OnRecordUpdate(zonesCollection).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		if original == nil || original.GetString("code") == e.Record.GetString("code") {
+			return e.Next()
+		}
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			return relabel(txApp, dbx.HashExp{"zone": e.Record.Id})
+		})
+	})
+
+	app.OnRecordCreate(lockersCollection).BindFunc(func(e *core.RecordEvent) error {
+		if err := assignLabel(e.App, e.Record); err != nil {
+			return err
+		}
+
+		return e.Next()
+	})
+
+	app.OnRecordUpdate(lockersCollection).BindFunc(func(e *core.RecordEvent) error {
+		if err := assignLabel(e.App, e.Record); err != nil {
+			return err
+		}
+
+		return e.Next()
+	})
+
+	app.OnRecordUpdate(settings.Collection).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		if original == nil || original.GetString("locker_numbering") == e.Record.GetString("locker_numbering") {
+			return e.Next()
+		}
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			return Relabel(txApp)
+		})
+	})
+}
+
+// Relabel recomputes the labels of all lockers, e.g. after the numbering scheme changed.
+//
+// Switching to the global scheme fails if the same number is used in multiple zones.
+func Relabel(app core.App) error {
+	return app.RunInTransaction(func(txApp core.App) error {
+		return relabel(txApp, nil)
+	})
+}
+
+func relabel(app core.App, where dbx.Expression) error {
+	exprs := []dbx.Expression{}
+	if where != nil {
+		exprs = append(exprs, where)
+	}
+
+	records, err := app.FindAllRecords(lockersCollection, exprs...)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := app.Save(record); err != nil {
+			return fmt.Errorf("failed to relabel locker %d: %w", record.GetInt("number"), err)
+		}
+	}
+
+	return nil
+}
+
+// Label formats the label of a locker for the given numbering scheme.
+func Label(scheme string, zoneCode string, number int) string {
+	if scheme == settings.NumberingZone && zoneCode != "" {
+		return fmt.Sprintf("%s-%03d", zoneCode, number)
+	}
+
+	return strconv.Itoa(number)
+}
+
+func assignLabel(app core.App, record *core.Record) error {
+	s, err := settings.Load(app)
+	if err != nil {
+		return err
+	}
+
+	zoneCode := ""
+	if s.LockerNumbering == settings.NumberingZone {
+		zone, err := app.FindRecordById(zonesCollection, record.GetString("zone"))
+		if err != nil {
+			return fmt.Errorf("failed to load the zone of locker %d: %w", record.GetInt("number"), err)
+		}
+
+		zoneCode = zone.GetString("code")
+		if zoneCode == "" {
+			return fmt.Errorf("zone %q has no code required for zone numbering", zone.GetString("name"))
+		}
+	}
+
+	record.Set("label", Label(s.LockerNumbering, zoneCode, record.GetInt("number")))
+
+	return nil
+}
+
+// ZoneCode derives a short code from the last word of a zone name ("Zone A" -> "A").
+func ZoneCode(name string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return "Z"
+	}
+
+	code := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, words[len(words)-1])
+
+	if len(code) > 6 {
+		code = code[:6]
+	}
+	if code == "" {
+		return "Z"
+	}
+
+	return code
+}
+
+// UniqueZoneCode returns the [ZoneCode] of name, suffixed with a counter
+// ("A2", "A3", ...) while another zone than exceptId uses it.
+func UniqueZoneCode(app core.App, name string, exceptId string) (string, error) {
+	base := ZoneCode(name)
+
+	for i := 1; ; i++ {
+		code := base
+		if i > 1 {
+			code = base + strconv.Itoa(i)
+		}
+
+		_, err := query.FindFirst(app, zonesCollection, query.And(
+			query.Eq("code", code),
+			query.Where("id", query.OpNeq, exceptId),
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			return code, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// NormalizeLabel converts user input such as "a12", "A-12" or "012" into the canonical
+// label form ("A-012" or "12").
+func NormalizeLabel(value string) string {
+	value = strings.ToUpper(strings.Join(strings.Fields(value), ""))
+
+	if m := numberPattern.FindStringSubmatch(value); m != nil {
+		number, _ := strconv.Atoi(m[1])
+		return strconv.Itoa(number)
+	}
+
+	m := dashedLabelPattern.FindStringSubmatch(value)
+	if m == nil {
+		m = plainLabelPattern.FindStringSubmatch(value)
+	}
+	if m != nil {
+		number, _ := strconv.Atoi(m[2])
+		return fmt.Sprintf("%s-%03d", m[1], number)
+	}
+
+	return value
+}
+
+// ResolveLocker finds the locker a family referred to by record id, label or
+// (zone scoped) number. zoneId is optional and narrows plain numbers to a zone.
+func ResolveLocker(app core.App, value string, zoneId string) (*core.Record, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	if record, err := app.FindRecordById(lockersCollection, value); err == nil {
+		return record, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	record, err := query.FindFirst(app, lockersCollection, query.Eq("label", NormalizeLabel(value)))
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return record, err
+	}
+
+	if m := numberPattern.FindStringSubmatch(value); m != nil && zoneId != "" {
+		number, _ := strconv.Atoi(m[1])
+		return query.FindFirst(app, lockersCollection, query.And(
+			query.Eq("zone", zoneId),
+			query.Eq("number", number),
+		))
+	}
+
+	return nil, sql.ErrNoRows
+}
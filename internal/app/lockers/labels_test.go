@@ -0,0 +1,68 @@
+package lockers_test
+
+import (
+	"testing"
+
+	"github.com/jryannel/spindit/internal/app/lockers"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestZoneCode(t *testing.T) {
+	for name, want := range map[string]string{
+		"Zone A":             "A",
+		"Hall b2":            "B2",
+		"Erdgeschoss Süd":    "SD",
+		"Building West Wing": "WING",
+		"Gymnasium":          "GYMNAS",
+		"":                   "Z",
+		"Zone ---":           "Z",
+	} {
+		if got := lockers.ZoneCode(name); got != want {
+			t.Errorf("ZoneCode(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUniqueZoneCode(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	// zones without code get the code of their name, suffixed while taken
+	first := testutil.CreateZone(t, app, map[string]any{"name": "Test Hall Q", "code": ""})
+	second := testutil.CreateZone(t, app, map[string]any{"name": "Annex Q", "code": ""})
+	third := testutil.CreateZone(t, app, map[string]any{"name": "Basement q", "code": ""})
+
+	testutil.AssertString(t, "first code", first.GetString("code"), "Q")
+	testutil.AssertString(t, "second code", second.GetString("code"), "Q2")
+	testutil.AssertString(t, "third code", third.GetString("code"), "Q3")
+
+	// a zone keeps its own code
+	code, err := lockers.UniqueZoneCode(app, "Renamed Q", first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "own code", code, "Q")
+}
+
+func TestLabel(t *testing.T) {
+	testutil.AssertString(t, "global label", lockers.Label(settings.NumberingGlobal, "A", 7), "7")
+	testutil.AssertString(t, "zone label", lockers.Label(settings.NumberingZone, "A", 7), "A-007")
+	testutil.AssertString(t, "zone label without code", lockers.Label(settings.NumberingZone, "", 7), "7")
+	testutil.AssertString(t, "zone label over 999", lockers.Label(settings.NumberingZone, "B2", 1234), "B2-1234")
+}
+
+func TestNormalizeLabel(t *testing.T) {
+	for value, want := range map[string]string{
+		"12":      "12",
+		"012":     "12",
+		"a12":     "A-012",
+		"A-12":    "A-012",
+		" b2-007": "B2-007",
+		"A 12":    "A-012",
+		"gym":     "GYM",
+	} {
+		if got := lockers.NormalizeLabel(value); got != want {
+			t.Errorf("NormalizeLabel(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
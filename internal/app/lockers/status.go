@@ -0,0 +1,70 @@
+package lockers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+// Locker statuses.
+const (
+	StatusFree        = "free"
+	StatusReserved    = "reserved"
+	StatusOccupied    = "occupied"
+	StatusMaintenance = "maintenance"
+)
+
+const assignmentsCollection = "assignments"
+
+// ErrInUse is returned when a locker status change would orphan the
+// reservation or assignment holding the locker.
+var ErrInUse = errors.New("locker is held by a reservation or assignment")
+
+// Free marks locker as free again, e.g. after maintenance.
+//
+// Lockers held by an assignment have to be released through their request
+// (cancellation) or assignment instead, so the related records stay consistent.
+func Free(app core.App, locker *core.Record) error {
+	if locker.GetString("status") == StatusFree {
+		return nil
+	}
+
+	if err := ensureUnassigned(app, locker); err != nil {
+		return err
+	}
+
+	locker.Set("status", StatusFree)
+
+	return app.Save(locker)
+}
+
+// SetMaintenance takes an unassigned locker out of service. A non-empty note
+// replaces the locker note, e.g. with the reason.
+func SetMaintenance(app core.App, locker *core.Record, note string) error {
+	if err := ensureUnassigned(app, locker); err != nil {
+		return err
+	}
+
+	locker.Set("status", StatusMaintenance)
+	if note != "" {
+		locker.Set("note", note)
+	}
+
+	return app.Save(locker)
+}
+
+func ensureUnassigned(app core.App, locker *core.Record) error {
+	assignment, err := query.FindFirst(app, assignmentsCollection, query.Eq("locker", locker.Id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: locker %s (assignment %s of request %s)", ErrInUse, locker.GetString("label"), assignment.Id, assignment.GetString("request"))
+}
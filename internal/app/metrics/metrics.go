@@ -0,0 +1,325 @@
+// Package metrics exposes operational metrics in the Prometheus text format.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+)
+
+// ContentType is the content type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ExpiringWithin is the window of the reservations expiring soon gauge.
+const ExpiringWithin = 24 * time.Hour
+
+const recordErrorsKey = "spindit.metrics.recordErrors"
+
+// recordErrors counts failed record writes, e.g. writes rejected by a hook,
+// per collection and action since the process started.
+type recordErrors struct {
+	mu     sync.Mutex
+	values map[[2]string]int
+}
+
+// Register counts failed record creates, updates and deletes of the app
+// collections, including writes rejected by Spindit hooks.
+func Register(app core.App) {
+	count := func(action string) func(e *core.RecordErrorEvent) error {
+		return func(e *core.RecordErrorEvent) error {
+			if collection := e.Record.Collection(); !collection.System {
+				errorCounters(e.App).add(collection.Name, action)
+			}
+
+			return e.Next()
+		}
+	}
+
+	app.OnRecordAfterCreateError().BindFunc(count("create"))
+	app.OnRecordAfterUpdateError().BindFunc(count("update"))
+	app.OnRecordAfterDeleteError().BindFunc(count("delete"))
+}
+
+func errorCounters(app core.App) *recordErrors {
+	return app.Store().GetOrSet(recordErrorsKey, func() any {
+		return &recordErrors{values: map[[2]string]int{}}
+	}).(*recordErrors)
+}
+
+func (c *recordErrors) add(collection string, action string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[[2]string{collection, action}]++
+}
+
+func (c *recordErrors) snapshot() map[[2]string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	values := make(map[[2]string]int, len(c.values))
+	for key, value := range c.values {
+		values[key] = value
+	}
+
+	return values
+}
+
+// Write writes all metrics of app to w.
+func Write(app core.App, w io.Writer) error {
+	m := &writer{}
+
+	if err := writeLockers(app, m); err != nil {
+		return err
+	}
+	if err := writeRequests(app, m); err != nil {
+		return err
+	}
+	if err := writeReservations(app, m); err != nil {
+		return err
+	}
+	if err := writeEmailQueue(app, m); err != nil {
+		return err
+	}
+	if err := writeJobs(app, m); err != nil {
+		return err
+	}
+	writeRecordErrors(app, m)
+
+	_, err := io.WriteString(w, m.String())
+
+	return err
+}
+
+type countRow struct {
+	Label  string `db:"label"`
+	Label2 string `db:"label2"`
+	Total  int    `db:"total"`
+}
+
+func writeLockers(app core.App, m *writer) error {
+	rows := []countRow{}
+	err := app.DB().NewQuery(`
+		SELECT COALESCE(z.code, '') AS label, l.status AS label2, COUNT(*) AS total
+		FROM lockers l
+		LEFT JOIN zones z ON z.id = l.zone
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`).All(&rows)
+	if err != nil {
+		return err
+	}
+
+	m.header("spindit_lockers", "gauge", "Lockers by zone code and status.")
+	for _, row := range rows {
+		m.sample("spindit_lockers", labels{"zone", row.Label, "status", row.Label2}, float64(row.Total))
+	}
+
+	return nil
+}
+
+func writeRequests(app core.App, m *writer) error {
+	rows := []countRow{}
+	err := app.DB().NewQuery(`
+		SELECT status AS label, COUNT(*) AS total
+		FROM requests
+		GROUP BY 1
+		ORDER BY 1
+	`).All(&rows)
+	if err != nil {
+		return err
+	}
+
+	m.header("spindit_requests", "gauge", "Locker requests by status.")
+	for _, row := range rows {
+		m.sample("spindit_requests", labels{"status", row.Label}, float64(row.Total))
+	}
+
+	return nil
+}
+
+func writeReservations(app core.App, m *writer) error {
+	now := clock.Now(app)
+
+	var total, expiring int
+	err := app.DB().NewQuery(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN expires_at <= {:until} THEN 1 ELSE 0 END), 0)
+		FROM reservations
+	`).Bind(dbx.Params{
+		"until": now.Add(ExpiringWithin).UTC().Format(types.DefaultDateLayout),
+	}).Row(&total, &expiring)
+	if err != nil {
+		return err
+	}
+
+	m.header("spindit_reservations", "gauge", "Open locker reservations awaiting payment.")
+	m.sample("spindit_reservations", nil, float64(total))
+	m.header("spindit_reservations_expiring_soon", "gauge", "Reservations expiring within the next 24 hours.")
+	m.sample("spindit_reservations_expiring_soon", nil, float64(expiring))
+
+	return nil
+}
+
+func writeEmailQueue(app core.App, m *writer) error {
+	rows := []countRow{}
+	err := app.DB().NewQuery(`
+		SELECT status AS label, COUNT(*) AS total
+		FROM email_queue
+		GROUP BY 1
+	`).All(&rows)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[row.Label] = row.Total
+	}
+
+	m.header("spindit_email_queue", "gauge", "Queued emails by status; pending and sending form the queue depth, failed the failures.")
+	for _, status := range []string{"pending", "sending", "sent", "failed"} {
+		m.sample("spindit_email_queue", labels{"status", status}, float64(counts[status]))
+	}
+
+	return nil
+}
+
+func writeJobs(app core.App, m *writer) error {
+	type jobRow struct {
+		Status     string         `db:"status"`
+		StartedAt  types.DateTime `db:"started_at"`
+		FinishedAt types.DateTime `db:"finished_at"`
+	}
+
+	m.header("spindit_job_last_duration_seconds", "gauge", "Duration of the latest finished run per job.")
+	m.header("spindit_job_last_success_timestamp_seconds", "gauge", "Unix time of the latest successful run per job.")
+	m.header("spindit_job_last_failed", "gauge", "1 if the latest finished run of the job failed.")
+
+	for _, job := range cronjobs.Jobs() {
+		last := jobRow{}
+		err := app.DB().Select("status", "started_at", "finished_at").
+			From(cronjobs.RunsCollection).
+			Where(dbx.HashExp{"job": job.Id}).
+			AndWhere(dbx.In("status", cronjobs.StatusSuccess, cronjobs.StatusFailed)).
+			AndWhere(dbx.NewExp("dry_run = FALSE")).
+			OrderBy("started_at DESC").
+			Limit(1).
+			One(&last)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			duration := last.FinishedAt.Time().Sub(last.StartedAt.Time()).Seconds()
+			m.sample("spindit_job_last_duration_seconds", labels{"job", job.Id}, duration)
+			failed := 0.0
+			if last.Status == cronjobs.StatusFailed {
+				failed = 1
+			}
+			m.sample("spindit_job_last_failed", labels{"job", job.Id}, failed)
+		}
+
+		success := jobRow{}
+		err = app.DB().Select("status", "started_at", "finished_at").
+			From(cronjobs.RunsCollection).
+			Where(dbx.HashExp{"job": job.Id, "status": cronjobs.StatusSuccess}).
+			AndWhere(dbx.NewExp("dry_run = FALSE")).
+			OrderBy("finished_at DESC").
+			Limit(1).
+			One(&success)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			m.sample("spindit_job_last_success_timestamp_seconds", labels{"job", job.Id}, float64(success.FinishedAt.Time().Unix()))
+		}
+	}
+
+	return nil
+}
+
+func writeRecordErrors(app core.App, m *writer) {
+	values := errorCounters(app).snapshot()
+
+	keys := make([][2]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b [2]string) int {
+		return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1])
+	})
+
+	m.header("spindit_record_errors_total", "counter", "Failed record writes, e.g. rejected by a hook, since the process started.")
+	for _, key := range keys {
+		m.sample("spindit_record_errors_total", labels{"collection", key[0], "action", key[1]}, float64(values[key]))
+	}
+}
+
+// labels are alternating label names and values.
+type labels []string
+
+// writer builds the text exposition, grouping the samples of each metric
+// below its HELP and TYPE lines in the order the metrics were declared.
+type writer struct {
+	families []string
+	samples  map[string][]string
+}
+
+func (w *writer) header(name string, kind string, help string) {
+	if w.samples == nil {
+		w.samples = map[string][]string{}
+	}
+
+	w.families = append(w.families, name)
+	w.samples[name] = []string{
+		fmt.Sprintf("# HELP %s %s", name, help),
+		fmt.Sprintf("# TYPE %s %s", name, kind),
+	}
+}
+
+func (w *writer) sample(name string, l labels, value float64) {
+	var b strings.Builder
+	b.WriteString(name)
+
+	if len(l) > 0 {
+		b.WriteString("{")
+		for i := 0; i+1 < len(l); i += 2 {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(l[i] + `="` + escapeLabel(l[i+1]) + `"`)
+		}
+		b.WriteString("}")
+	}
+
+	b.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64))
+
+	w.samples[name] = append(w.samples[name], b.String())
+}
+
+func (w *writer) String() string {
+	var b strings.Builder
+	for _, name := range w.families {
+		for _, line := range w.samples[name] {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,53 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/metrics"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	testutil.ReservedRequest(t, app)
+
+	if _, err := cronjobs.Run(app, "reservations.expire", cronjobs.RunOptions{Trigger: "manual"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a locker without zone and number is rejected and counted
+	lockersCollection, err := app.FindCollectionByNameOrId("lockers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Save(core.NewRecord(lockersCollection)); err == nil {
+		t.Fatal("expected the invalid locker to be rejected")
+	}
+
+	var out strings.Builder
+	if err := metrics.Write(app, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`spindit_lockers{zone="`,
+		`",status="reserved"} 1`,
+		`spindit_requests{status="reserved"} 1`,
+		"spindit_reservations 1\n",
+		"spindit_reservations_expiring_soon 0\n",
+		`spindit_email_queue{status="failed"} 0`,
+		`spindit_job_last_failed{job="reservations.expire"} 0`,
+		`spindit_job_last_success_timestamp_seconds{job="reservations.expire"} `,
+		`spindit_record_errors_total{collection="lockers",action="create"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected the metrics to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a [Fake] webhook body.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// fakeEvent is the webhook body of the [Fake] provider.
+type fakeEvent struct {
+	Type      string    `json:"type"`
+	SessionId string    `json:"session_id"`
+	InvoiceId string    `json:"invoice_id"`
+	Reference string    `json:"reference"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	PaidAt    time.Time `json:"paid_at"`
+}
+
+// Fake is an in-process [PaymentGateway] for tests and local development.
+// Sessions are kept in memory and paid with [Fake.Pay], which returns the
+// signed webhook the provider would send.
+type Fake struct {
+	secret string
+
+	mu       sync.Mutex
+	sessions map[string]CheckoutRequest
+}
+
+// NewFake returns a fake gateway signing its webhooks with secret.
+func NewFake(secret string) *Fake {
+	return &Fake{secret: secret, sessions: map[string]CheckoutRequest{}}
+}
+
+// Name implements [PaymentGateway].
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// CreateCheckout implements [PaymentGateway].
+func (f *Fake) CreateCheckout(_ context.Context, req CheckoutRequest) (Session, error) {
+	id := "fake_cs_" + core.GenerateDefaultRandomId()
+
+	f.mu.Lock()
+	f.sessions[id] = req
+	f.mu.Unlock()
+
+	return Session{Id: id, URL: "https://payments.invalid/checkout/" + id}, nil
+}
+
+// Session returns the checkout request of an opened session.
+func (f *Fake) Session(id string) (CheckoutRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	req, ok := f.sessions[id]
+
+	return req, ok
+}
+
+// Pay completes session at paidAt and returns the signed webhook request
+// to target, the URL of the webhook route.
+func (f *Fake) Pay(target string, session string, paidAt time.Time) (*http.Request, error) {
+	req, ok := f.Session(session)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake session %q", session)
+	}
+
+	body, err := json.Marshal(fakeEvent{
+		Type:      EventCheckoutCompleted,
+		SessionId: session,
+		InvoiceId: req.InvoiceId,
+		Reference: "fake_pi_" + session,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		PaidAt:    paidAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(FakeSignatureHeader, f.sign(body))
+
+	return r, nil
+}
+
+// ParseWebhook implements [PaymentGateway].
+func (f *Fake) ParseWebhook(r *http.Request) (Event, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return Event{}, err
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.mac(body)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var event fakeEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Event{}, fmt.Errorf("invalid fake webhook body: %w", err)
+	}
+
+	return Event{
+		Type:      event.Type,
+		SessionId: event.SessionId,
+		InvoiceId: event.InvoiceId,
+		Reference: event.Reference,
+		Amount:    event.Amount,
+		Currency:  event.Currency,
+		PaidAt:    event.PaidAt,
+	}, nil
+}
+
+func (f *Fake) sign(body []byte) string {
+	return hex.EncodeToString(f.mac(body))
+}
+
+func (f *Fake) mac(body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(f.secret))
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,72 @@
+package payments_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jryannel/spindit/internal/app/payments"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestFakeWebhook(t *testing.T) {
+	gateway := payments.NewFake("fake-webhook-secret")
+
+	session, err := gateway.CreateCheckout(context.Background(), payments.CheckoutRequest{
+		InvoiceId: "inv123",
+		Number:    "INV-000001",
+		Amount:    20,
+		Currency:  "EUR",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := gateway.Pay("http://localhost/webhook", session.Id, testutil.Start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := gateway.ParseWebhook(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := payments.Event{
+		Type:      payments.EventCheckoutCompleted,
+		SessionId: session.Id,
+		InvoiceId: "inv123",
+		Reference: "fake_pi_" + session.Id,
+		Amount:    20,
+		Currency:  "EUR",
+		PaidAt:    testutil.Start,
+	}
+	if !event.PaidAt.Equal(want.PaidAt) {
+		t.Fatalf("expected paid at %s, got %s", want.PaidAt, event.PaidAt)
+	}
+	event.PaidAt = want.PaidAt
+	if event != want {
+		t.Fatalf("expected %+v, got %+v", want, event)
+	}
+
+	if _, err := gateway.Pay("http://localhost/webhook", "fake_cs_unknown", testutil.Start); err == nil {
+		t.Fatal("expected paying an unknown session to fail")
+	}
+
+	// a body signed with another secret is rejected
+	other := payments.NewFake("other-secret")
+	otherSession, _ := other.CreateCheckout(context.Background(), payments.CheckoutRequest{Amount: 20, Currency: "EUR"})
+	forged, err := other.Pay("http://localhost/webhook", otherSession.Id, testutil.Start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.ParseWebhook(forged); !errors.Is(err, payments.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	unsigned, _ := http.NewRequest(http.MethodPost, "http://localhost/webhook", strings.NewReader(`{"type":"checkout.completed"}`))
+	if _, err := gateway.ParseWebhook(unsigned); !errors.Is(err, payments.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for an unsigned webhook, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Package payments connects online payment providers to the invoices.
+//
+// A [PaymentGateway] opens checkout sessions for invoices and verifies the
+// webhooks of its provider; completed payments are confirmed through
+// [invoices.MarkPaid], the same path staff use, so adding a provider never
+// touches the invoice logic.
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+const storeKey = "spindit.payments.gateway"
+
+// EventCheckoutCompleted is the event of a paid checkout session. Gateways
+// report other provider events with their own type, which are ignored.
+const EventCheckoutCompleted = "checkout.completed"
+
+var (
+	// ErrInvalidSignature is returned by gateways for unsigned or forged webhooks.
+	ErrInvalidSignature = errors.New("invalid payment webhook signature")
+	// ErrNotPayable is returned for invoices that are not open.
+	ErrNotPayable = errors.New("the invoice cannot be paid online")
+	// ErrUnknownSession is the review cause of events of sessions without invoice.
+	ErrUnknownSession = errors.New("unknown checkout session")
+	// ErrAmountMismatch is the review cause of payments whose amount differs from the invoice.
+	ErrAmountMismatch = errors.New("the paid amount does not match the invoice")
+)
+
+// CheckoutRequest describes the invoice a checkout session is opened for.
+type CheckoutRequest struct {
+	// InvoiceId must be stored in the metadata of the provider session and
+	// returned with its events, see [Event.InvoiceId].
+	InvoiceId string
+	Number    string
+	Amount    float64
+	Currency  string
+	// Email is the payer's email, if known.
+	Email string
+	// SuccessURL and CancelURL are where the provider sends the payer back.
+	SuccessURL string
+	CancelURL  string
+}
+
+// Session is an opened checkout session.
+type Session struct {
+	Id string `json:"id"`
+	// URL is the payment page the payer is redirected to.
+	URL string `json:"url"`
+}
+
+// Event is a verified provider webhook.
+type Event struct {
+	Type      string
+	SessionId string
+	// InvoiceId is the [CheckoutRequest.InvoiceId] of the session. Events
+	// without it are resolved by the latest session of the invoice.
+	InvoiceId string
+	// Reference identifies the payment at the provider.
+	Reference string
+	Amount    float64
+	Currency  string
+	PaidAt    time.Time
+}
+
+// PaymentGateway is an online payment provider.
+type PaymentGateway interface {
+	// Name identifies the provider, e.g. "stripe". It is stored with the
+	// sessions of the invoices.
+	Name() string
+	// CreateCheckout opens a checkout session at the provider.
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (Session, error)
+	// ParseWebhook verifies the signature of a provider webhook and
+	// returns its event, [ErrInvalidSignature] if it is not authentic.
+	ParseWebhook(r *http.Request) (Event, error)
+}
+
+// Get returns the gateway configured for app, nil if online payments are
+// disabled.
+func Get(app core.App) PaymentGateway {
+	g, _ := app.Store().Get(storeKey).(PaymentGateway)
+
+	return g
+}
+
+// Set configures the gateway of app. Passing nil disables online payments.
+func Set(app core.App, g PaymentGateway) {
+	if g == nil {
+		app.Store().Remove(storeKey)
+		return
+	}
+
+	app.Store().Set(storeKey, g)
+}
+
+// Checkout opens a checkout session for invoice and stores it on the
+// invoice. Only sent invoices can be paid. A new session replaces the
+// stored one, earlier sessions stay payable through their invoice id.
+func Checkout(ctx context.Context, app core.App, g PaymentGateway, invoice *core.Record, successURL string, cancelURL string) (Session, error) {
+	if invoice.GetString("status") != invoices.StatusSent {
+		return Session{}, fmt.Errorf("%w: invoice %s is %s", ErrNotPayable, invoice.GetString("number"), invoice.GetString("status"))
+	}
+
+	req := CheckoutRequest{
+		InvoiceId:  invoice.Id,
+		Number:     invoice.GetString("number"),
+		Amount:     invoice.GetFloat("amount"),
+		Currency:   strings.ToUpper(invoice.GetString("currency")),
+		SuccessURL: successURL,
+		CancelURL:  cancelURL,
+	}
+
+	if request, err := app.FindRecordById("requests", invoice.GetString("request")); err == nil {
+		if user, err := app.FindRecordById("users", request.GetString("user")); err == nil {
+			req.Email = user.Email()
+		}
+	}
+
+	session, err := g.CreateCheckout(ctx, req)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to open a %s checkout session: %w", g.Name(), err)
+	}
+
+	invoice.Set("payment_provider", g.Name())
+	invoice.Set("payment_session", session.Id)
+	if err := app.Save(invoice); err != nil {
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+// HandleEvent confirms the invoice of a completed checkout session and
+// returns it. Repeated events of a paid invoice are accepted without
+// changes, other event types are ignored and return nil.
+//
+// A verified payment is never rejected: payments of unknown sessions, of
+// invoices that are no longer open or over a different amount are stored
+// in the payment_reviews queue and return an error wrapping
+// [ErrQueuedForReview].
+//
+// The invoice is read, checked and booked in one transaction, so of two
+// concurrent payments of one invoice the second is queued as already paid.
+func HandleEvent(app core.App, g PaymentGateway, event Event) (*core.Record, error) {
+	if event.Type != EventCheckoutCompleted {
+		return nil, nil
+	}
+
+	var invoice *core.Record
+	var queued error
+	err := app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		invoice, err = handleEvent(txApp, g, event)
+		if errors.Is(err, ErrQueuedForReview) {
+			// commit the review
+			queued = err
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return invoice, err
+	}
+
+	return invoice, queued
+}
+
+func handleEvent(app core.App, g PaymentGateway, event Event) (*core.Record, error) {
+	invoice, err := findInvoice(app, g, event)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, queueReview(app, g, event, nil, ReasonUnknownSession, fmt.Errorf("%w %q", ErrUnknownSession, event.SessionId))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch status := invoice.GetString("status"); {
+	case status == invoices.StatusPaid && invoice.GetString("payment_reference") == event.Reference:
+		return invoice, nil
+	case status == invoices.StatusPaid:
+		return invoice, queueReview(app, g, event, invoice, ReasonAlreadyPaid,
+			fmt.Errorf("invoice %s is paid already", invoice.GetString("number")))
+	case status != invoices.StatusSent:
+		return invoice, queueReview(app, g, event, invoice, ReasonNotPayable,
+			fmt.Errorf("%w: invoice %s is %s", ErrNotPayable, invoice.GetString("number"), status))
+	}
+
+	if math.Abs(event.Amount-invoice.GetFloat("amount")) > 0.005 || !strings.EqualFold(event.Currency, invoice.GetString("currency")) {
+		return invoice, queueReview(app, g, event, invoice, ReasonAmountMismatch,
+			fmt.Errorf("%w: invoice %s is %.2f %s, paid %.2f %s", ErrAmountMismatch,
+				invoice.GetString("number"), invoice.GetFloat("amount"), invoice.GetString("currency"), event.Amount, event.Currency))
+	}
+
+	paidAt := event.PaidAt
+	if paidAt.IsZero() {
+		paidAt = clock.Now(app)
+	}
+
+	invoice.Set("payment_reference", event.Reference)
+	if err := invoices.MarkPaid(app, invoice, paidAt); err != nil {
+		return invoice, err
+	}
+
+	return invoice, nil
+}
+
+// findInvoice returns the invoice of event, by the invoice id of the
+// provider metadata or else by the latest session stored on the invoice.
+func findInvoice(app core.App, g PaymentGateway, event Event) (*core.Record, error) {
+	if event.InvoiceId != "" {
+		return query.FindFirst(app, invoices.Collection, query.And(
+			query.Eq("id", event.InvoiceId),
+			query.Eq("payment_provider", g.Name()),
+		))
+	}
+
+	return query.FindFirst(app, invoices.Collection, query.And(
+		query.Eq("payment_provider", g.Name()),
+		query.Eq("payment_session", event.SessionId),
+	))
+}
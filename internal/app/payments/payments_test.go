@@ -0,0 +1,236 @@
+package payments_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/payments"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestOnlinePayment(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	gateway := payments.NewFake("fake-webhook-secret")
+	payments.Set(app, gateway)
+
+	request, locker := testutil.ReservedRequest(t, app)
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+
+	session, err := payments.Checkout(context.Background(), app, gateway, invoice, "https://app.example.com/paid", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout, ok := gateway.Session(session.Id)
+	if !ok || checkout.Amount != 20 || checkout.Currency != "EUR" || checkout.Number != invoice.GetString("number") {
+		t.Fatalf("expected a checkout over 20 EUR for %s, got %+v", invoice.GetString("number"), checkout)
+	}
+	invoice = testutil.Reload(t, app, invoice)
+	testutil.AssertString(t, "payment_session", invoice.GetString("payment_session"), session.Id)
+
+	webhook := func(r *http.Request) (*core.Record, error) {
+		t.Helper()
+		event, err := gateway.ParseWebhook(r)
+		if err != nil {
+			return nil, err
+		}
+		return payments.HandleEvent(app, gateway, event)
+	}
+
+	// forged webhooks are rejected
+	forged, err := gateway.Pay("http://localhost/api/spindit/payments/webhook", session.Id, testutil.Start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Header.Set(payments.FakeSignatureHeader, strings.Repeat("0", 64))
+	if _, err := webhook(forged); !errors.Is(err, payments.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, invoice).GetString("status"), invoices.StatusSent)
+
+	paidAt := testutil.Start.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		r, err := gateway.Pay("http://localhost/api/spindit/payments/webhook", session.Id, paidAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := webhook(r); err != nil {
+			t.Fatalf("webhook %d failed: %v", i+1, err)
+		}
+	}
+
+	invoice = testutil.Reload(t, app, invoice)
+	testutil.AssertString(t, "invoice status", invoice.GetString("status"), invoices.StatusPaid)
+	testutil.AssertString(t, "payment_reference", invoice.GetString("payment_reference"), "fake_pi_"+session.Id)
+	if got := invoice.GetDateTime("paid_at").Time(); !got.Equal(paidAt) {
+		t.Fatalf("expected paid_at %s, got %s", paidAt, got)
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "assigned")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "occupied")
+
+	if _, err := payments.Checkout(context.Background(), app, gateway, invoice, "", ""); !errors.Is(err, payments.ErrNotPayable) {
+		t.Fatalf("expected a paid invoice to be not payable, got %v", err)
+	}
+
+	// a payment over a different amount is queued for review
+	other, _ := testutil.ReservedRequest(t, app)
+	otherInvoice := testutil.CreateInvoice(t, app, other, nil)
+	otherSession, err := payments.Checkout(context.Background(), app, gateway, otherInvoice, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherInvoice = testutil.Reload(t, app, otherInvoice)
+	otherInvoice.Set("amount", 25)
+	if err := app.Save(otherInvoice); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gateway.Pay("http://localhost/api/spindit/payments/webhook", otherSession.Id, paidAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webhook(r); !errors.Is(err, payments.ErrQueuedForReview) || !errors.Is(err, payments.ErrAmountMismatch) {
+		t.Fatalf("expected ErrQueuedForReview with ErrAmountMismatch, got %v", err)
+	}
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, otherInvoice).GetString("status"), invoices.StatusSent)
+	testutil.AssertCount(t, app, payments.ReviewsCollection, dbx.HashExp{
+		"session": otherSession.Id,
+		"reason":  payments.ReasonAmountMismatch,
+		"invoice": otherInvoice.Id,
+	}, 1)
+}
+
+func TestOnlinePaymentSessions(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	gateway := payments.NewFake("fake-webhook-secret")
+	payments.Set(app, gateway)
+
+	webhook := func(session string) (*core.Record, error) {
+		t.Helper()
+		r, err := gateway.Pay("http://localhost/api/spindit/payments/webhook", session, testutil.Start.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, err := gateway.ParseWebhook(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payments.HandleEvent(app, gateway, event)
+	}
+
+	// an earlier session stays payable after the family opened a new one
+	request, _ := testutil.ReservedRequest(t, app)
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+	first, err := payments.Checkout(context.Background(), app, gateway, invoice, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := payments.Checkout(context.Background(), app, gateway, testutil.Reload(t, app, invoice), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webhook(first.Id); err != nil {
+		t.Fatalf("expected the earlier session to pay the invoice, got %v", err)
+	}
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, invoice).GetString("status"), invoices.StatusPaid)
+
+	// paying the other session as well is a double payment
+	if _, err := webhook(second.Id); !errors.Is(err, payments.ErrQueuedForReview) {
+		t.Fatalf("expected the second payment to be queued for review, got %v", err)
+	}
+	testutil.AssertCount(t, app, payments.ReviewsCollection, dbx.HashExp{"session": second.Id, "reason": payments.ReasonAlreadyPaid}, 1)
+
+	// a payment for an invoice cancelled during the checkout is queued
+	// once, however often the provider repeats the webhook
+	cancelled, _ := testutil.ReservedRequest(t, app)
+	cancelledInvoice := testutil.CreateInvoice(t, app, cancelled, nil)
+	session, err := payments.Checkout(context.Background(), app, gateway, cancelledInvoice, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled.Set("status", "cancelled")
+	if err := app.Save(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, cancelledInvoice).GetString("status"), invoices.StatusCancelled)
+
+	for i := 0; i < 2; i++ {
+		if _, err := webhook(session.Id); !errors.Is(err, payments.ErrQueuedForReview) || !errors.Is(err, payments.ErrNotPayable) {
+			t.Fatalf("webhook %d: expected ErrQueuedForReview with ErrNotPayable, got %v", i+1, err)
+		}
+	}
+	testutil.AssertCount(t, app, payments.ReviewsCollection, dbx.HashExp{
+		"session": session.Id,
+		"reason":  payments.ReasonNotPayable,
+		"status":  "open",
+	}, 1)
+}
+
+func TestConcurrentPaymentsOfOneInvoice(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	gateway := payments.NewFake("fake-webhook-secret")
+	payments.Set(app, gateway)
+
+	request, _ := testutil.ReservedRequest(t, app)
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+
+	events := make([]payments.Event, 2)
+	for i := range events {
+		session, err := payments.Checkout(context.Background(), app, gateway, testutil.Reload(t, app, invoice), "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := gateway.Pay("http://localhost/api/spindit/payments/webhook", session.Id, testutil.Start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if events[i], err = gateway.ParseWebhook(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the second session completes while the first payment is being saved
+	var second sync.WaitGroup
+	var secondErr error
+	var once sync.Once
+	app.OnRecordUpdate(invoices.Collection).BindFunc(func(e *core.RecordEvent) error {
+		once.Do(func() {
+			second.Add(1)
+			go func() {
+				defer second.Done()
+				_, secondErr = payments.HandleEvent(app, gateway, events[1])
+			}()
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		return e.Next()
+	})
+
+	if _, err := payments.HandleEvent(app, gateway, events[0]); err != nil {
+		t.Fatalf("expected the first payment to be booked, got %v", err)
+	}
+	second.Wait()
+	if !errors.Is(secondErr, payments.ErrQueuedForReview) {
+		t.Fatalf("expected the second payment to be queued for review, got %v", secondErr)
+	}
+
+	invoice = testutil.Reload(t, app, invoice)
+	testutil.AssertString(t, "payment reference", invoice.GetString("payment_reference"), events[0].Reference)
+	testutil.AssertCount(t, app, payments.ReviewsCollection, dbx.HashExp{
+		"session": events[1].SessionId,
+		"reason":  payments.ReasonAlreadyPaid,
+	}, 1)
+}
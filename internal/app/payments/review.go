@@ -0,0 +1,64 @@
+package payments
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+// ReviewsCollection is the review queue of verified payments that could not
+// be booked on an invoice.
+const ReviewsCollection = "payment_reviews"
+
+// Reasons a payment is queued for review.
+const (
+	ReasonUnknownSession = "unknown_session" // no invoice belongs to the session
+	ReasonNotPayable     = "not_payable"     // the invoice is cancelled or not sent
+	ReasonAmountMismatch = "amount_mismatch" // the paid amount differs from the invoice
+	ReasonAlreadyPaid    = "already_paid"    // the invoice was paid by another payment
+)
+
+// ErrQueuedForReview is returned by [HandleEvent] for verified payments that
+// were stored in the review queue instead of marking an invoice paid. The
+// provider must not retry them.
+var ErrQueuedForReview = errors.New("the payment was queued for review")
+
+// queueReview stores the payment of event in the review queue, once per
+// checkout session, and returns an error wrapping [ErrQueuedForReview] and
+// cause.
+func queueReview(app core.App, g PaymentGateway, event Event, invoice *core.Record, reason string, cause error) error {
+	_, err := query.FindFirst(app, ReviewsCollection, query.And(
+		query.Eq("provider", g.Name()),
+		query.Eq("session", event.SessionId),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		collection, err := app.FindCollectionByNameOrId(ReviewsCollection)
+		if err != nil {
+			return err
+		}
+
+		review := core.NewRecord(collection)
+		review.Set("provider", g.Name())
+		review.Set("session", event.SessionId)
+		review.Set("reference", event.Reference)
+		review.Set("amount", event.Amount)
+		review.Set("currency", event.Currency)
+		review.Set("paid_at", event.PaidAt)
+		review.Set("reason", reason)
+		review.Set("status", "open")
+		if invoice != nil {
+			review.Set("invoice", invoice.Id)
+		}
+		if err := app.Save(review); err != nil {
+			return fmt.Errorf("failed to queue the payment of session %s for review: %w", event.SessionId, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrQueuedForReview, cause)
+}
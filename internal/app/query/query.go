@@ -0,0 +1,181 @@
+// Package query builds PocketBase record filters with bound parameters.
+//
+// Values are never interpolated into the filter expression; they are passed
+// to the filter parser as [dbx.Params] placeholders, so user supplied ids,
+// zone values or search terms cannot change the meaning of a filter.
+//
+// The PocketBase filter parser cannot represent every string (e.g. a value
+// ending in a backslash); such values make the lookup fail instead of
+// matching unrelated records.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Supported comparison operators.
+const (
+	OpEq   = "="
+	OpNeq  = "!="
+	OpGt   = ">"
+	OpGte  = ">="
+	OpLt   = "<"
+	OpLte  = "<="
+	OpLike = "~"
+)
+
+var fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+type condition struct {
+	field string
+	op    string
+	value any
+	isOr  bool
+	or    []Filter
+}
+
+// Filter is a conjunction of field conditions. The zero value matches all records.
+type Filter struct {
+	conditions []condition
+}
+
+// Where returns a filter comparing field against value with op.
+//
+// field and op are part of the expression and must be constants; Where panics
+// on an invalid field name or an unsupported operator.
+func Where(field string, op string, value any) Filter {
+	if !fieldPattern.MatchString(field) {
+		panic(fmt.Sprintf("query: invalid field name %q", field))
+	}
+
+	switch op {
+	case OpEq, OpNeq, OpGt, OpGte, OpLt, OpLte, OpLike:
+	default:
+		panic(fmt.Sprintf("query: unsupported operator %q", op))
+	}
+
+	return Filter{conditions: []condition{{field: field, op: op, value: value}}}
+}
+
+// Eq returns a filter matching records whose field equals value.
+func Eq(field string, value any) Filter {
+	return Where(field, OpEq, value)
+}
+
+// And combines the given filters so that all of them must match.
+func And(filters ...Filter) Filter {
+	result := Filter{}
+	for _, f := range filters {
+		result.conditions = append(result.conditions, f.conditions...)
+	}
+
+	return result
+}
+
+// Or combines the given filters so that at least one of them must match.
+func Or(filters ...Filter) Filter {
+	return Filter{conditions: []condition{{isOr: true, or: filters}}}
+}
+
+// And returns a copy of f extended with the given filters.
+func (f Filter) And(filters ...Filter) Filter {
+	return And(append([]Filter{f}, filters...)...)
+}
+
+// IsEmpty reports whether the filter has no conditions.
+func (f Filter) IsEmpty() bool {
+	return len(f.conditions) == 0
+}
+
+// Build returns the filter expression and its bound parameters.
+//
+// PocketBase substitutes the placeholders one after another, so a value
+// containing the placeholder of another parameter would receive that
+// parameter's value. The placeholder prefix is therefore extended until no
+// string value contains it.
+func (f Filter) Build() (string, dbx.Params) {
+	prefix := "p"
+
+	for {
+		params := dbx.Params{}
+		expr := f.build(params, prefix)
+		if expr == "" {
+			expr = "id != ''"
+		}
+
+		if !containsPlaceholder(params, prefix) {
+			return expr, params
+		}
+
+		prefix += "_"
+	}
+}
+
+func containsPlaceholder(params dbx.Params, prefix string) bool {
+	for _, value := range params {
+		if s, ok := value.(string); ok && strings.Contains(s, "{:"+prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f Filter) build(params dbx.Params, prefix string) string {
+	parts := make([]string, 0, len(f.conditions))
+
+	for _, c := range f.conditions {
+		if c.isOr {
+			alternatives := make([]string, 0, len(c.or))
+			for _, alt := range c.or {
+				expr := alt.build(params, prefix)
+				if expr == "" {
+					expr = "id != ''"
+				}
+				alternatives = append(alternatives, "("+expr+")")
+			}
+			if len(alternatives) == 0 {
+				// an empty alternative list matches nothing
+				parts = append(parts, "id = ''")
+				continue
+			}
+			parts = append(parts, "("+strings.Join(alternatives, " || ")+")")
+			continue
+		}
+
+		if s, ok := c.value.(string); ok && s == "" && (c.op == OpEq || c.op == OpNeq) {
+			// only a literal empty string also matches NULL, e.g. unset date fields
+			parts = append(parts, c.field+" "+c.op+" ''")
+			continue
+		}
+
+		name := prefix + strconv.Itoa(len(params))
+		params[name] = c.value
+		parts = append(parts, c.field+" "+c.op+" {:"+name+"}")
+	}
+
+	return strings.Join(parts, " && ")
+}
+
+// FindFirst returns the first record of collection matching f.
+//
+// It returns [database/sql.ErrNoRows] when no record matches.
+func FindFirst(app core.App, collection string, f Filter) (*core.Record, error) {
+	expr, params := f.Build()
+
+	return app.FindFirstRecordByFilter(collection, expr, params)
+}
+
+// FindAll returns the records of collection matching f, ordered by sort
+// (e.g. "-created,number"). A limit of 0 returns all matching records.
+func FindAll(app core.App, collection string, f Filter, sort string, limit int, offset int) ([]*core.Record, error) {
+	expr, params := f.Build()
+
+	return app.FindRecordsByFilter(collection, expr, sort, limit, offset, params)
+}
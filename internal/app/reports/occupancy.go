@@ -0,0 +1,163 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Supported export formats.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+)
+
+// ContentType returns the MIME type for the given export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// IsSupportedFormat reports whether format can be passed to [WriteOccupancy].
+func IsSupportedFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX || format == FormatPDF
+}
+
+// OccupancyFilter narrows down the lockers included in an occupancy report.
+//
+// When SchoolYear is set only lockers with an active assignment in that
+// school year are listed.
+type OccupancyFilter struct {
+	Zone       string
+	Status     string
+	SchoolYear string
+}
+
+// OccupancyRow is a single locker line of the occupancy report.
+type OccupancyRow struct {
+	Label        string `db:"label"`
+	Status       string `db:"status"`
+	StudentName  string `db:"student_name"`
+	StudentClass string `db:"student_class"`
+	SchoolYear   string `db:"school_year"`
+	Note         string `db:"note"`
+}
+
+type occupancyZone struct {
+	Id   string `db:"id"`
+	Name string `db:"name"`
+}
+
+// occupancyWriter renders the per-zone locker lists into a concrete file format.
+type occupancyWriter interface {
+	StartZone(name string) error
+	WriteRow(row OccupancyRow) error
+	Close() error
+}
+
+// WriteOccupancy streams the per-zone locker occupancy report in the given format to w.
+//
+// Rows are read from the database cursor one at a time, so the report
+// never holds the full locker list in memory.
+func WriteOccupancy(app core.App, w io.Writer, format string, filter OccupancyFilter) error {
+	var out occupancyWriter
+	switch format {
+	case FormatCSV:
+		out = newCSVWriter(w)
+	case FormatXLSX:
+		out = newXLSXWriter(w)
+	case FormatPDF:
+		out = newPDFWriter(w)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+
+	zones := []occupancyZone{}
+	zonesQuery := app.DB().Select("id", "name").From("zones").OrderBy("name", "id")
+	if filter.Zone != "" {
+		zonesQuery.AndWhere(dbx.HashExp{"id": filter.Zone})
+	}
+	if err := zonesQuery.All(&zones); err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		if err := out.StartZone(zone.Name); err != nil {
+			return err
+		}
+
+		if err := writeZoneRows(app, out, zone.Id, filter); err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
+
+func writeZoneRows(app core.App, out occupancyWriter, zoneId string, filter OccupancyFilter) error {
+	params := dbx.Params{"zone": zoneId}
+
+	occupantJoin := "LEFT JOIN"
+	occupantConditions := []string{"r.status IN ('reserved', 'assigned')"}
+	if filter.SchoolYear != "" {
+		occupantJoin = "INNER JOIN"
+		occupantConditions = append(occupantConditions, "r.school_year = {:schoolYear}")
+		params["schoolYear"] = filter.SchoolYear
+	}
+
+	lockerConditions := []string{"l.zone = {:zone}"}
+	if filter.Status != "" {
+		lockerConditions = append(lockerConditions, "l.status = {:status}")
+		params["status"] = filter.Status
+	}
+
+	query := app.DB().NewQuery(fmt.Sprintf(`
+		SELECT
+			COALESCE(NULLIF(l.label, ''), CAST(l.number AS TEXT)) AS label,
+			l.status AS status,
+			COALESCE(l.note, '') AS note,
+			COALESCE(o.student_name, '') AS student_name,
+			COALESCE(o.student_class, '') AS student_class,
+			COALESCE(o.school_year, '') AS school_year
+		FROM lockers l
+		%s (
+			-- one occupant per locker, the one of the latest school year;
+			-- SQLite takes the bare columns from the row of MAX()
+			SELECT a.locker, r.student_name, r.student_class, MAX(r.school_year) AS school_year
+			FROM assignments a
+			INNER JOIN requests r ON r.id = a.request
+			WHERE %s
+			GROUP BY a.locker
+		) o ON o.locker = l.id
+		WHERE %s
+		ORDER BY l.number
+	`, occupantJoin, strings.Join(occupantConditions, " AND "), strings.Join(lockerConditions, " AND ")))
+
+	rows, err := query.Bind(params).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row OccupancyRow
+		if err := rows.ScanStruct(&row); err != nil {
+			return err
+		}
+		if err := out.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,67 @@
+package reports_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/reports"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestOccupancyListsLockersOnce(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	request, locker := testutil.AssignedRequest(t, app)
+
+	// a second active assignment of the locker, e.g. a legacy import of the
+	// next school year
+	next := testutil.CreateRequest(t, app, testutil.CreateUser(t, app, nil), map[string]any{
+		"school_year":  "2025/26",
+		"student_name": "Next Student",
+	})
+	if _, err := app.DB().Update("requests", dbx.Params{"status": "assigned"}, dbx.HashExp{"id": next.Id}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	assignments, err := app.FindCollectionByNameOrId("assignments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment := core.NewRecord(assignments)
+	assignment.Load(map[string]any{"request": next.Id, "locker": locker.Id, "assigned_at": testutil.Start})
+	if err := app.Save(assignment); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := reports.WriteOccupancy(app, &out, reports.FormatCSV, reports.OccupancyFilter{Zone: locker.GetString("zone")}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected the header and one locker row, got %v", rows)
+	}
+	testutil.AssertString(t, "occupant", rows[1][3], "Next Student")
+	testutil.AssertString(t, "school year", rows[1][5], "2025/26")
+
+	out.Reset()
+	filter := reports.OccupancyFilter{Zone: locker.GetString("zone"), SchoolYear: request.GetString("school_year")}
+	if err := reports.WriteOccupancy(app, &out, reports.FormatCSV, filter); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected the header and one locker row, got %v", rows)
+	}
+	testutil.AssertString(t, "occupant in the filtered year", rows[1][3], request.GetString("student_name"))
+}
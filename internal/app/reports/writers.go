@@ -0,0 +1,260 @@
+package reports
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jryannel/spindit/internal/pbext/pdf"
+)
+
+var occupancyHeaders = []string{"Locker", "Status", "Student", "Class", "School year", "Note"}
+
+func (row OccupancyRow) values() []string {
+	return []string{
+		row.Label,
+		row.Status,
+		row.StudentName,
+		row.StudentClass,
+		row.SchoolYear,
+		row.Note,
+	}
+}
+
+// -------------------------------------------------------------------
+// CSV
+// -------------------------------------------------------------------
+
+type csvWriter struct {
+	w    *csv.Writer
+	zone string
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	cw.w.Write(append([]string{"Zone"}, occupancyHeaders...))
+	return cw
+}
+
+func (cw *csvWriter) StartZone(name string) error {
+	cw.zone = name
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) WriteRow(row OccupancyRow) error {
+	return cw.w.Write(append([]string{cw.zone}, row.values()...))
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// -------------------------------------------------------------------
+// XLSX (one worksheet per zone)
+// -------------------------------------------------------------------
+
+type xlsxWriter struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	names  []string
+	rowNum int
+}
+
+func newXLSXWriter(w io.Writer) *xlsxWriter {
+	return &xlsxWriter{zw: zip.NewWriter(w)}
+}
+
+func (xw *xlsxWriter) StartZone(name string) error {
+	if err := xw.endSheet(); err != nil {
+		return err
+	}
+
+	xw.names = append(xw.names, sheetName(name, xw.names))
+
+	entry, err := xw.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(xw.names)))
+	if err != nil {
+		return err
+	}
+
+	xw.sheet = bufio.NewWriter(entry)
+	xw.rowNum = 0
+	xw.sheet.WriteString(xml.Header)
+	xw.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	return xw.writeCells(occupancyHeaders)
+}
+
+func (xw *xlsxWriter) WriteRow(row OccupancyRow) error {
+	return xw.writeCells(row.values())
+}
+
+// writeCells writes a row of inline string cells.
+func (xw *xlsxWriter) writeCells(values []string) error {
+	xw.rowNum++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.rowNum)
+	for _, value := range values {
+		xw.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(xw.sheet, []byte(value)); err != nil {
+			return err
+		}
+		xw.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := xw.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (xw *xlsxWriter) endSheet() error {
+	if xw.sheet == nil {
+		return nil
+	}
+
+	xw.sheet.WriteString(`</sheetData></worksheet>`)
+	err := xw.sheet.Flush()
+	xw.sheet = nil
+
+	return err
+}
+
+func (xw *xlsxWriter) Close() error {
+	if len(xw.names) == 0 {
+		if err := xw.StartZone("Occupancy"); err != nil {
+			return err
+		}
+	}
+
+	if err := xw.endSheet(); err != nil {
+		return err
+	}
+
+	var workbook, workbookRels, contentTypes strings.Builder
+
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+
+	workbookRels.WriteString(xml.Header)
+	workbookRels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	contentTypes.WriteString(xml.Header)
+	contentTypes.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	contentTypes.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	contentTypes.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	contentTypes.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+
+	for i, name := range xw.names {
+		workbook.WriteString(`<sheet name="`)
+		xml.EscapeText(&workbook, []byte(name))
+		fmt.Fprintf(&workbook, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+	contentTypes.WriteString(`</Types>`)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	}
+
+	for _, file := range files {
+		entry, err := xw.zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, file.content); err != nil {
+			return err
+		}
+	}
+
+	return xw.zw.Close()
+}
+
+// sheetName normalizes name to a valid and unique worksheet name (max 31 chars, no []:*?/\).
+func sheetName(name string, existing []string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Zone"
+	}
+
+	base := []rune(name)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+
+	candidate := string(base)
+	for i := 2; containsFold(existing, candidate); i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		trimmed := base
+		if len(trimmed)+len(suffix) > 31 {
+			trimmed = trimmed[:31-len(suffix)]
+		}
+		candidate = string(trimmed) + suffix
+	}
+
+	return candidate
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------------------------------------------------------
+// PDF (one section per zone)
+// -------------------------------------------------------------------
+
+type pdfWriter struct {
+	doc   *pdf.Document
+	table *pdf.Table
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	doc := pdf.NewDocument(w, pdf.A4Landscape)
+
+	return &pdfWriter{
+		doc: doc,
+		table: pdf.NewTable(doc, []pdf.Column{
+			{Title: occupancyHeaders[0], Width: 1},
+			{Title: occupancyHeaders[1], Width: 1.3},
+			{Title: occupancyHeaders[2], Width: 3},
+			{Title: occupancyHeaders[3], Width: 1},
+			{Title: occupancyHeaders[4], Width: 1.3},
+			{Title: occupancyHeaders[5], Width: 4},
+		}),
+	}
+}
+
+func (pw *pdfWriter) StartZone(name string) error {
+	return pw.table.Section("Occupancy report – " + name)
+}
+
+func (pw *pdfWriter) WriteRow(row OccupancyRow) error {
+	return pw.table.Row(row.values()...)
+}
+
+func (pw *pdfWriter) Close() error {
+	return pw.doc.Close()
+}
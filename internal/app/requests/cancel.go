@@ -0,0 +1,25 @@
+package requests
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Cancel cancels an active request.
+//
+// The cancellation hook releases the reservation or assignment, frees the
+// locker and cancels open invoices, exactly as for a cancellation by staff.
+func Cancel(app core.App, request *core.Record) error {
+	status := request.GetString("status")
+	if status == StatusCancelled {
+		return nil
+	}
+	if !IsActiveStatus(status) {
+		return fmt.Errorf("request %s is %s and cannot be cancelled", request.Id, status)
+	}
+
+	request.Set("status", StatusCancelled)
+
+	return app.Save(request)
+}
@@ -0,0 +1,212 @@
+package requests
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+// Collection is the name of the locker requests collection.
+const Collection = "requests"
+
+// Request statuses.
+const (
+	StatusPending   = "pending"
+	StatusReserved  = "reserved"
+	StatusExpired   = "expired"
+	StatusAssigned  = "assigned"
+	StatusCancelled = "cancelled"
+)
+
+// ActiveStatuses lists the statuses of requests that still hold or wait for a locker.
+var ActiveStatuses = []string{StatusPending, StatusReserved, StatusAssigned}
+
+// IsActiveStatus reports whether status is one of [ActiveStatuses].
+func IsActiveStatus(status string) bool {
+	for _, active := range ActiveStatuses {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
+
+// Register installs the request hooks.
+func Register(app core.App) {
+	app.OnRecordCreate(Collection).BindFunc(func(e *core.RecordEvent) error {
+		return guardDuplicate(e, nil)
+	})
+
+	app.OnRecordUpdate(Collection).BindFunc(func(e *core.RecordEvent) error {
+		return guardDuplicate(e, e.Record.Original())
+	})
+
+	app.OnRecordCreateRequest(Collection).BindFunc(limitCreate)
+}
+
+// guardDuplicate rejects a second active request for the same student, family
+// and school year unless staff explicitly set allow_duplicate.
+//
+// The lookup and the write run in one transaction so that concurrent
+// submissions cannot both pass the check.
+func guardDuplicate(e *core.RecordEvent, original *core.Record) error {
+	record := e.Record
+	record.Set("student_key", StudentKey(record.GetString("student_name")))
+
+	if record.GetBool("allow_duplicate") ||
+		record.GetString("student_key") == "" ||
+		!IsActiveStatus(record.GetString("status")) {
+		return e.Next()
+	}
+
+	if original != nil &&
+		original.GetString("student_key") == record.GetString("student_key") &&
+		original.GetString("user") == record.GetString("user") &&
+		original.GetString("school_year") == record.GetString("school_year") &&
+		IsActiveStatus(original.GetString("status")) {
+		// existing duplicates stay editable as long as they are not re-activated or moved
+		return e.Next()
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		existing, err := FindActiveDuplicate(txApp, record)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if existing != nil {
+			return validation.Errors{
+				"student_name": validation.NewError(
+					"validation_duplicate_request",
+					"An active request for {{.student}} in {{.school_year}} already exists ({{.request}})",
+				).SetParams(map[string]any{
+					"request":     existing.Id,
+					"status":      existing.GetString("status"),
+					"student":     existing.GetString("student_name"),
+					"school_year": existing.GetString("school_year"),
+				}),
+			}
+		}
+
+		e.App = txApp
+		return e.Next()
+	})
+}
+
+// FindActiveDuplicate returns another active request of the same family for the
+// same (normalized) student and school year as record.
+//
+// It returns [sql.ErrNoRows] when there is none.
+func FindActiveDuplicate(app core.App, record *core.Record) (*core.Record, error) {
+	statuses := make([]query.Filter, len(ActiveStatuses))
+	for i, status := range ActiveStatuses {
+		statuses[i] = query.Eq("status", status)
+	}
+
+	return query.FindFirst(app, Collection, query.And(
+		query.Eq("user", record.GetString("user")),
+		query.Eq("school_year", record.GetString("school_year")),
+		query.Eq("student_key", StudentKey(record.GetString("student_name"))),
+		query.Where("id", query.OpNeq, record.Id),
+		query.Or(statuses...),
+	))
+}
+
+// StudentKey normalizes a student name for duplicate detection by lowercasing,
+// removing diacritics and punctuation and collapsing whitespace
+// ("  Jörg  MÜLLER " -> "jorg muller").
+func StudentKey(name string) string {
+	decomposed := norm.NFD.String(strings.ReplaceAll(strings.ToLower(name), "ß", "ss"))
+
+	key := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			return -1
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return r
+		default:
+			return ' '
+		}
+	}, decomposed)
+
+	return strings.Join(strings.Fields(key), " ")
+}
+
+// DuplicateGroup is a set of requests for the same family, student and school year.
+type DuplicateGroup struct {
+	UserId     string
+	UserEmail  string
+	SchoolYear string
+	StudentKey string
+	Requests   []*core.Record
+}
+
+// ActiveCount returns the number of active requests in the group.
+func (g *DuplicateGroup) ActiveCount() int {
+	total := 0
+	for _, r := range g.Requests {
+		if IsActiveStatus(r.GetString("status")) {
+			total++
+		}
+	}
+	return total
+}
+
+// FindDuplicateGroups lists all historical duplicates, optionally limited to a school year.
+// With activeOnly only groups with more than one active request are returned.
+func FindDuplicateGroups(app core.App, schoolYear string, activeOnly bool) ([]*DuplicateGroup, error) {
+	keys := []struct {
+		User       string `db:"user"`
+		Email      string `db:"email"`
+		SchoolYear string `db:"school_year"`
+		StudentKey string `db:"student_key"`
+	}{}
+
+	q := app.DB().Select("r.user AS user", "COALESCE(u.email, '') AS email", "r.school_year AS school_year", "r.student_key AS student_key").
+		From(Collection+" r").
+		LeftJoin("users u", dbx.NewExp("u.id = r.user")).
+		Where(dbx.NewExp("r.student_key != ''")).
+		GroupBy("r.user", "r.school_year", "r.student_key").
+		Having(dbx.NewExp("COUNT(*) > 1")).
+		OrderBy("r.school_year DESC", "email", "r.student_key")
+	if schoolYear != "" {
+		q.AndWhere(dbx.HashExp{"r.school_year": schoolYear})
+	}
+	if err := q.All(&keys); err != nil {
+		return nil, err
+	}
+
+	groups := make([]*DuplicateGroup, 0, len(keys))
+	for _, key := range keys {
+		records, err := query.FindAll(app, Collection, query.And(
+			query.Eq("user", key.User),
+			query.Eq("school_year", key.SchoolYear),
+			query.Eq("student_key", key.StudentKey),
+		), "submitted_at", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		group := &DuplicateGroup{
+			UserId:     key.User,
+			UserEmail:  key.Email,
+			SchoolYear: key.SchoolYear,
+			StudentKey: key.StudentKey,
+			Requests:   records,
+		}
+		if activeOnly && group.ActiveCount() < 2 {
+			continue
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
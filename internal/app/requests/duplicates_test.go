@@ -0,0 +1,159 @@
+package requests_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestStudentKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"Max Muster":        "max muster",
+		"  Jörg  MÜLLER ":   "jorg muller",
+		"Strauß":            "strauss",
+		"Anna-Lena O'Brien": "anna lena o brien",
+		"Zoë\tRenée":        "zoe renee",
+		"Lukas Müller (5b)": "lukas muller 5b",
+		"":                  "",
+		"---":               "",
+	} {
+		if got := requests.StudentKey(name); got != want {
+			t.Errorf("StudentKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDuplicateRequestGuard(t *testing.T) {
+	scenarios := []struct {
+		name      string
+		staff     bool
+		duplicate bool
+		status    int
+		content   string
+	}{
+		{"a second active request is rejected", false, false, http.StatusBadRequest, `"code":"validation_duplicate_request"`},
+		{"staff may allow a duplicate", true, true, http.StatusOK, `"allow_duplicate":true`},
+		{"families may not allow a duplicate", false, true, http.StatusBadRequest, `"message":"Failed to create record."`},
+	}
+
+	for _, s := range scenarios {
+		app := testutil.NewScenarioApp(t)
+		testutil.FreezeClock(app, testutil.Start)
+
+		family := testutil.CreateUser(t, app, nil)
+		existing := testutil.CreateRequest(t, app, family, map[string]any{"student_name": "Mia Müller"})
+
+		auth := family
+		if s.staff {
+			auth = testutil.CreateUser(t, app, map[string]any{"role": access.RoleStaff})
+		}
+		token, err := auth.NewAuthToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content := []string{s.content}
+		if !s.duplicate {
+			// the error points at the existing request
+			content = append(content, `"request":"`+existing.Id+`"`)
+		}
+
+		scenario := tests.ApiScenario{
+			Name:   s.name,
+			Method: http.MethodPost,
+			URL:    "/api/collections/requests/records",
+			Body: strings.NewReader(`{
+				"user": "` + family.Id + `",
+				"requester_name": "Test Family",
+				"requester_address": "Teststraße 1, 12345 Berlin",
+				"requester_phone": "+49301234567",
+				"student_name": "  mia MULLER",
+				"student_class": "5a",
+				"school_year": "2024/25",
+				"status": "pending",
+				"submitted_at": "2024-08-01 10:00:00.000Z",
+				"allow_duplicate": ` + strconv.FormatBool(s.duplicate) + `
+			}`),
+			Headers:         map[string]string{"Authorization": token},
+			ExpectedStatus:  s.status,
+			ExpectedContent: content,
+			TestAppFactory: func(testing.TB) *tests.TestApp {
+				return app
+			},
+		}
+		scenario.Test(t)
+	}
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	family := testutil.CreateUser(t, app, nil)
+	first := testutil.CreateRequest(t, app, family, map[string]any{"student_name": "Mia Müller", "submitted_at": testutil.Start.Add(-2 * time.Hour)})
+	if err := requests.Cancel(app, first); err != nil {
+		t.Fatal(err)
+	}
+	second := testutil.CreateRequest(t, app, family, map[string]any{"student_name": "mia muller", "submitted_at": testutil.Start.Add(-time.Hour)})
+	third := testutil.CreateRequest(t, app, family, map[string]any{"student_name": "Mia-Müller", "allow_duplicate": true})
+
+	// other years, students and families are not grouped
+	testutil.CreateRequest(t, app, family, map[string]any{"student_name": "Mia Müller", "school_year": "2023/24"})
+	testutil.CreateRequest(t, app, family, map[string]any{"student_name": "Tom Müller"})
+	testutil.CreateRequest(t, app, testutil.CreateUser(t, app, nil), map[string]any{"student_name": "Mia Müller"})
+
+	groups, err := requests.FindDuplicateGroups(app, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("expected one duplicate group, got %d", len(groups))
+	}
+	group := groups[0]
+	testutil.AssertString(t, "group email", group.UserEmail, family.Email())
+	testutil.AssertString(t, "group student key", group.StudentKey, "mia muller")
+	if len(group.Requests) != 3 || group.ActiveCount() != 2 {
+		t.Fatalf("expected 3 requests with 2 active ones, got %d with %d active", len(group.Requests), group.ActiveCount())
+	}
+	for i, want := range []string{first.Id, second.Id, third.Id} {
+		testutil.AssertString(t, fmt.Sprintf("request %d", i+1), group.Requests[i].Id, want)
+	}
+
+	for _, s := range []struct {
+		year       string
+		activeOnly bool
+		want       int
+	}{
+		{"2024/25", true, 1},
+		{"2023/24", false, 0},
+	} {
+		groups, err := requests.FindDuplicateGroups(app, s.year, s.activeOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != s.want {
+			t.Fatalf("expected %d groups in %s, got %d", s.want, s.year, len(groups))
+		}
+	}
+
+	// groups with a single active request are not reported as active
+	if err := requests.Cancel(app, testutil.Reload(t, app, third)); err != nil {
+		t.Fatal(err)
+	}
+	groups, err = requests.FindDuplicateGroups(app, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Fatalf("expected no active duplicate groups, got %d", len(groups))
+	}
+}
@@ -0,0 +1,95 @@
+package requests
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+// rateLimitWindow is the period [limitCreate] counts the created requests
+// of an account over.
+const rateLimitWindow = time.Hour
+
+// limitCreate enforces the per family quota and the hourly creation rate for
+// non staff accounts.
+//
+// The rate is counted from the requests of the account submitted within
+// rateLimitWindow, so it holds across restarts and instances. Their
+// submission date is therefore set by the server.
+//
+// The counts and the insert run in one transaction so that concurrent
+// submissions cannot all pass the limits.
+func limitCreate(e *core.RecordRequestEvent) error {
+	if access.IsStaff(e.Auth) {
+		return e.Next()
+	}
+
+	s, err := settings.Load(e.App)
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now(e.App)
+	e.Record.Set("submitted_at", now)
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		if s.MaxRequestsPerHour > 0 && e.Auth != nil {
+			recent, err := txApp.CountRecords(Collection,
+				dbx.HashExp{"user": e.Auth.Id},
+				dbx.NewExp("[[submitted_at]] > {:since}", dbx.Params{
+					"since": now.Add(-rateLimitWindow).UTC().Format(types.DefaultDateLayout),
+				}),
+			)
+			if err != nil {
+				return err
+			}
+			if int(recent) >= s.MaxRequestsPerHour {
+				return e.TooManyRequestsError("Too many locker requests, please try again later.", nil)
+			}
+		}
+
+		if s.MaxActiveRequestsPerFamily > 0 {
+			active, err := CountActive(txApp, e.Record.GetString("user"), e.Record.GetString("school_year"))
+			if err != nil {
+				return err
+			}
+			if active >= s.MaxActiveRequestsPerFamily {
+				return e.BadRequestError("The request quota for this school year is reached.", validation.Errors{
+					"school_year": validation.NewError(
+						"validation_request_quota",
+						"A family can hold at most {{.max}} active requests per school year",
+					).SetParams(map[string]any{
+						"max":         s.MaxActiveRequestsPerFamily,
+						"school_year": e.Record.GetString("school_year"),
+					}),
+				})
+			}
+		}
+
+		e.App = txApp
+		return e.Next()
+	})
+}
+
+// CountActive returns the number of active requests of a family in a school year.
+func CountActive(app core.App, userId string, schoolYear string) (int, error) {
+	statuses := make([]any, len(ActiveStatuses))
+	for i, status := range ActiveStatuses {
+		statuses[i] = status
+	}
+
+	total, err := app.CountRecords(Collection, dbx.HashExp{
+		"user":        userId,
+		"school_year": schoolYear,
+		"status":      statuses,
+	})
+
+	return int(total), err
+}
@@ -0,0 +1,237 @@
+package requests_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestHourlyRequestLimit(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		previous time.Duration
+		status   int
+		content  string
+	}{
+		{"requests within the hour are counted", -30 * time.Minute, http.StatusTooManyRequests, `"status":429`},
+		// a backdated submission is dated by the server, so it is counted next time
+		{"older requests are not counted", -2 * time.Hour, http.StatusOK, `"submitted_at":"2024-08-01 10:00:00.000Z"`},
+	}
+
+	for _, s := range scenarios {
+		app := testutil.NewScenarioApp(t)
+		testutil.FreezeClock(app, testutil.Start)
+
+		record, err := settings.FindRecord(app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record.Set("max_requests_per_hour", 2)
+		record.Set("max_active_requests_per_family", 0)
+		if err := app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+
+		family := testutil.CreateUser(t, app, nil)
+		for range 2 {
+			testutil.CreateRequest(t, app, family, map[string]any{"submitted_at": testutil.Start.Add(s.previous)})
+		}
+		token, err := family.NewAuthToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scenario := tests.ApiScenario{
+			Name:   s.name,
+			Method: http.MethodPost,
+			URL:    "/api/collections/requests/records",
+			Body: strings.NewReader(`{
+				"user": "` + family.Id + `",
+				"requester_name": "Test Family",
+				"requester_address": "Teststraße 1, 12345 Berlin",
+				"requester_phone": "+49301234567",
+				"student_name": "Mia Müller",
+				"student_class": "5a",
+				"school_year": "2024/25",
+				"status": "pending",
+				"submitted_at": "2020-01-01 00:00:00.000Z"
+			}`),
+			Headers:         map[string]string{"Authorization": token},
+			ExpectedStatus:  s.status,
+			ExpectedContent: []string{s.content},
+			TestAppFactory: func(testing.TB) *tests.TestApp {
+				return app
+			},
+		}
+		scenario.Test(t)
+	}
+}
+
+func TestActiveRequestQuota(t *testing.T) {
+	scenarios := []struct {
+		name    string
+		year    string
+		cancel  bool
+		staff   bool
+		code    int
+		content string
+	}{
+		{"active requests of the year are counted", "2024/25", false, false, http.StatusBadRequest, `"code":"validation_request_quota"`},
+		{"other school years are not counted", "2023/24", false, false, http.StatusOK, `"school_year":"2024/25"`},
+		{"cancelled requests are not counted", "2024/25", true, false, http.StatusOK, `"school_year":"2024/25"`},
+		{"staff are exempt", "2024/25", false, true, http.StatusOK, `"school_year":"2024/25"`},
+	}
+
+	for _, s := range scenarios {
+		app := testutil.NewScenarioApp(t)
+		testutil.FreezeClock(app, testutil.Start)
+
+		record, err := settings.FindRecord(app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record.Set("max_requests_per_hour", 0)
+		record.Set("max_active_requests_per_family", 2)
+		if err := app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+
+		family := testutil.CreateUser(t, app, nil)
+		for range 2 {
+			request := testutil.CreateRequest(t, app, family, map[string]any{"school_year": s.year})
+			if s.cancel {
+				if err := requests.Cancel(app, request); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		auth := family
+		if s.staff {
+			auth = testutil.CreateUser(t, app, map[string]any{"role": access.RoleStaff})
+		}
+		token, err := auth.NewAuthToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scenario := tests.ApiScenario{
+			Name:   s.name,
+			Method: http.MethodPost,
+			URL:    "/api/collections/requests/records",
+			Body: strings.NewReader(`{
+				"user": "` + family.Id + `",
+				"requester_name": "Test Family",
+				"requester_address": "Teststraße 1, 12345 Berlin",
+				"requester_phone": "+49301234567",
+				"student_name": "Mia Müller",
+				"student_class": "5a",
+				"school_year": "2024/25",
+				"status": "pending",
+				"submitted_at": "2024-08-01 10:00:00.000Z"
+			}`),
+			Headers:         map[string]string{"Authorization": token},
+			ExpectedStatus:  s.code,
+			ExpectedContent: []string{s.content},
+			TestAppFactory: func(testing.TB) *tests.TestApp {
+				return app
+			},
+		}
+		scenario.Test(t)
+	}
+}
+
+func TestConcurrentRequestsWithinQuota(t *testing.T) {
+	app := testutil.NewScenarioApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	record, err := settings.FindRecord(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Set("max_requests_per_hour", 0)
+	record.Set("max_active_requests_per_family", 2)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	family := testutil.CreateUser(t, app, nil)
+	testutil.CreateRequest(t, app, family, nil)
+	token, err := family.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := func(student string) string {
+		return `{
+			"user": "` + family.Id + `",
+			"requester_name": "Test Family",
+			"requester_address": "Teststraße 1, 12345 Berlin",
+			"requester_phone": "+49301234567",
+			"student_name": "` + student + `",
+			"student_class": "5a",
+			"school_year": "2024/25",
+			"status": "pending"
+		}`
+	}
+
+	// a second submission arrives while the first one is saved
+	var once sync.Once
+	var wg sync.WaitGroup
+	second := httptest.NewRecorder()
+
+	scenario := tests.ApiScenario{
+		Name:            "the quota holds for concurrent submissions",
+		Method:          http.MethodPost,
+		URL:             "/api/collections/requests/records",
+		Body:            strings.NewReader(body("Mia Müller")),
+		Headers:         map[string]string{"Authorization": token},
+		ExpectedStatus:  http.StatusOK,
+		ExpectedContent: []string{`"student_name":"Mia Müller"`},
+		BeforeTestFunc: func(t testing.TB, app *tests.TestApp, e *core.ServeEvent) {
+			mux, err := e.Router.BuildMux()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app.OnRecordCreate(requests.Collection).BindFunc(func(e *core.RecordEvent) error {
+				once.Do(func() {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						req := httptest.NewRequest(http.MethodPost, "/api/collections/requests/records", strings.NewReader(body("Tom Müller")))
+						req.Header.Set("content-type", "application/json")
+						req.Header.Set("Authorization", token)
+						mux.ServeHTTP(second, req)
+					}()
+					time.Sleep(100 * time.Millisecond)
+				})
+
+				return e.Next()
+			})
+		},
+		AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+			wg.Wait()
+			if second.Code != http.StatusBadRequest || !strings.Contains(second.Body.String(), "validation_request_quota") {
+				t.Fatalf("expected the second submission to exceed the quota, got %d %s", second.Code, second.Body.String())
+			}
+			testutil.AssertCount(t, app, requests.Collection, dbx.HashExp{"user": family.Id}, 2)
+		},
+		TestAppFactory: func(testing.TB) *tests.TestApp {
+			return app
+		},
+	}
+	scenario.Test(t)
+}
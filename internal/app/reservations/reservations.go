@@ -0,0 +1,144 @@
+// Package reservations manages the temporary locker holds created for new
+// requests until their invoice is paid.
+package reservations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+const (
+	// Collection is the name of the reservations collection.
+	Collection = "reservations"
+
+	requestsCollection    = "requests"
+	lockersCollection     = "lockers"
+	assignmentsCollection = "assignments"
+)
+
+// Create reserves locker for request until the configured payment deadline.
+func Create(app core.App, request *core.Record, locker *core.Record, now time.Time) (*core.Record, error) {
+	s, err := settings.Load(app)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := app.FindCollectionByNameOrId(Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation := core.NewRecord(collection)
+	reservation.Set("request", request.Id)
+	reservation.Set("locker", locker.Id)
+	reservation.Set("expires_at", now.AddDate(0, 0, s.ReservationDays))
+
+	if err := app.Save(reservation); err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// DeleteForRequest removes the reservations of a request, e.g. after payment or cancellation.
+func DeleteForRequest(app core.App, requestId string) error {
+	records, err := app.FindAllRecords(Collection, dbx.HashExp{"request": requestId})
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := app.Delete(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Expire releases all reservations whose deadline passed before now and
+// returns the number of expired reservations.
+//
+// The request is marked as expired, the reserved locker is freed and open
+// invoices are cancelled. Reservations of requests that were paid in the
+// meantime are only removed.
+func Expire(app core.App, now time.Time) (int, error) {
+	due, err := query.FindAll(app, Collection, query.Where("expires_at", query.OpLte, now.UTC().Format(types.DefaultDateLayout)), "expires_at", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, reservation := range due {
+		released := false
+
+		err := app.RunInTransaction(func(txApp core.App) error {
+			var err error
+			released, err = release(txApp, reservation)
+			return err
+		})
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire reservation %s: %w", reservation.Id, err)
+		}
+
+		if released {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+func release(app core.App, reservation *core.Record) (bool, error) {
+	request, err := app.FindRecordById(requestsCollection, reservation.GetString("request"))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	// paid (assigned) or cancelled requests no longer hold the reservation
+	if request == nil || request.GetString("status") != "reserved" {
+		return false, app.Delete(reservation)
+	}
+
+	request.Set("status", "expired")
+	if err := app.Save(request); err != nil {
+		return false, err
+	}
+
+	locker, err := app.FindRecordById(lockersCollection, reservation.GetString("locker"))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if locker != nil && locker.GetString("status") == "reserved" {
+		locker.Set("status", "free")
+		if err := app.Save(locker); err != nil {
+			return false, err
+		}
+	}
+
+	assignments, err := app.FindAllRecords(assignmentsCollection, dbx.HashExp{"request": request.Id})
+	if err != nil {
+		return false, err
+	}
+	for _, assignment := range assignments {
+		if err := app.Delete(assignment); err != nil {
+			return false, err
+		}
+	}
+
+	if err := invoices.CancelOpen(app, request.Id); err != nil {
+		return false, err
+	}
+
+	return true, app.Delete(reservation)
+}
@@ -0,0 +1,251 @@
+// Package retention removes personal data from closed school years once
+// their retention period passed, keeping the records needed for statistics
+// and accounting.
+package retention
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+// PseudonymKeyEnv names the environment variable with the secret that keys
+// the student pseudonyms. It is not stored in app_settings because the
+// database is part of every backup, and without it the pseudonyms of known
+// student names could be recomputed from a copy of the data.
+const PseudonymKeyEnv = "SPINDIT_PSEUDONYM_KEY"
+
+const (
+	emailsCollection       = "email_queue"
+	transactionsCollection = "bank_transactions"
+
+	anonymized      = "anonymized"
+	anonymizedPhone = "0000000"
+	anonymizedEmail = "anonymized@example.invalid"
+)
+
+var gradePattern = regexp.MustCompile(`^[0-9]+`)
+
+// Report summarizes an anonymization run.
+type Report struct {
+	// RequestsUntil is the last school year whose requests are past
+	// retention, empty when request retention is disabled.
+	RequestsUntil string `json:"requests_until,omitempty"`
+	// EmailsBefore is the queue date before which emails are past
+	// retention, nil when email retention is disabled.
+	EmailsBefore *time.Time `json:"emails_before,omitempty"`
+
+	// Requests is the number of pseudonymized requests.
+	Requests int `json:"requests"`
+	// Transactions is the number of anonymized bank transactions.
+	Transactions int `json:"transactions"`
+	// Emails is the number of anonymized emails.
+	Emails int `json:"emails"`
+	// Skipped counts requests past retention that are still active, e.g.
+	// an assignment that was never released, and were left unchanged.
+	Skipped int `json:"skipped"`
+}
+
+// Anonymize removes the personal data past the retention periods of the
+// settings at now.
+//
+// Requests of school years that ended more than RetentionRequestsMonths
+// before now are pseudonymized: requester name, address and phone are
+// replaced, the student name becomes a pseudonym that stays the same for
+// the family's student across years (random per request without
+// [PseudonymKeyEnv]) and the class is reduced to its grade.
+// School year, zone, locker, status and the linked reservations,
+// assignments and invoices are kept for statistics and accounting. Bank
+// transactions booked in these school years lose payer and remittance
+// text, unless they still wait for review.
+//
+// Sent or failed emails queued more than RetentionEmailsMonths before now
+// lose their recipient, subject and payload.
+func Anonymize(app core.App, now time.Time) (Report, error) {
+	report := Report{}
+
+	s, err := settings.Load(app)
+	if err != nil {
+		return report, err
+	}
+
+	if s.RetentionRequestsMonths > 0 {
+		report.RequestsUntil = LastExpiredSchoolYear(now, s.RetentionRequestsMonths)
+		if err := anonymizeRequests(app, now, &report); err != nil {
+			return report, err
+		}
+		if report.Requests > 0 && os.Getenv(PseudonymKeyEnv) == "" {
+			app.Logger().Warn("student pseudonyms are random per request, set "+PseudonymKeyEnv+" to keep them stable across years", "requests", report.Requests)
+		}
+		if err := anonymizeTransactions(app, now, &report); err != nil {
+			return report, err
+		}
+	}
+
+	if s.RetentionEmailsMonths > 0 {
+		before := now.AddDate(0, -s.RetentionEmailsMonths, 0)
+		report.EmailsBefore = &before
+		if err := anonymizeEmails(app, now, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// LastExpiredSchoolYear returns the latest school year that ended at least
+// months before now. School years end on 1 August, e.g. "2023/24" ends on
+// 1 August 2024.
+func LastExpiredSchoolYear(now time.Time, months int) string {
+	limit := now.In(clock.Location()).AddDate(0, -months, 0)
+
+	end := limit.Year()
+	if limit.Before(time.Date(end, time.August, 1, 0, 0, 0, 0, clock.Location())) {
+		end--
+	}
+
+	return fmt.Sprintf("%d/%02d", end-1, end%100)
+}
+
+func anonymizeRequests(app core.App, now time.Time, report *Report) error {
+	due, err := query.FindAll(app, requests.Collection, query.And(
+		query.Where("school_year", query.OpLte, report.RequestsUntil),
+		query.Eq("anonymized_at", ""),
+	), "school_year", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, request := range due {
+		if requests.IsActiveStatus(request.GetString("status")) {
+			report.Skipped++
+			continue
+		}
+
+		if err := PseudonymizeRequest(app, request, now); err != nil {
+			return fmt.Errorf("failed to anonymize request %s: %w", request.Id, err)
+		}
+
+		report.Requests++
+	}
+
+	return nil
+}
+
+func anonymizeTransactions(app core.App, now time.Time, report *Report) error {
+	start, _ := strconv.Atoi(report.RequestsUntil[:4])
+	end := time.Date(start+1, time.August, 1, 0, 0, 0, 0, clock.Location())
+
+	due, err := query.FindAll(app, transactionsCollection, query.And(
+		query.Where("booked_at", query.OpLt, end.UTC().Format(types.DefaultDateLayout)),
+		query.Eq("anonymized_at", ""),
+		// the review queue keeps the payer until staff resolved it
+		query.Or(query.Eq("status", "matched"), query.Eq("status", "resolved")),
+	), "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, transaction := range due {
+		if err := AnonymizeTransaction(app, transaction, now); err != nil {
+			return fmt.Errorf("failed to anonymize bank transaction %s: %w", transaction.Id, err)
+		}
+
+		report.Transactions++
+	}
+
+	return nil
+}
+
+func anonymizeEmails(app core.App, now time.Time, report *Report) error {
+	before := report.EmailsBefore.UTC().Format(types.DefaultDateLayout)
+
+	due, err := query.FindAll(app, emailsCollection, query.And(
+		query.Or(query.Eq("status", "sent"), query.Eq("status", "failed")),
+		query.Eq("anonymized_at", ""),
+		query.Or(
+			query.And(query.Where("created", query.OpNeq, ""), query.Where("created", query.OpLt, before)),
+			// entries queued before the created date was recorded
+			query.And(query.Eq("created", ""), query.Where("sent_at", query.OpNeq, ""), query.Where("sent_at", query.OpLt, before)),
+		),
+	), "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range due {
+		email.Set("recipient", anonymizedEmail)
+		email.Set("subject", anonymized)
+		email.Set("payload", nil)
+		email.Set("anonymized_at", now)
+		if err := app.Save(email); err != nil {
+			return fmt.Errorf("failed to anonymize email %s: %w", email.Id, err)
+		}
+
+		report.Emails++
+	}
+
+	return nil
+}
+
+// PseudonymizeRequest replaces the personal fields of request and marks it
+// as anonymized at now, see [Anonymize].
+func PseudonymizeRequest(app core.App, request *core.Record, now time.Time) error {
+	request.Set("student_name", pseudonym(request))
+	request.Set("student_class", grade(request.GetString("student_class")))
+	request.Set("requester_name", anonymized)
+	request.Set("requester_address", anonymized)
+	request.Set("requester_phone", anonymizedPhone)
+	request.Set("anonymized_at", now)
+
+	return app.Save(request)
+}
+
+// AnonymizeTransaction removes the payer and remittance text of a bank
+// transaction and marks it as anonymized at now. Amount, booking date and
+// the matched invoice are kept for accounting.
+func AnonymizeTransaction(app core.App, transaction *core.Record, now time.Time) error {
+	transaction.Set("payer", anonymized)
+	transaction.Set("reference", anonymized)
+	transaction.Set("anonymized_at", now)
+
+	return app.Save(transaction)
+}
+
+// pseudonym derives a stable student pseudonym from the family and the
+// normalized student name, keyed with [PseudonymKeyEnv], so renewals of the
+// same student stay countable. Without the key it is random.
+func pseudonym(request *core.Record) string {
+	key := os.Getenv(PseudonymKeyEnv)
+	if key == "" {
+		return "Student " + security.RandomStringWithAlphabet(12, "0123456789abcdef")
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(request.GetString("user") + "\x00" + requests.StudentKey(request.GetString("student_name"))))
+
+	return "Student " + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// grade keeps the grade of a class, e.g. "7" of "7b".
+func grade(class string) string {
+	if g := gradePattern.FindString(class); g != "" {
+		return g
+	}
+
+	return "-"
+}
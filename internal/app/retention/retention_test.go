@@ -0,0 +1,163 @@
+package retention_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/retention"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestRetentionAnonymization(t *testing.T) {
+	t.Setenv(retention.PseudonymKeyEnv, "test-pseudonym-key")
+
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	family := testutil.CreateUser(t, app, nil)
+	closed := func(data map[string]any) *core.Record {
+		request := testutil.CreateRequest(t, app, family, data)
+		if err := requests.Cancel(app, request); err != nil {
+			t.Fatal(err)
+		}
+		return testutil.Reload(t, app, request)
+	}
+	old := closed(map[string]any{"school_year": "2021/22", "student_name": "Mia Müller", "student_class": "7b"})
+	older := closed(map[string]any{"school_year": "2020/21", "student_name": "mia  müller"})
+	recent := closed(map[string]any{"school_year": "2022/23"})
+	active := testutil.CreateRequest(t, app, family, map[string]any{"school_year": "2021/22"})
+
+	emails, err := app.FindCollectionByNameOrId("email_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := func(created string) *core.Record {
+		email := core.NewRecord(emails)
+		email.Load(map[string]any{
+			"recipient": family.Email(),
+			"subject":   "Your locker",
+			"template":  "locker_assigned",
+			"payload":   map[string]any{"student": "Mia Müller"},
+			"status":    "sent",
+			"sent_at":   created,
+		})
+		if err := app.Save(email); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.DB().Update("email_queue", dbx.Params{"created": created}, dbx.HashExp{"id": email.Id}).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return email
+	}
+	oldEmail := queue("2023-09-01 08:00:00.000Z")
+	recentEmail := queue("2024-07-01 08:00:00.000Z")
+
+	transactions, err := app.FindCollectionByNameOrId("bank_transactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := func(bookedAt string, status string) *core.Record {
+		transaction := core.NewRecord(transactions)
+		transaction.Load(map[string]any{
+			"booked_at":   bookedAt,
+			"amount":      20,
+			"currency":    "EUR",
+			"payer":       "Familie Müller",
+			"reference":   "Spind Mia Müller",
+			"fingerprint": "sha:" + core.GenerateDefaultRandomId(),
+			"status":      status,
+		})
+		if err := app.Save(transaction); err != nil {
+			t.Fatal(err)
+		}
+		return transaction
+	}
+	oldTransaction := book("2022-03-01 00:00:00.000Z", "matched")
+	unmatchedTransaction := book("2022-03-01 00:00:00.000Z", "unmatched")
+	recentTransaction := book("2023-03-01 00:00:00.000Z", "matched")
+
+	dryRun, err := cronjobs.Run(app, "retention.anonymize", cronjobs.RunOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if dryRun.GetInt("affected") != 4 {
+		t.Fatalf("expected the dry run to report 4 records, got %v", dryRun.Get("details"))
+	}
+	testutil.AssertString(t, "student after dry run", testutil.Reload(t, app, old).GetString("student_name"), "Mia Müller")
+
+	run, err := cronjobs.Run(app, "retention.anonymize", cronjobs.RunOptions{})
+	if err != nil {
+		t.Fatalf("retention run failed: %v", err)
+	}
+	if run.GetInt("affected") != 4 {
+		t.Fatalf("expected 4 anonymized records, got %v", run.Get("details"))
+	}
+
+	old = testutil.Reload(t, app, old)
+	testutil.AssertString(t, "requester name", old.GetString("requester_name"), "anonymized")
+	testutil.AssertString(t, "student class", old.GetString("student_class"), "7")
+	testutil.AssertString(t, "school year", old.GetString("school_year"), "2021/22")
+	if strings.Contains(old.GetString("student_name"), "Mia") {
+		t.Fatalf("expected a pseudonymized student name, got %q", old.GetString("student_name"))
+	}
+	testutil.AssertString(t, "pseudonym across years", testutil.Reload(t, app, older).GetString("student_name"), old.GetString("student_name"))
+
+	testutil.AssertString(t, "recent requester name", testutil.Reload(t, app, recent).GetString("requester_name"), recent.GetString("requester_name"))
+	testutil.AssertString(t, "active requester name", testutil.Reload(t, app, active).GetString("requester_name"), active.GetString("requester_name"))
+
+	testutil.AssertString(t, "old email recipient", testutil.Reload(t, app, oldEmail).GetString("recipient"), "anonymized@example.invalid")
+	testutil.AssertString(t, "recent email recipient", testutil.Reload(t, app, recentEmail).GetString("recipient"), family.Email())
+
+	testutil.AssertString(t, "old payer", testutil.Reload(t, app, oldTransaction).GetString("payer"), "anonymized")
+	testutil.AssertString(t, "old remittance", testutil.Reload(t, app, oldTransaction).GetString("reference"), "anonymized")
+	testutil.AssertString(t, "payer in review", testutil.Reload(t, app, unmatchedTransaction).GetString("payer"), "Familie Müller")
+	testutil.AssertString(t, "recent payer", testutil.Reload(t, app, recentTransaction).GetString("payer"), "Familie Müller")
+
+	again, err := cronjobs.Run(app, "retention.anonymize", cronjobs.RunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.GetInt("affected") != 0 {
+		t.Fatalf("expected anonymized records to be skipped, got %v", again.Get("details"))
+	}
+}
+
+func TestPseudonymKey(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	now := testutil.FreezeClock(app, testutil.Start).Now()
+
+	family := testutil.CreateUser(t, app, nil)
+	pseudonymize := func() string {
+		t.Helper()
+		request := testutil.CreateRequest(t, app, family, map[string]any{"school_year": "2021/22", "student_name": "Mia Müller"})
+		if err := requests.Cancel(app, request); err != nil {
+			t.Fatal(err)
+		}
+		request = testutil.Reload(t, app, request)
+		if err := retention.PseudonymizeRequest(app, request, now); err != nil {
+			t.Fatal(err)
+		}
+		return testutil.Reload(t, app, request).GetString("student_name")
+	}
+
+	t.Setenv(retention.PseudonymKeyEnv, "first-key")
+	first := pseudonymize()
+	if again := pseudonymize(); again != first {
+		t.Fatalf("expected a stable pseudonym, got %q and %q", first, again)
+	}
+
+	t.Setenv(retention.PseudonymKeyEnv, "second-key")
+	if other := pseudonymize(); other == first {
+		t.Fatalf("expected the pseudonym to depend on the key, got %q twice", other)
+	}
+
+	t.Setenv(retention.PseudonymKeyEnv, "")
+	if random, again := pseudonymize(), pseudonymize(); random == again {
+		t.Fatalf("expected random pseudonyms without a key, got %q twice", random)
+	}
+}
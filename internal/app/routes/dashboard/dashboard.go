@@ -0,0 +1,299 @@
+package dashboard
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+)
+
+const (
+	// Route exposes the aggregated staff dashboard statistics.
+	Route = "/api/spindit/staff/dashboard"
+
+	cacheTTL         = 30 * time.Second
+	expiringWindow   = 24 * time.Hour
+	newUsersInterval = 7 * 24 * time.Hour
+)
+
+// Stats is the payload returned by the staff dashboard endpoint.
+type Stats struct {
+	GeneratedAt              types.DateTime        `json:"generated_at"`
+	Users                    UserStats             `json:"users"`
+	Zones                    int                   `json:"zones"`
+	Occupancy                Occupancy             `json:"occupancy"`
+	Requests                 RequestFunnel         `json:"requests"`
+	OutstandingInvoices      []OutstandingInvoices `json:"outstanding_invoices"`
+	ReservationsExpiringSoon int                   `json:"reservations_expiring_soon"`
+	WaitlistDepth            int                   `json:"waitlist_depth"`
+}
+
+// UserStats summarizes registered accounts.
+type UserStats struct {
+	Total       int `json:"total"`
+	NewThisWeek int `json:"new_this_week"`
+}
+
+// Occupancy groups locker counts by zone and status.
+type Occupancy struct {
+	Total    int             `json:"total"`
+	Statuses map[string]int  `json:"statuses"`
+	Zones    []ZoneOccupancy `json:"zones"`
+}
+
+// ZoneOccupancy holds the locker counts of a single zone.
+type ZoneOccupancy struct {
+	Id       string         `json:"id"`
+	Name     string         `json:"name"`
+	Total    int            `json:"total"`
+	Statuses map[string]int `json:"statuses"`
+}
+
+// RequestFunnel groups request counts by school year and status.
+type RequestFunnel struct {
+	Total       int                `json:"total"`
+	Statuses    map[string]int     `json:"statuses"`
+	SchoolYears []SchoolYearFunnel `json:"school_years"`
+}
+
+// SchoolYearFunnel holds the request counts of a single school year.
+type SchoolYearFunnel struct {
+	SchoolYear string         `json:"school_year"`
+	Total      int            `json:"total"`
+	Statuses   map[string]int `json:"statuses"`
+}
+
+// OutstandingInvoices sums the open (draft or sent) invoices of a currency.
+type OutstandingInvoices struct {
+	Currency string  `db:"currency" json:"currency"`
+	Count    int     `db:"count" json:"count"`
+	Amount   float64 `db:"amount" json:"amount"`
+	Overdue  int     `db:"overdue" json:"overdue"`
+}
+
+// Register exposes the staff dashboard statistics route.
+func Register(app core.App) {
+	c := &cache{}
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(Route, func(e *core.RequestEvent) error {
+			stats, err := c.get(e.App)
+			if err != nil {
+				return e.InternalServerError("Failed to compute the dashboard statistics.", err)
+			}
+
+			return e.JSON(http.StatusOK, stats)
+		}).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+type cache struct {
+	mu      sync.Mutex
+	stats   *Stats
+	expires time.Time
+}
+
+func (c *cache) get(app core.App) (*Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the cache expires in wall clock time, the statistics use the app clock
+	now := time.Now()
+	if c.stats != nil && now.Before(c.expires) {
+		return c.stats, nil
+	}
+
+	stats, err := Compute(app, clock.Now(app))
+	if err != nil {
+		return nil, err
+	}
+
+	c.stats = stats
+	c.expires = now.Add(cacheTTL)
+
+	return stats, nil
+}
+
+// Compute aggregates the dashboard statistics relative to the given time.
+func Compute(app core.App, now time.Time) (*Stats, error) {
+	generatedAt, err := types.ParseDateTime(now)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{GeneratedAt: generatedAt}
+
+	if err := countUsers(app, now, &stats.Users); err != nil {
+		return nil, err
+	}
+
+	if err := app.DB().NewQuery("SELECT COUNT(*) FROM zones").Row(&stats.Zones); err != nil {
+		return nil, err
+	}
+
+	if err := aggregateOccupancy(app, &stats.Occupancy); err != nil {
+		return nil, err
+	}
+
+	if err := aggregateRequests(app, &stats.Requests); err != nil {
+		return nil, err
+	}
+
+	invoices, err := aggregateInvoices(app, now)
+	if err != nil {
+		return nil, err
+	}
+	stats.OutstandingInvoices = invoices
+
+	err = app.DB().NewQuery(`
+		SELECT COUNT(*) FROM reservations
+		WHERE expires_at >= {:from} AND expires_at < {:until}
+	`).Bind(dbx.Params{
+		"from":  formatDate(now),
+		"until": formatDate(now.Add(expiringWindow)),
+	}).Row(&stats.ReservationsExpiringSoon)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.DB().NewQuery(`
+		SELECT COUNT(*) FROM requests r
+		WHERE r.status = 'pending'
+		AND NOT EXISTS (SELECT 1 FROM assignments a WHERE a.request = r.id)
+	`).Row(&stats.WaitlistDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func countUsers(app core.App, now time.Time, out *UserStats) error {
+	return app.DB().NewQuery(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN created >= {:since} THEN 1 ELSE 0 END), 0)
+		FROM users
+	`).Bind(dbx.Params{
+		"since": formatDate(now.Add(-newUsersInterval)),
+	}).Row(&out.Total, &out.NewThisWeek)
+}
+
+func aggregateOccupancy(app core.App, out *Occupancy) error {
+	rows := []struct {
+		Zone   string `db:"zone"`
+		Name   string `db:"name"`
+		Status string `db:"status"`
+		Total  int    `db:"total"`
+	}{}
+
+	err := app.DB().NewQuery(`
+		SELECT l.zone AS zone, COALESCE(z.name, '') AS name, l.status AS status, COUNT(*) AS total
+		FROM lockers l
+		LEFT JOIN zones z ON z.id = l.zone
+		GROUP BY l.zone, l.status
+		ORDER BY name, l.zone
+	`).All(&rows)
+	if err != nil {
+		return err
+	}
+
+	out.Statuses = map[string]int{}
+	out.Zones = []ZoneOccupancy{}
+
+	indexes := map[string]int{}
+	for _, row := range rows {
+		idx, ok := indexes[row.Zone]
+		if !ok {
+			idx = len(out.Zones)
+			indexes[row.Zone] = idx
+			out.Zones = append(out.Zones, ZoneOccupancy{
+				Id:       row.Zone,
+				Name:     row.Name,
+				Statuses: map[string]int{},
+			})
+		}
+
+		out.Zones[idx].Statuses[row.Status] += row.Total
+		out.Zones[idx].Total += row.Total
+		out.Statuses[row.Status] += row.Total
+		out.Total += row.Total
+	}
+
+	return nil
+}
+
+func aggregateRequests(app core.App, out *RequestFunnel) error {
+	rows := []struct {
+		SchoolYear string `db:"school_year"`
+		Status     string `db:"status"`
+		Total      int    `db:"total"`
+	}{}
+
+	err := app.DB().NewQuery(`
+		SELECT school_year, status, COUNT(*) AS total
+		FROM requests
+		GROUP BY school_year, status
+		ORDER BY school_year DESC
+	`).All(&rows)
+	if err != nil {
+		return err
+	}
+
+	out.Statuses = map[string]int{}
+	out.SchoolYears = []SchoolYearFunnel{}
+
+	indexes := map[string]int{}
+	for _, row := range rows {
+		idx, ok := indexes[row.SchoolYear]
+		if !ok {
+			idx = len(out.SchoolYears)
+			indexes[row.SchoolYear] = idx
+			out.SchoolYears = append(out.SchoolYears, SchoolYearFunnel{
+				SchoolYear: row.SchoolYear,
+				Statuses:   map[string]int{},
+			})
+		}
+
+		out.SchoolYears[idx].Statuses[row.Status] += row.Total
+		out.SchoolYears[idx].Total += row.Total
+		out.Statuses[row.Status] += row.Total
+		out.Total += row.Total
+	}
+
+	return nil
+}
+
+func aggregateInvoices(app core.App, now time.Time) ([]OutstandingInvoices, error) {
+	rows := []OutstandingInvoices{}
+
+	err := app.DB().NewQuery(`
+		SELECT
+			currency,
+			COUNT(*) AS count,
+			COALESCE(SUM(amount), 0) AS amount,
+			COALESCE(SUM(CASE WHEN due_at != '' AND due_at < {:now} THEN 1 ELSE 0 END), 0) AS overdue
+		FROM invoices
+		WHERE status IN ('draft', 'sent')
+		GROUP BY currency
+		ORDER BY currency
+	`).Bind(dbx.Params{
+		"now": formatDate(now),
+	}).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// formatDate converts t into the text representation PocketBase uses for date columns.
+func formatDate(t time.Time) string {
+	return t.UTC().Format(types.DefaultDateLayout)
+}
@@ -0,0 +1,75 @@
+// Package devclock exposes a dev-only endpoint to time travel the server clock.
+package devclock
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+)
+
+// Route reads (GET) and changes (POST) the app clock.
+const Route = "/api/spindit/dev/clock"
+
+type clockState struct {
+	Now    string `json:"now"`
+	Fake   bool   `json:"fake"`
+	Offset string `json:"offset"`
+}
+
+type clockInput struct {
+	// Now is the fake time (RFC 3339, "2006-01-02 15:04" or "2006-01-02"); empty resets the clock.
+	Now string `json:"now"`
+}
+
+// Register exposes the clock endpoint to superusers when the server runs with --dev.
+func Register(app core.App) {
+	if !app.IsDev() {
+		return
+	}
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(Route, func(e *core.RequestEvent) error {
+			return e.JSON(http.StatusOK, state(e.App))
+		}).Bind(apis.RequireSuperuserAuth())
+
+		se.Router.POST(Route, func(e *core.RequestEvent) error {
+			input := clockInput{}
+			if err := e.BindBody(&input); err != nil {
+				return e.BadRequestError("Invalid clock payload.", err)
+			}
+
+			if input.Now == "" {
+				clock.Set(e.App, nil)
+			} else {
+				now, err := clock.Parse(input.Now, clock.Location())
+				if err != nil {
+					return e.BadRequestError("Invalid time, use RFC 3339 or YYYY-MM-DD [HH:MM].", err)
+				}
+				clock.Set(e.App, clock.NewOffset(now))
+			}
+
+			current := state(e.App)
+			e.App.Logger().Warn("app clock changed", "now", current.Now, "offset", current.Offset)
+
+			return e.JSON(http.StatusOK, current)
+		}).Bind(apis.RequireSuperuserAuth())
+
+		return se.Next()
+	})
+}
+
+func state(app core.App) clockState {
+	c := clock.Get(app)
+
+	s := clockState{Now: c.Now().Format(time.RFC3339)}
+	if offset, ok := c.(*clock.Offset); ok {
+		s.Fake = true
+		s.Offset = offset.Offset().Round(time.Second).String()
+	}
+
+	return s
+}
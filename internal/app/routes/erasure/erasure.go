@@ -0,0 +1,103 @@
+package erasure
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/erasure"
+)
+
+const (
+	// MeRoute checks (GET) or performs (POST) the erasure of the authenticated user.
+	MeRoute = "/api/spindit/me/erasure"
+	// UserRoute lets staff check (GET) or perform (POST) the erasure of any user.
+	UserRoute = "/api/spindit/staff/users/{id}/erasure"
+)
+
+// Register exposes the right to erasure routes.
+//
+// GET returns the [erasure.Plan] with its blockers. POST expects
+// {"confirm": true} and erases the account, answering 409 with the plan
+// while blockers remain.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(MeRoute, handleCheckMe).Bind(apis.RequireAuth("users"))
+		se.Router.POST(MeRoute, handleEraseMe).Bind(apis.RequireAuth("users"))
+		se.Router.GET(UserRoute, handleCheckUser).Bind(access.RequireStaff())
+		se.Router.POST(UserRoute, handleEraseUser).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleCheckMe(e *core.RequestEvent) error {
+	return check(e, e.Auth)
+}
+
+func handleEraseMe(e *core.RequestEvent) error {
+	return erase(e, e.Auth)
+}
+
+func handleCheckUser(e *core.RequestEvent) error {
+	user, err := findUser(e)
+	if err != nil {
+		return err
+	}
+
+	return check(e, user)
+}
+
+func handleEraseUser(e *core.RequestEvent) error {
+	user, err := findUser(e)
+	if err != nil {
+		return err
+	}
+
+	return erase(e, user)
+}
+
+func findUser(e *core.RequestEvent) (*core.Record, error) {
+	user, err := e.App.FindRecordById("users", e.Request.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, e.NotFoundError("Unknown user.", err)
+	}
+	if err != nil {
+		return nil, e.InternalServerError("Failed to load the user.", err)
+	}
+
+	return user, nil
+}
+
+func check(e *core.RequestEvent, user *core.Record) error {
+	plan, err := erasure.Check(e.App, user, clock.Now(e.App))
+	if err != nil {
+		return e.InternalServerError("Failed to check the erasure.", err)
+	}
+
+	return e.JSON(http.StatusOK, plan)
+}
+
+func erase(e *core.RequestEvent, user *core.Record) error {
+	body := struct {
+		Confirm bool `json:"confirm"`
+	}{}
+	if err := e.BindBody(&body); err != nil || !body.Confirm {
+		return e.BadRequestError(`Confirm the erasure with {"confirm": true}.`, err)
+	}
+
+	plan, err := erasure.Erase(e.App, user, e.Auth, clock.Now(e.App))
+	if errors.Is(err, erasure.ErrBlocked) {
+		return e.JSON(http.StatusConflict, plan)
+	}
+	if err != nil {
+		return e.InternalServerError("Failed to erase the account.", err)
+	}
+
+	return e.JSON(http.StatusOK, plan)
+}
@@ -0,0 +1,111 @@
+package erasure_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tests"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/erasure"
+	routes "github.com/jryannel/spindit/internal/app/routes/erasure"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestEraseMe(t *testing.T) {
+	app := testutil.NewScenarioApp(t)
+
+	family := testutil.CreateUser(t, app, nil)
+	token, err := family.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenario := tests.ApiScenario{
+		Name:            "a family erases its own account",
+		Method:          http.MethodPost,
+		URL:             routes.MeRoute,
+		Body:            strings.NewReader(`{"confirm": true}`),
+		Headers:         map[string]string{"Authorization": token},
+		ExpectedStatus:  http.StatusOK,
+		ExpectedContent: []string{`"account_deleted":true`, `"applied":true`},
+		TestAppFactory: func(testing.TB) *tests.TestApp {
+			return app
+		},
+		AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+			testutil.AssertCount(t, app, "users", dbx.HashExp{"id": family.Id}, 0)
+			testutil.AssertCount(t, app, "audit_logs", dbx.HashExp{
+				"action":    erasure.AuditAction,
+				"record_id": family.Id,
+				"actor":     "",
+			}, 1)
+		},
+	}
+	scenario.Test(t)
+}
+
+func TestEraseUser(t *testing.T) {
+	app := testutil.NewScenarioApp(t)
+
+	staff := testutil.CreateUser(t, app, map[string]any{"role": access.RoleStaff})
+	family := testutil.CreateUser(t, app, nil)
+	token, err := staff.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenario := tests.ApiScenario{
+		Name:            "staff erase a family account",
+		Method:          http.MethodPost,
+		URL:             strings.Replace(routes.UserRoute, "{id}", family.Id, 1),
+		Body:            strings.NewReader(`{"confirm": true}`),
+		Headers:         map[string]string{"Authorization": token},
+		ExpectedStatus:  http.StatusOK,
+		ExpectedContent: []string{`"account_deleted":true`},
+		TestAppFactory: func(testing.TB) *tests.TestApp {
+			return app
+		},
+		AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+			testutil.AssertCount(t, app, "audit_logs", dbx.HashExp{
+				"action":    erasure.AuditAction,
+				"record_id": family.Id,
+				"actor":     staff.Id,
+			}, 1)
+		},
+	}
+	scenario.Test(t)
+}
+
+func TestRecordsAPIDoesNotDeleteUsers(t *testing.T) {
+	for _, role := range []string{access.RoleFamily, access.RoleStaff, access.RoleAdmin} {
+		app := testutil.NewScenarioApp(t)
+
+		family := testutil.CreateUser(t, app, nil)
+		auth := family
+		if role != access.RoleFamily {
+			auth = testutil.CreateUser(t, app, map[string]any{"role": role})
+		}
+		token, err := auth.NewAuthToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scenario := tests.ApiScenario{
+			Name:            role + " deletes a family account through the records API",
+			Method:          http.MethodDelete,
+			URL:             "/api/collections/users/records/" + family.Id,
+			Headers:         map[string]string{"Authorization": token},
+			ExpectedStatus:  http.StatusForbidden,
+			ExpectedContent: []string{`"data":{}`},
+			TestAppFactory: func(testing.TB) *tests.TestApp {
+				return app
+			},
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				testutil.AssertCount(t, app, "users", dbx.HashExp{"id": family.Id}, 1)
+			},
+		}
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/export"
+)
+
+const (
+	// MeRoute downloads the data export of the authenticated user.
+	MeRoute = "/api/spindit/me/export"
+	// UserRoute lets staff download the data export of any user.
+	UserRoute = "/api/spindit/staff/users/{id}/export"
+)
+
+// Register exposes the personal data export routes.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(MeRoute, handleMe).Bind(apis.RequireAuth("users"))
+		se.Router.GET(UserRoute, handleUser).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleMe(e *core.RequestEvent) error {
+	return writeExport(e, e.Auth)
+}
+
+func handleUser(e *core.RequestEvent) error {
+	user, err := e.App.FindRecordById("users", e.Request.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e.NotFoundError("Unknown user.", err)
+	}
+	if err != nil {
+		return e.InternalServerError("Failed to load the user.", err)
+	}
+
+	e.App.Logger().Info("staff exported user data", "user", user.Id, "actor", e.Auth.Id)
+
+	return writeExport(e, user)
+}
+
+func writeExport(e *core.RequestEvent, user *core.Record) error {
+	data, err := export.Collect(e.App, user)
+	if err != nil {
+		return e.InternalServerError("Failed to collect the data export.", err)
+	}
+
+	now := clock.Now(e.App)
+	e.Response.Header().Set("Content-Type", "application/zip")
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename(user, now)))
+	e.Response.WriteHeader(http.StatusOK)
+
+	// The headers are already sent at this point, so failures can only be logged.
+	if err := export.WriteZip(e.App, e.Response, data, now); err != nil {
+		e.App.Logger().Error("failed to stream data export", "user", user.Id, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package girocode
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/invoices"
+)
+
+// ImageRoute returns the GiroCode of an open invoice of the family as PNG.
+const ImageRoute = "/api/spindit/me/invoices/{id}/girocode.png"
+
+const (
+	defaultScale = 8
+	maxScale     = 20
+)
+
+// Register exposes the GiroCode image route.
+//
+// Families get the codes of their own invoices, staff of any invoice. The
+// optional "scale" query parameter sets the pixels per module (default 8).
+// The route responds with 503 while no payee bank account is configured.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(ImageRoute, handleImage).Bind(apis.RequireAuth("users"))
+
+		return se.Next()
+	})
+}
+
+func handleImage(e *core.RequestEvent) error {
+	invoice, err := e.App.FindRecordById(invoices.Collection, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Unknown invoice.", err)
+	}
+	if !access.IsStaff(e.Auth) {
+		request, err := e.App.FindRecordById("requests", invoice.GetString("request"))
+		if err != nil || request.GetString("user") != e.Auth.Id {
+			return e.NotFoundError("Unknown invoice.", err)
+		}
+	}
+
+	if invoice.GetString("status") != invoices.StatusSent {
+		return e.BadRequestError("The invoice is not open.", nil)
+	}
+
+	scale := defaultScale
+	if value := e.Request.URL.Query().Get("scale"); value != "" {
+		scale, err = strconv.Atoi(value)
+		if err != nil || scale < 1 || scale > maxScale {
+			return e.BadRequestError("Invalid scale.", err)
+		}
+	}
+
+	giro, err := invoices.GiroCode(e.App, invoice)
+	if errors.Is(err, invoices.ErrNoPayeeAccount) {
+		return e.Error(http.StatusServiceUnavailable, "Bank transfer details are not available.", nil)
+	}
+	if err != nil {
+		return e.BadRequestError("The invoice cannot be paid by bank transfer.", err)
+	}
+
+	code, err := giro.QRCode()
+	if err != nil {
+		return e.InternalServerError("Failed to encode the GiroCode.", err)
+	}
+
+	var buf bytes.Buffer
+	if err := code.WritePNG(&buf, scale); err != nil {
+		return e.InternalServerError("Failed to render the GiroCode.", err)
+	}
+
+	e.Response.Header().Set("Cache-Control", "private, no-cache")
+
+	return e.Blob(http.StatusOK, "image/png", buf.Bytes())
+}
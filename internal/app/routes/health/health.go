@@ -0,0 +1,54 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/health"
+	"github.com/jryannel/spindit/internal/app/routes/metrics"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+const (
+	// LiveRoute is the liveness probe.
+	LiveRoute = "/api/spindit/health"
+	// ReadyRoute is the readiness probe.
+	ReadyRoute = "/api/spindit/ready"
+)
+
+// Register exposes the public liveness and readiness probes.
+//
+// Both respond with the list of checks, with status 200 when all of them
+// passed and 503 otherwise. The check messages are only included for
+// requests with the metrics token ("Authorization: Bearer <metrics_token>").
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		started := clock.Now(se.App)
+
+		se.Router.GET(LiveRoute, func(e *core.RequestEvent) error {
+			return respond(e, health.Live(e.App))
+		})
+		se.Router.GET(ReadyRoute, func(e *core.RequestEvent) error {
+			return respond(e, health.Ready(e.App, clock.Now(e.App), started))
+		})
+
+		return se.Next()
+	})
+}
+
+func respond(e *core.RequestEvent, report *health.Report) error {
+	e.Response.Header().Set("Cache-Control", "no-store")
+
+	s, err := settings.Load(e.App)
+	if err != nil || !metrics.Authorized(e, s) {
+		report = report.Public()
+	}
+
+	if !report.OK() {
+		return e.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return e.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,64 @@
+package health_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/tests"
+
+	"github.com/jryannel/spindit/internal/app/routes/health"
+	"github.com/jryannel/spindit/internal/app/settings"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestReadyDetails(t *testing.T) {
+	scenarios := []struct {
+		name       string
+		headers    map[string]string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:       "public probes only see the check names",
+			expected:   []string{`"name":"database","ok":true}`, `"name":"mailer","ok":false}`},
+			unexpected: []string{`"message"`},
+		},
+		{
+			name:       "a wrong token only sees the check names",
+			headers:    map[string]string{"Authorization": "Bearer wrong"},
+			unexpected: []string{`"message"`},
+		},
+		{
+			name:     "the metrics token sees the messages",
+			headers:  map[string]string{"Authorization": "Bearer metrics-secret"},
+			expected: []string{`"message":"writable"`, `"message":"SMTP is not enabled`},
+		},
+	}
+
+	for _, s := range scenarios {
+		app := testutil.NewScenarioApp(t)
+
+		record, err := settings.FindRecord(app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record.Set("metrics_token", "metrics-secret")
+		if err := app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+
+		scenario := tests.ApiScenario{
+			Name:               s.name,
+			Method:             http.MethodGet,
+			URL:                health.ReadyRoute,
+			Headers:            s.headers,
+			ExpectedStatus:     http.StatusServiceUnavailable,
+			ExpectedContent:    append([]string{`"status":"fail"`}, s.expected...),
+			NotExpectedContent: s.unexpected,
+			TestAppFactory: func(testing.TB) *tests.TestApp {
+				return app
+			},
+		}
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/cronjobs"
+	"github.com/jryannel/spindit/internal/app/query"
+)
+
+const (
+	// ListRoute lists the background jobs with their latest run.
+	ListRoute = "/api/spindit/staff/jobs"
+	// RunRoute triggers a job on demand.
+	RunRoute = "/api/spindit/staff/jobs/{id}/run"
+)
+
+type jobInfo struct {
+	Id       string       `json:"id"`
+	Schedule string       `json:"schedule"`
+	LastRun  *core.Record `json:"lastRun"`
+}
+
+type runBody struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// Register exposes the manual job trigger routes for staff and superusers.
+//
+// A run responds with the recorded job_runs entry. Failed runs are recorded
+// as well and respond with status 500 and the failed run record, runs
+// skipped because a previous run still holds the job lease with status 409.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(ListRoute, handleList).Bind(access.RequireStaff())
+		se.Router.POST(RunRoute, handleRun).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleList(e *core.RequestEvent) error {
+	jobs := cronjobs.Jobs()
+	infos := make([]jobInfo, 0, len(jobs))
+
+	for _, job := range jobs {
+		runs, err := query.FindAll(e.App, cronjobs.RunsCollection, query.Eq("job", job.Id), "-started_at", 1, 0)
+		if err != nil {
+			return e.InternalServerError("Failed to load the job runs.", err)
+		}
+
+		info := jobInfo{Id: job.Id, Schedule: job.Schedule}
+		if len(runs) > 0 {
+			info.LastRun = runs[0]
+		}
+		infos = append(infos, info)
+	}
+
+	return e.JSON(http.StatusOK, infos)
+}
+
+func handleRun(e *core.RequestEvent) error {
+	id := e.Request.PathValue("id")
+	if _, ok := cronjobs.Find(id); !ok {
+		return e.NotFoundError("Unknown job.", nil)
+	}
+
+	var body runBody
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Invalid run options.", err)
+	}
+
+	run, err := cronjobs.Run(e.App, id, cronjobs.RunOptions{
+		DryRun:      body.DryRun,
+		Trigger:     cronjobs.TriggerManual,
+		TriggeredBy: e.Auth.Email(),
+	})
+	if run == nil {
+		return e.InternalServerError("Failed to start the job.", err)
+	}
+	if errors.Is(err, cronjobs.ErrLocked) {
+		return e.JSON(http.StatusConflict, run)
+	}
+	if err != nil {
+		e.App.Logger().Error("manual job run failed", "job", id, "actor", e.Auth.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, run)
+	}
+
+	e.App.Logger().Info("manual job run", "job", id, "actor", e.Auth.Id, "dryRun", body.DryRun, "affected", run.GetInt("affected"))
+
+	return e.JSON(http.StatusOK, run)
+}
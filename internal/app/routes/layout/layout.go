@@ -0,0 +1,79 @@
+package layout
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/layout"
+)
+
+// ImportRoute accepts a CSV or YAML locker layout upload.
+const ImportRoute = "/api/spindit/staff/lockers/import"
+
+// Register exposes the locker layout import route.
+//
+// The route expects a multipart "file" field. Without apply=true it only
+// reports the diff; with apply=true the layout is written in one transaction.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST(ImportRoute, handleImport).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleImport(e *core.RequestEvent) error {
+	file, header, err := e.Request.FormFile("file")
+	if err != nil {
+		return e.BadRequestError("Missing layout file upload.", err)
+	}
+	defer file.Close()
+
+	format := e.Request.FormValue("format")
+	if format == "" {
+		format = layout.FormatFromFilename(header.Filename)
+	}
+
+	l, err := layout.Parse(file, format)
+	if err != nil {
+		return e.BadRequestError("Invalid layout file.", err)
+	}
+
+	apply, _ := strconv.ParseBool(e.Request.FormValue("apply"))
+	if !apply {
+		diff, err := layout.Plan(e.App, l)
+		if errors.Is(err, layout.ErrTooManyLockers) {
+			return e.BadRequestError("Invalid layout file.", err)
+		}
+		if err != nil {
+			return e.InternalServerError("Failed to validate the layout.", err)
+		}
+
+		return e.JSON(http.StatusOK, diff)
+	}
+
+	diff, err := layout.Apply(e.App, l)
+	if errors.Is(err, layout.ErrConflicts) {
+		return e.JSON(http.StatusConflict, diff)
+	}
+	if errors.Is(err, layout.ErrTooManyLockers) {
+		return e.BadRequestError("Invalid layout file.", err)
+	}
+	if err != nil {
+		return e.InternalServerError("Failed to apply the layout.", err)
+	}
+
+	e.App.Logger().Info(
+		"locker layout imported",
+		"actor", e.Auth.Id,
+		"zonesCreated", len(diff.ZonesCreated),
+		"lockersCreated", len(diff.LockersCreated),
+		"lockersUpdated", len(diff.LockersUpdated),
+	)
+
+	return e.JSON(http.StatusOK, diff)
+}
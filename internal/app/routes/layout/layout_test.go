@@ -0,0 +1,62 @@
+package layout_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tests"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	routes "github.com/jryannel/spindit/internal/app/routes/layout"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestImportConflicts(t *testing.T) {
+	app := testutil.NewScenarioApp(t)
+
+	staff := testutil.CreateUser(t, app, map[string]any{"role": access.RoleStaff})
+	token, err := staff.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "layout.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("zone,numbers\nNorth,2001-2002\nSouth,2002-2003\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.WriteField("apply", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	scenario := tests.ApiScenario{
+		Name:   "a layout with conflicts is not applied",
+		Method: http.MethodPost,
+		URL:    routes.ImportRoute,
+		Body:   &body,
+		Headers: map[string]string{
+			"Authorization": token,
+			"Content-Type":  form.FormDataContentType(),
+		},
+		ExpectedStatus:  http.StatusConflict,
+		ExpectedContent: []string{`"conflicts":["locker 2002 is listed in \"North\" and \"South\""]`, `"applied":false`},
+		TestAppFactory: func(testing.TB) *tests.TestApp {
+			return app
+		},
+		AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+			testutil.AssertCount(t, app, "zones", dbx.HashExp{"name": []any{"North", "South"}}, 0)
+			testutil.AssertCount(t, app, "lockers", dbx.NewExp("[[number]] > 2000"), 0)
+		},
+	}
+	scenario.Test(t)
+}
@@ -0,0 +1,75 @@
+package legacy
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/legacy"
+)
+
+// ImportRoute accepts a legacy assignment export as CSV upload.
+const ImportRoute = "/api/spindit/staff/assignments/import"
+
+// Register exposes the legacy assignment import route.
+//
+// The route expects a multipart "file" field and optional "amount" and
+// "currency" fields for the invoices. Without apply=true it only reports the
+// outcome per row; with apply=true the valid rows are imported and the
+// report lists the rows that could not be matched.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST(ImportRoute, handleImport).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleImport(e *core.RequestEvent) error {
+	file, _, err := e.Request.FormFile("file")
+	if err != nil {
+		return e.BadRequestError("Missing legacy export upload.", err)
+	}
+	defer file.Close()
+
+	rows, err := legacy.ParseCSV(file)
+	if err != nil {
+		return e.BadRequestError("Invalid legacy export file.", err)
+	}
+
+	opts := legacy.Options{Currency: e.Request.FormValue("currency")}
+	if value := e.Request.FormValue("amount"); value != "" {
+		opts.Amount, err = strconv.ParseFloat(value, 64)
+		if err != nil || opts.Amount < 0 {
+			return e.BadRequestError("Invalid invoice amount.", err)
+		}
+	}
+
+	apply, _ := strconv.ParseBool(e.Request.FormValue("apply"))
+	if !apply {
+		report, err := legacy.Plan(e.App, rows, opts)
+		if err != nil {
+			return e.InternalServerError("Failed to match the legacy export.", err)
+		}
+
+		return e.JSON(http.StatusOK, report)
+	}
+
+	report, err := legacy.Apply(e.App, rows, opts)
+	if err != nil {
+		return e.InternalServerError("Failed to import the legacy export.", err)
+	}
+
+	e.App.Logger().Info(
+		"legacy assignments imported",
+		"actor", e.Auth.Id,
+		"imported", report.Imported,
+		"existing", report.Existing,
+		"errors", report.Errors,
+		"usersCreated", report.UsersCreated,
+	)
+
+	return e.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/metrics"
+	"github.com/jryannel/spindit/internal/app/settings"
+)
+
+// MetricsRoute serves the Prometheus metrics.
+const MetricsRoute = "/metrics"
+
+// Register exposes the metrics route and the failed write counters.
+//
+// Scrapers authenticate with "Authorization: Bearer <metrics_token>" from
+// app_settings; the route answers 404 while no token is configured.
+func Register(app core.App) {
+	metrics.Register(app)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(MetricsRoute, handleMetrics)
+
+		return se.Next()
+	})
+}
+
+func handleMetrics(e *core.RequestEvent) error {
+	s, err := settings.Load(e.App)
+	if err != nil {
+		return e.InternalServerError("Failed to load the settings.", err)
+	}
+	if s.MetricsToken == "" {
+		return e.NotFoundError("Metrics are disabled, set metrics_token in app_settings.", nil)
+	}
+
+	if !Authorized(e, s) {
+		return e.UnauthorizedError("Invalid metrics token.", nil)
+	}
+
+	var body strings.Builder
+	if err := metrics.Write(e.App, &body); err != nil {
+		return e.InternalServerError("Failed to collect the metrics.", err)
+	}
+
+	e.Response.Header().Set("Content-Type", metrics.ContentType)
+
+	return e.String(http.StatusOK, body.String())
+}
+
+// Authorized reports whether e carries the metrics token of s. It is false
+// while no token is configured.
+func Authorized(e *core.RequestEvent, s settings.Settings) bool {
+	if s.MetricsToken == "" {
+		return false
+	}
+
+	token, ok := strings.CutPrefix(e.Request.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) == 1
+}
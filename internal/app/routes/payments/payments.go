@@ -0,0 +1,102 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/payments"
+)
+
+const (
+	// CheckoutRoute opens a checkout session for an invoice of the family.
+	CheckoutRoute = "/api/spindit/me/invoices/{id}/checkout"
+	// WebhookRoute receives the webhooks of the payment provider.
+	WebhookRoute = "/api/spindit/payments/webhook"
+)
+
+type checkoutBody struct {
+	SuccessURL string `json:"success_url"`
+	CancelURL  string `json:"cancel_url"`
+}
+
+// Register exposes the online payment routes. Both respond with 503 while
+// no [payments.PaymentGateway] is configured.
+//
+// The webhook route is public and relies on the gateway to verify the
+// provider signature. It responds with 200 for ignored and repeated events
+// and for payments queued for review, so the provider stops retrying them.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST(CheckoutRoute, handleCheckout).Bind(apis.RequireAuth("users"))
+		se.Router.POST(WebhookRoute, handleWebhook)
+
+		return se.Next()
+	})
+}
+
+func handleCheckout(e *core.RequestEvent) error {
+	g := payments.Get(e.App)
+	if g == nil {
+		return e.Error(http.StatusServiceUnavailable, "Online payments are not available.", nil)
+	}
+
+	var body checkoutBody
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Invalid checkout options.", err)
+	}
+
+	invoice, err := e.App.FindRecordById(invoices.Collection, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Unknown invoice.", err)
+	}
+	request, err := e.App.FindRecordById("requests", invoice.GetString("request"))
+	if err != nil || request.GetString("user") != e.Auth.Id {
+		return e.NotFoundError("Unknown invoice.", err)
+	}
+
+	session, err := payments.Checkout(e.Request.Context(), e.App, g, invoice, body.SuccessURL, body.CancelURL)
+	if errors.Is(err, payments.ErrNotPayable) {
+		return e.BadRequestError("The invoice cannot be paid online.", err)
+	}
+	if err != nil {
+		return e.InternalServerError("Failed to open the checkout session.", err)
+	}
+
+	return e.JSON(http.StatusOK, session)
+}
+
+func handleWebhook(e *core.RequestEvent) error {
+	g := payments.Get(e.App)
+	if g == nil {
+		return e.Error(http.StatusServiceUnavailable, "Online payments are not available.", nil)
+	}
+
+	event, err := g.ParseWebhook(e.Request)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		return e.UnauthorizedError("Invalid signature.", nil)
+	}
+	if err != nil {
+		return e.BadRequestError("Invalid payment webhook.", err)
+	}
+
+	invoice, err := payments.HandleEvent(e.App, g, event)
+	switch {
+	case errors.Is(err, payments.ErrQueuedForReview):
+		e.App.Logger().Warn("online payment queued for review", "provider", g.Name(), "session", event.SessionId, "reference", event.Reference, "error", err)
+		return e.JSON(http.StatusOK, map[string]string{"status": "review"})
+	case err != nil:
+		return e.InternalServerError("Failed to confirm the payment.", err)
+	}
+
+	status := "ignored"
+	if invoice != nil {
+		status = invoice.GetString("status")
+		e.App.Logger().Info("online payment confirmed", "provider", g.Name(), "invoice", invoice.GetString("number"), "reference", event.Reference)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{"status": status})
+}
@@ -0,0 +1,73 @@
+package reports
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/reports"
+)
+
+// OccupancyRoute streams the occupancy report for janitor teams.
+const OccupancyRoute = "/api/spindit/staff/reports/occupancy"
+
+var (
+	lockerStatuses    = []string{"free", "reserved", "occupied", "maintenance"}
+	schoolYearPattern = regexp.MustCompile(`^[0-9]{4}/[0-9]{2}$`)
+)
+
+// Register exposes the report export routes.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET(OccupancyRoute, handleOccupancy).
+			Bind(access.RequireRole(access.RoleJanitor, access.RoleStaff, access.RoleAdmin))
+
+		return se.Next()
+	})
+}
+
+func handleOccupancy(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = reports.FormatCSV
+	}
+	if !reports.IsSupportedFormat(format) {
+		return e.BadRequestError("Unsupported report format. Use csv, xlsx or pdf.", nil)
+	}
+
+	filter := reports.OccupancyFilter{
+		Zone:       query.Get("zone"),
+		Status:     query.Get("status"),
+		SchoolYear: query.Get("school_year"),
+	}
+	if filter.Status != "" && !slices.Contains(lockerStatuses, filter.Status) {
+		return e.BadRequestError("Invalid locker status filter.", nil)
+	}
+	if filter.SchoolYear != "" && !schoolYearPattern.MatchString(filter.SchoolYear) {
+		return e.BadRequestError("Invalid school year filter, expected YYYY/YY.", nil)
+	}
+	if filter.Zone != "" {
+		if _, err := e.App.FindRecordById("zones", filter.Zone); err != nil {
+			return e.NotFoundError("Unknown zone.", err)
+		}
+	}
+
+	filename := fmt.Sprintf("occupancy-%s.%s", clock.Now(e.App).Format("20060102"), format)
+	e.Response.Header().Set("Content-Type", reports.ContentType(format))
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	e.Response.WriteHeader(http.StatusOK)
+
+	// The headers are already sent at this point, so failures can only be logged.
+	if err := reports.WriteOccupancy(e.App, e.Response, format, filter); err != nil {
+		e.App.Logger().Error("failed to stream occupancy report", "format", format, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package statements
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/statements"
+)
+
+// ReconcileRoute accepts a bank statement as CAMT.053 or CSV upload.
+const ReconcileRoute = "/api/spindit/staff/invoices/reconcile"
+
+// maxStatementSize caps the uploaded statement.
+const maxStatementSize = 10 << 20
+
+// Register exposes the bank statement reconciliation route.
+//
+// The route expects a multipart "file" field. Without apply=true it only
+// reports the outcome per transaction; with apply=true the exact matches
+// are marked paid and the other incoming transfers are queued for review
+// in bank_transactions.
+func Register(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST(ReconcileRoute, handleReconcile).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleReconcile(e *core.RequestEvent) error {
+	file, header, err := e.Request.FormFile("file")
+	if err != nil {
+		return e.BadRequestError("Missing bank statement upload.", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxStatementSize+1))
+	if err != nil {
+		return e.BadRequestError("Failed to read the bank statement.", err)
+	}
+	if len(data) > maxStatementSize {
+		return e.BadRequestError("The bank statement is too large.", nil)
+	}
+
+	transactions, err := statements.Parse(data)
+	if err != nil {
+		return e.BadRequestError("Invalid bank statement file.", err)
+	}
+
+	apply, _ := strconv.ParseBool(e.Request.FormValue("apply"))
+	if !apply {
+		report, err := statements.Plan(e.App, header.Filename, transactions)
+		if err != nil {
+			return e.InternalServerError("Failed to match the bank statement.", err)
+		}
+
+		return e.JSON(http.StatusOK, report)
+	}
+
+	report, err := statements.Apply(e.App, header.Filename, transactions, e.Auth)
+	if err != nil {
+		return e.InternalServerError("Failed to reconcile the bank statement.", err)
+	}
+
+	e.App.Logger().Info(
+		"bank statement reconciled",
+		"actor", e.Auth.Id,
+		"statement", header.Filename,
+		"matched", report.Matched,
+		"review", report.Review,
+		"duplicates", report.Duplicates,
+		"errors", report.Errors,
+	)
+
+	return e.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/webhooks"
+)
+
+// ReplayRoute posts a logged delivery again.
+const ReplayRoute = "/api/spindit/staff/webhooks/deliveries/{id}/replay"
+
+// Register queues the webhook events and exposes the replay route for
+// staff and superusers.
+//
+// A replay responds with the new delivery, which records the outcome of
+// its first attempt and is retried like any other delivery.
+func Register(app core.App) {
+	webhooks.Register(app)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST(ReplayRoute, handleReplay).Bind(access.RequireStaff())
+
+		return se.Next()
+	})
+}
+
+func handleReplay(e *core.RequestEvent) error {
+	delivery, err := e.App.FindRecordById(webhooks.DeliveriesCollection, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Unknown webhook delivery.", err)
+	}
+
+	replay, err := webhooks.Replay(e.App, delivery, clock.Now(e.App))
+	if err != nil {
+		return e.InternalServerError("Failed to replay the webhook delivery.", err)
+	}
+
+	e.App.Logger().Info("webhook delivery replayed", "delivery", delivery.Id, "replay", replay.Id, "actor", e.Auth.Email())
+
+	return e.JSON(http.StatusOK, replay)
+}
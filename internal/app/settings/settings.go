@@ -0,0 +1,148 @@
+package settings
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Collection is the name of the singleton collection holding the school wide configuration.
+const Collection = "app_settings"
+
+// Locker numbering schemes.
+const (
+	NumberingGlobal = "global" // lockers are labelled with their plain, school wide unique number
+	NumberingZone   = "zone"   // lockers are numbered per zone and labelled with the zone code, e.g. "A-012"
+)
+
+// Settings is the typed view of the app_settings record.
+//
+// Limits set to 0 are disabled.
+type Settings struct {
+	LockerNumbering string
+
+	// MaxActiveRequestsPerFamily caps the pending, reserved and assigned
+	// requests a family may hold per school year.
+	MaxActiveRequestsPerFamily int
+
+	// MaxRequestsPerHour caps the requests a single account may create per hour.
+	MaxRequestsPerHour int
+
+	// ReservationDays is the payment deadline of an automatic reservation.
+	ReservationDays int
+
+	// RetentionRequestsMonths is the number of months after the end of a
+	// school year until its requests are pseudonymized.
+	RetentionRequestsMonths int
+
+	// RetentionEmailsMonths is the number of months after queueing until
+	// the recipient and payload of an email are anonymized.
+	RetentionEmailsMonths int
+
+	// BackupKeepDaily, BackupKeepWeekly and BackupKeepMonthly are the
+	// numbers of daily, weekly and monthly backups kept. All 0 keeps every
+	// backup.
+	BackupKeepDaily   int
+	BackupKeepWeekly  int
+	BackupKeepMonthly int
+
+	// MetricsToken is the bearer token required by the /metrics endpoint.
+	// The endpoint is disabled while it is empty.
+	MetricsToken string
+
+	// AlertWebhookURL receives a JSON POST for every fired and resolved
+	// alert in addition to the staff emails.
+	AlertWebhookURL string
+
+	// AlertBounceRatePercent is the share of failed emails of the last hour
+	// that fires the bounce alert, once at least AlertBounceMinFailures
+	// emails failed.
+	AlertBounceRatePercent int
+	AlertBounceMinFailures int
+
+	// PayeeName, PayeeIBAN and PayeeBIC are the bank account printed on
+	// the invoices and encoded in their GiroCode. Invoices carry no payment
+	// details while PayeeIBAN is empty.
+	PayeeName string
+	PayeeIBAN string
+	PayeeBIC  string
+}
+
+// Defaults returns the settings used when no app_settings record exists.
+func Defaults() Settings {
+	return Settings{
+		LockerNumbering:            NumberingGlobal,
+		MaxActiveRequestsPerFamily: 5,
+		MaxRequestsPerHour:         10,
+		ReservationDays:            7,
+		RetentionRequestsMonths:    24,
+		RetentionEmailsMonths:      6,
+		BackupKeepDaily:            7,
+		BackupKeepWeekly:           4,
+		BackupKeepMonthly:          6,
+		AlertBounceRatePercent:     20,
+		AlertBounceMinFailures:     5,
+	}
+}
+
+// Load reads the current settings, falling back to [Defaults] for missing values.
+func Load(app core.App) (Settings, error) {
+	record, err := FindRecord(app)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Defaults(), nil
+		}
+		return Settings{}, err
+	}
+
+	return FromRecord(record), nil
+}
+
+// FindRecord returns the app_settings singleton record.
+//
+// It returns [sql.ErrNoRows] while the collection does not exist yet, e.g.
+// when earlier migrations save lockers.
+func FindRecord(app core.App) (*core.Record, error) {
+	collection, err := app.FindCachedCollectionByNameOrId(Collection)
+	if err != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	record := &core.Record{}
+
+	err = app.RecordQuery(collection).OrderBy("rowid ASC").Limit(1).One(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// FromRecord converts an app_settings record into [Settings].
+func FromRecord(record *core.Record) Settings {
+	s := Defaults()
+
+	if value := record.GetString("locker_numbering"); value != "" {
+		s.LockerNumbering = value
+	}
+	s.MaxActiveRequestsPerFamily = record.GetInt("max_active_requests_per_family")
+	s.MaxRequestsPerHour = record.GetInt("max_requests_per_hour")
+	if days := record.GetInt("reservation_days"); days > 0 {
+		s.ReservationDays = days
+	}
+	s.RetentionRequestsMonths = record.GetInt("retention_requests_months")
+	s.RetentionEmailsMonths = record.GetInt("retention_emails_months")
+	s.BackupKeepDaily = record.GetInt("backup_keep_daily")
+	s.BackupKeepWeekly = record.GetInt("backup_keep_weekly")
+	s.BackupKeepMonthly = record.GetInt("backup_keep_monthly")
+	s.MetricsToken = record.GetString("metrics_token")
+	s.AlertWebhookURL = record.GetString("alert_webhook_url")
+	s.AlertBounceRatePercent = record.GetInt("alert_bounce_rate_percent")
+	s.AlertBounceMinFailures = record.GetInt("alert_bounce_min_failures")
+	s.PayeeName = record.GetString("payee_name")
+	s.PayeeIBAN = record.GetString("payee_iban")
+	s.PayeeBIC = record.GetString("payee_bic")
+
+	return s
+}
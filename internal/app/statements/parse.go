@@ -0,0 +1,437 @@
+// Package statements reconciles bank statements with the open invoices.
+//
+// Families pay by bank transfer with the invoice number as reference. Staff
+// upload the statement of the school account as CAMT.053 XML or as CSV
+// export; incoming transfers are matched to the invoices by number and
+// amount, exact matches are marked paid and everything else is queued for
+// review in bank_transactions.
+package statements
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+)
+
+// Transaction is a single booking of a statement. Amount is negative for
+// outgoing transfers.
+type Transaction struct {
+	// Line is the position of the booking in the statement, the CSV line
+	// or the number of the CAMT entry.
+	Line     int       `json:"line"`
+	BookedAt time.Time `json:"booked_at"`
+	Amount   float64   `json:"amount"`
+	Currency string    `json:"currency"`
+	Payer    string    `json:"payer"`
+	// Reference is the remittance information entered by the payer.
+	Reference string `json:"reference"`
+	// BankReference is the id of the booking at the bank, if any.
+	BankReference string `json:"bank_reference,omitempty"`
+}
+
+// Parse reads a CAMT.053 XML or CSV statement, detected by its content.
+func Parse(data []byte) ([]Transaction, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("<")) {
+		return ParseCAMT(trimmed)
+	}
+
+	return ParseCSV(data)
+}
+
+type camtDocument struct {
+	Statements []struct {
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// camtStatus is plain text up to camt.053.001.04 and a code element later.
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+type camtEntry struct {
+	Amount      camtAmount      `xml:"Amt"`
+	CreditDebit string          `xml:"CdtDbtInd"`
+	Status      camtStatus      `xml:"Sts"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	ValueDate   camtDate        `xml:"ValDt"`
+	BankRef     string          `xml:"AcctSvcrRef"`
+	Info        string          `xml:"AddtlNtryInf"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDetails struct {
+	Amount       camtAmount `xml:"Amt"`
+	TxAmount     camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	BankRef      string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndId   string     `xml:"Refs>EndToEndId"`
+	Debtor       string     `xml:"RltdPties>Dbtr>Nm"`
+	DebtorParty  string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Unstructured []string   `xml:"RmtInf>Ustrd"`
+	Structured   []string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+// ParseCAMT reads the booked entries of a CAMT.053 statement of any
+// camt.053.001 version. Batch bookings are split into their transactions.
+func ParseCAMT(data []byte) ([]Transaction, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid camt.053 statement: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, errors.New("invalid camt.053 statement: no BkToCstmrStmt/Stmt element")
+	}
+
+	transactions := []Transaction{}
+	line := 0
+
+	for _, statement := range doc.Statements {
+		for _, entry := range statement.Entries {
+			line++
+
+			status := strings.TrimSpace(entry.Status.Code)
+			if status == "" {
+				status = strings.TrimSpace(entry.Status.Value)
+			}
+			if status != "" && status != "BOOK" {
+				continue // pending or informational entries are not booked yet
+			}
+
+			booked, err := parseCAMTDate(entry.BookingDate)
+			if err != nil {
+				booked, err = parseCAMTDate(entry.ValueDate)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: missing booking date", line)
+			}
+
+			sign := 1.0
+			if strings.TrimSpace(entry.CreditDebit) == "DBIT" {
+				sign = -1
+			}
+
+			details := entry.Details
+			if len(details) == 0 {
+				details = []camtTxDetails{{}}
+			}
+
+			for _, d := range details {
+				amount := entry.Amount
+				if len(details) > 1 {
+					amount = d.TxAmount
+					if strings.TrimSpace(amount.Value) == "" {
+						amount = d.Amount
+					}
+				}
+
+				value, err := strconv.ParseFloat(strings.TrimSpace(amount.Value), 64)
+				if err != nil {
+					return nil, fmt.Errorf("entry %d: invalid amount %q", line, amount.Value)
+				}
+
+				payer := d.Debtor
+				if payer == "" {
+					payer = d.DebtorParty
+				}
+
+				reference := strings.Join(append(d.Structured, d.Unstructured...), " ")
+				if strings.TrimSpace(reference) == "" {
+					reference = entry.Info
+				}
+
+				bankRef := d.BankRef
+				if bankRef == "" && len(details) == 1 {
+					bankRef = entry.BankRef
+				}
+				if bankRef == "" && d.EndToEndId != "" && d.EndToEndId != "NOTPROVIDED" {
+					bankRef = d.EndToEndId
+				}
+
+				transactions = append(transactions, Transaction{
+					Line:          line,
+					BookedAt:      booked,
+					Amount:        sign * value,
+					Currency:      strings.ToUpper(strings.TrimSpace(amount.Currency)),
+					Payer:         strings.TrimSpace(payer),
+					Reference:     collapseSpaces(reference),
+					BankReference: strings.TrimSpace(bankRef),
+				})
+			}
+		}
+	}
+
+	return transactions, nil
+}
+
+func parseCAMTDate(d camtDate) (time.Time, error) {
+	if value := strings.TrimSpace(d.Date); value != "" {
+		return time.ParseInLocation("2006-01-02", value, clock.Location())
+	}
+	if value := strings.TrimSpace(d.DateTime); value != "" {
+		return time.Parse(time.RFC3339, value)
+	}
+
+	return time.Time{}, errors.New("missing date")
+}
+
+// csvColumnAliases maps the header names of common bank exports to the
+// [Transaction] columns.
+var csvColumnAliases = map[string]string{
+	"date":                              "date",
+	"booking_date":                      "date",
+	"buchungstag":                       "date",
+	"buchungsdatum":                     "date",
+	"datum":                             "date",
+	"valuta":                            "date",
+	"wertstellung":                      "date",
+	"value_date":                        "date",
+	"amount":                            "amount",
+	"betrag":                            "amount",
+	"betrag_(€)":                        "amount",
+	"betrag_(eur)":                      "amount",
+	"umsatz":                            "amount",
+	"currency":                          "currency",
+	"währung":                           "currency",
+	"waehrung":                          "currency",
+	"payer":                             "payer",
+	"name":                              "payer",
+	"auftraggeber":                      "payer",
+	"zahlungspflichtiger":               "payer",
+	"auftraggeber/zahlungsempfänger":    "payer",
+	"beguenstigter/zahlungspflichtiger": "payer",
+	"begünstigter/zahlungspflichtiger":  "payer",
+	"name_zahlungsbeteiligter":          "payer",
+	"reference":                         "reference",
+	"purpose":                           "reference",
+	"description":                       "reference",
+	"verwendungszweck":                  "reference",
+	"bank_reference":                    "bank_reference",
+	"transaction_id":                    "bank_reference",
+}
+
+var requiredCSVColumns = []string{"date", "amount", "reference"}
+
+// ParseCSV reads a bank CSV export separated by semicolons or commas, in
+// UTF-8 or Latin-1. Lines before the header row, as written by many banks,
+// are skipped. The date, amount and reference columns are required; payer,
+// currency and bank reference are optional. Amounts may use German number
+// formatting, e.g. "1.234,56".
+func ParseCSV(data []byte) ([]Transaction, error) {
+	if !utf8.Valid(data) {
+		data = latin1ToUTF8(data)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectComma(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	// the reader skips empty lines, so the file line of every record is
+	// kept for the reports
+	records := [][]string{}
+	lines := []int{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv statement: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	header := -1
+	columns := map[string]int{}
+	for i, record := range records {
+		columns = csvColumns(record)
+		if hasColumns(columns, requiredCSVColumns) {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("no csv header with the columns %s found", strings.Join(requiredCSVColumns, ", "))
+	}
+
+	transactions := []Transaction{}
+
+	for i, record := range records[header+1:] {
+		line := lines[header+1+i]
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if get("date") == "" && get("amount") == "" {
+			continue // blank or summary line
+		}
+
+		booked, err := parseCSVDate(get("date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		amount, err := ParseAmount(get("amount"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		currency := strings.ToUpper(get("currency"))
+		if currency == "" {
+			currency = "EUR"
+		}
+
+		transactions = append(transactions, Transaction{
+			Line:          line,
+			BookedAt:      booked,
+			Amount:        amount,
+			Currency:      currency,
+			Payer:         get("payer"),
+			Reference:     collapseSpaces(get("reference")),
+			BankReference: get("bank_reference"),
+		})
+	}
+
+	return transactions, nil
+}
+
+func csvColumns(record []string) map[string]int {
+	columns := map[string]int{}
+
+	for i, name := range record {
+		key := strings.ToLower(strings.TrimSpace(name))
+		key = strings.TrimPrefix(key, "\ufeff") // Excel byte order mark
+		key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+		if column, ok := csvColumnAliases[key]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+
+	return columns
+}
+
+func hasColumns(columns map[string]int, required []string) bool {
+	for _, column := range required {
+		if _, ok := columns[column]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// detectComma picks the separator used more often in the first lines.
+func detectComma(data []byte) rune {
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+
+	if bytes.Count(head, []byte(";")) >= bytes.Count(head, []byte(",")) {
+		return ';'
+	}
+
+	return ','
+}
+
+var csvDateLayouts = []string{"02.01.2006", "2006-01-02", "02.01.06", "02/01/2006"}
+
+func parseCSVDate(value string) (time.Time, error) {
+	for _, layout := range csvDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, clock.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// ParseAmount reads an amount with a decimal point or comma and optional
+// thousands separators, currency symbol or code. A single separator followed
+// by exactly three digits groups thousands, e.g. "1.234" in German and
+// "1,234" in English exports, as banks write amounts with two decimals.
+func ParseAmount(value string) (float64, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == ',' || r == '.' || r == '-' || r == '+' {
+			return r
+		}
+		return -1
+	}, value)
+
+	lastComma := strings.LastIndex(cleaned, ",")
+	lastPoint := strings.LastIndex(cleaned, ".")
+
+	decimal := ""
+	switch {
+	case lastComma >= 0 && lastPoint >= 0:
+		// the last separator is the decimal one, e.g. "1.234,56" or "1,234.56"
+		decimal = ","
+		if lastPoint > lastComma {
+			decimal = "."
+		}
+	case lastComma >= 0 && strings.Count(cleaned, ",") == 1 && len(cleaned)-lastComma-1 != 3:
+		decimal = ","
+	case lastPoint >= 0 && strings.Count(cleaned, ".") == 1 && len(cleaned)-lastPoint-1 != 3:
+		decimal = "."
+	}
+
+	for _, separator := range []string{",", "."} {
+		if separator != decimal {
+			cleaned = strings.ReplaceAll(cleaned, separator, "")
+		}
+	}
+	if decimal != "" {
+		cleaned = strings.Replace(cleaned, decimal, ".", 1)
+	}
+
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil || cleaned == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	return amount, nil
+}
+
+func latin1ToUTF8(data []byte) []byte {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+
+	return []byte(string(runes))
+}
+
+func collapseSpaces(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
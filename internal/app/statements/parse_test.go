@@ -0,0 +1,164 @@
+package statements_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/statements"
+)
+
+func TestParseAmount(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "20", want: 20},
+		{value: "20,00", want: 20},
+		{value: "20.00", want: 20},
+		{value: "20,5", want: 20.5},
+		{value: "-20,00", want: -20},
+		{value: "+20.00", want: 20},
+		{value: "1.234", want: 1234},
+		{value: "1,234", want: 1234},
+		{value: "1.234,56", want: 1234.56},
+		{value: "1,234.56", want: 1234.56},
+		{value: "1.234.567", want: 1234567},
+		{value: "1.234.567,89", want: 1234567.89},
+		{value: "20,00 €", want: 20},
+		{value: "EUR 1.234,50", want: 1234.5},
+		{value: "-1.234", want: -1234},
+		{value: "", wantErr: true},
+		{value: "EUR", wantErr: true},
+		{value: "1-2", wantErr: true},
+	} {
+		got, err := statements.ParseAmount(tc.value)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q): expected an error, got %v", tc.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseAmount(%q) = %v, %v; want %v", tc.value, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	// Latin-1 export with account lines before the header, as written by
+	// German savings banks
+	csv := []byte("Konto;DE89370400440532013000\n" +
+		"Zeitraum;01.08.2024 - 31.08.2024\n" +
+		"\n" +
+		"Buchungstag;Auftraggeber/Zahlungsempf\xe4nger;Verwendungszweck;Betrag;W\xe4hrung\n" +
+		"05.08.2024;Familie M\xfcller;\"Spind  INV-000001\";20,00;EUR\n" +
+		"06.08.2024;Stadtwerke;Abschlag;-1.234;eur\n" +
+		";;;;\n" +
+		"2024-08-07;Schulf\xf6rderverein;Spende;1.234,56;\n")
+
+	got, err := statements.ParseCSV(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []statements.Transaction{
+		{Line: 5, BookedAt: day(5), Amount: 20, Currency: "EUR", Payer: "Familie Müller", Reference: "Spind INV-000001"},
+		{Line: 6, BookedAt: day(6), Amount: -1234, Currency: "EUR", Payer: "Stadtwerke", Reference: "Abschlag"},
+		{Line: 8, BookedAt: day(7), Amount: 1234.56, Currency: "EUR", Payer: "Schulförderverein", Reference: "Spende"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	for name, csv := range map[string]string{
+		"missing header": "Datum;Betrag\n05.08.2024;20,00\n",
+		"invalid date":   "Datum;Betrag;Verwendungszweck\n2024/08/05;20,00;INV-000001\n",
+		"invalid amount": "Datum;Betrag;Verwendungszweck\n05.08.2024;zwanzig;INV-000001\n",
+	} {
+		if _, err := statements.ParseCSV([]byte(csv)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseCAMT(t *testing.T) {
+	camt := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt><Stmt>
+  <Ntry>
+    <Amt Ccy="EUR">20.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+    <BookgDt><Dt>2024-08-05</Dt></BookgDt><AcctSvcrRef>REF-1</AcctSvcrRef>
+    <NtryDtls><TxDtls>
+      <RltdPties><Dbtr><Nm>Familie Muster</Nm></Dbtr></RltdPties>
+      <RmtInf><Ustrd>Spind</Ustrd><Ustrd>INV-000001</Ustrd></RmtInf>
+    </TxDtls></NtryDtls>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">5.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>PDNG</Sts>
+    <BookgDt><Dt>2024-08-06</Dt></BookgDt>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">45.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+    <ValDt><Dt>2024-08-07</Dt></ValDt><AcctSvcrRef>BATCH</AcctSvcrRef>
+    <NtryDtls>
+      <TxDtls>
+        <Refs><EndToEndId>E2E-1</EndToEndId></Refs>
+        <AmtDtls><TxAmt><Amt Ccy="EUR">20.00</Amt></TxAmt></AmtDtls>
+        <RltdPties><Dbtr><Pty><Nm>Familie Beispiel</Nm></Pty></Dbtr></RltdPties>
+        <RmtInf><Strd><CdtrRefInf><Ref>INV-000002</Ref></CdtrRefInf></Strd></RmtInf>
+      </TxDtls>
+      <TxDtls>
+        <Refs><AcctSvcrRef>REF-3</AcctSvcrRef><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+        <Amt Ccy="EUR">25.00</Amt>
+        <RmtInf><Ustrd>INV-000003</Ustrd></RmtInf>
+      </TxDtls>
+    </NtryDtls>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">1234.5</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+    <BookgDt><DtTm>2024-08-08T09:30:00+02:00</DtTm></BookgDt>
+    <AddtlNtryInf>Miete</AddtlNtryInf>
+  </Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+
+	got, err := statements.Parse([]byte("\ufeff" + camt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []statements.Transaction{
+		{Line: 1, BookedAt: day(5), Amount: 20, Currency: "EUR", Payer: "Familie Muster", Reference: "Spind INV-000001", BankReference: "REF-1"},
+		{Line: 3, BookedAt: day(7), Amount: 20, Currency: "EUR", Payer: "Familie Beispiel", Reference: "INV-000002", BankReference: "E2E-1"},
+		{Line: 3, BookedAt: day(7), Amount: 25, Currency: "EUR", Reference: "INV-000003", BankReference: "REF-3"},
+		{Line: 4, BookedAt: time.Date(2024, time.August, 8, 7, 30, 0, 0, time.UTC), Amount: -1234.5, Currency: "EUR", Reference: "Miete"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d transactions, got %+v", len(want), got)
+	}
+	for i := range want {
+		if !got[i].BookedAt.Equal(want[i].BookedAt) {
+			t.Errorf("transaction %d booked at %s, want %s", i, got[i].BookedAt, want[i].BookedAt)
+		}
+		got[i].BookedAt = want[i].BookedAt
+		if got[i] != want[i] {
+			t.Errorf("transaction %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	for name, xml := range map[string]string{
+		"not xml":         "<Document>",
+		"no statement":    "<Document><Other/></Document>",
+		"no booking date": "<Document><BkToCstmrStmt><Stmt><Ntry><Amt Ccy=\"EUR\">1.00</Amt></Ntry></Stmt></BkToCstmrStmt></Document>",
+		"invalid amount":  "<Document><BkToCstmrStmt><Stmt><Ntry><Amt Ccy=\"EUR\">1,00</Amt><BookgDt><Dt>2024-08-05</Dt></BookgDt></Ntry></Stmt></BkToCstmrStmt></Document>",
+	} {
+		if _, err := statements.ParseCAMT([]byte(xml)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func day(d int) time.Time {
+	return time.Date(2024, time.August, d, 0, 0, 0, 0, clock.Location())
+}
@@ -0,0 +1,317 @@
+package statements
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/text"
+)
+
+// Collection is the name of the imported bank transactions collection.
+const Collection = "bank_transactions"
+
+// Transaction outcomes. The first six are stored in bank_transactions,
+// duplicate, ignored and error are only reported.
+const (
+	StatusMatched     = "matched"      // paid the invoice exactly, which is marked paid
+	StatusPartial     = "partial"      // paid less than the invoice amount
+	StatusOverpaid    = "overpaid"     // paid more than the invoice amount
+	StatusUnmatched   = "unmatched"    // no open invoice found
+	StatusAlreadyPaid = "already_paid" // paid an invoice that is paid already
+	StatusResolved    = "resolved"     // reviewed by staff
+	StatusDuplicate   = "duplicate"    // imported with an earlier statement
+	StatusIgnored     = "ignored"      // outgoing transfer
+	StatusError       = "error"        // failed to import
+)
+
+// invoiceNumberPattern finds invoice numbers in remittance texts, also when
+// the payer dropped or changed the separator or the leading zeros.
+var invoiceNumberPattern = regexp.MustCompile(`(?i)\bINV[\s\-_./:]*([0-9]{1,6})\b`)
+
+// Result is the outcome of a single transaction.
+type Result struct {
+	Transaction
+	Status string `json:"status"`
+	// Invoice is the number of the matched invoice.
+	Invoice       string  `json:"invoice,omitempty"`
+	InvoiceAmount float64 `json:"invoice_amount,omitempty"`
+	Note          string  `json:"note,omitempty"`
+	// Record is the bank_transactions id, once applied.
+	Record string `json:"record,omitempty"`
+}
+
+// Report summarizes a statement import.
+type Report struct {
+	Statement    string   `json:"statement"`
+	Transactions []Result `json:"transactions"`
+	Matched      int      `json:"matched"`
+	Review       int      `json:"review"`
+	Duplicates   int      `json:"duplicates"`
+	Ignored      int      `json:"ignored"`
+	Errors       int      `json:"errors"`
+	Applied      bool     `json:"applied"`
+}
+
+// plannedTransaction is a transaction to store.
+type plannedTransaction struct {
+	result      *Result
+	fingerprint string
+	invoice     *core.Record
+}
+
+// Plan matches the incoming transfers of a statement against the invoices
+// and reports the outcome of every transaction without changing anything.
+func Plan(app core.App, statement string, transactions []Transaction) (*Report, error) {
+	report, _, err := plan(app, statement, transactions)
+
+	return report, err
+}
+
+// Apply stores the new incoming transfers in bank_transactions and marks
+// the exactly matched invoices paid through [invoices.MarkPaid], booked at
+// the booking date. Each transaction is applied in its own database
+// transaction, so failures are reported without affecting the others. The
+// invoice is matched again within it, so an invoice paid online or
+// cancelled meanwhile is not booked.
+//
+// actor is recorded as importer if it is a users record.
+func Apply(app core.App, statement string, transactions []Transaction, actor *core.Record) (*Report, error) {
+	report, planned, err := plan(app, statement, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := app.FindCollectionByNameOrId(Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	// invoices paid by an earlier transaction of this import
+	paid := map[string]bool{}
+
+	for _, p := range planned {
+		record := core.NewRecord(collection)
+		plannedStatus := p.result.Status
+
+		err := app.RunInTransaction(func(txApp core.App) error {
+			r := p.result
+
+			r.Note = ""
+			invoice, err := match(txApp, r, paid)
+			if err != nil {
+				return err
+			}
+			p.invoice = invoice
+
+			record.Set("statement", statement)
+			record.Set("booked_at", r.BookedAt)
+			record.Set("amount", r.Amount)
+			record.Set("currency", r.Currency)
+			record.Set("payer", text.Truncate(r.Payer, 255))
+			record.Set("reference", text.Truncate(r.Reference, 1000))
+			record.Set("bank_reference", text.Truncate(r.BankReference, 255))
+			record.Set("fingerprint", p.fingerprint)
+			record.Set("status", r.Status)
+			record.Set("note", text.Truncate(r.Note, 500))
+			if p.invoice != nil {
+				record.Set("invoice", p.invoice.Id)
+			}
+			if actor != nil && actor.Collection().Name == "users" {
+				record.Set("imported_by", actor.Id)
+			}
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+
+			if r.Status == StatusMatched {
+				return invoices.MarkPaid(txApp, p.invoice, r.BookedAt)
+			}
+
+			return nil
+		})
+		if err != nil {
+			if plannedStatus == StatusMatched {
+				report.Matched--
+			} else {
+				report.Review--
+			}
+			report.Errors++
+			p.result.Status = StatusError
+			p.result.Note = err.Error()
+			continue
+		}
+
+		switch {
+		case plannedStatus == StatusMatched && p.result.Status != StatusMatched:
+			report.Matched--
+			report.Review++
+		case plannedStatus != StatusMatched && p.result.Status == StatusMatched:
+			report.Review--
+			report.Matched++
+		}
+		if p.result.Status == StatusMatched {
+			paid[p.invoice.Id] = true
+		}
+
+		p.result.Record = record.Id
+	}
+
+	report.Applied = true
+
+	return report, nil
+}
+
+func plan(app core.App, statement string, transactions []Transaction) (*Report, []*plannedTransaction, error) {
+	report := &Report{Statement: statement, Transactions: make([]Result, len(transactions))}
+	planned := []*plannedTransaction{}
+
+	occurrences := map[string]int{}
+	// invoices paid by an earlier transaction of the same statement
+	paid := map[string]bool{}
+
+	for i, tx := range transactions {
+		r := &report.Transactions[i]
+		r.Transaction = tx
+
+		if tx.Amount <= 0 {
+			r.Status = StatusIgnored
+			report.Ignored++
+			continue
+		}
+
+		fingerprint := Fingerprint(tx, occurrences)
+
+		_, err := query.FindFirst(app, Collection, query.Eq("fingerprint", fingerprint))
+		if err == nil {
+			r.Status = StatusDuplicate
+			report.Duplicates++
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+
+		invoice, err := match(app, r, paid)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if r.Status == StatusMatched {
+			paid[invoice.Id] = true
+			report.Matched++
+		} else {
+			report.Review++
+		}
+
+		planned = append(planned, &plannedTransaction{result: r, fingerprint: fingerprint, invoice: invoice})
+	}
+
+	return report, planned, nil
+}
+
+// match sets the status of r and returns the referenced invoice, if any.
+func match(app core.App, r *Result, paid map[string]bool) (*core.Record, error) {
+	numbers := InvoiceNumbers(r.Reference)
+
+	switch len(numbers) {
+	case 0:
+		r.Status = StatusUnmatched
+		r.Note = "no invoice number in the reference"
+		return nil, nil
+	case 1:
+	default:
+		r.Status = StatusUnmatched
+		r.Note = "several invoice numbers in the reference: " + strings.Join(numbers, ", ")
+		return nil, nil
+	}
+
+	invoice, err := app.FindFirstRecordByData(invoices.Collection, "number", numbers[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		r.Status = StatusUnmatched
+		r.Note = fmt.Sprintf("invoice %s not found", numbers[0])
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.Invoice = invoice.GetString("number")
+	r.InvoiceAmount = invoice.GetFloat("amount")
+
+	switch {
+	case invoice.GetString("status") == invoices.StatusPaid || paid[invoice.Id]:
+		r.Status = StatusAlreadyPaid
+		r.Note = "the invoice is paid already"
+	case invoice.GetString("status") != invoices.StatusSent:
+		r.Status = StatusUnmatched
+		r.Note = "the invoice is " + invoice.GetString("status")
+	case !strings.EqualFold(r.Currency, invoice.GetString("currency")):
+		r.Status = StatusUnmatched
+		r.Note = fmt.Sprintf("paid in %s, the invoice is in %s", r.Currency, invoice.GetString("currency"))
+	case math.Abs(r.Amount-r.InvoiceAmount) < 0.005:
+		r.Status = StatusMatched
+	case r.Amount < r.InvoiceAmount:
+		r.Status = StatusPartial
+		r.Note = fmt.Sprintf("%.2f of %.2f paid", r.Amount, r.InvoiceAmount)
+	default:
+		r.Status = StatusOverpaid
+		r.Note = fmt.Sprintf("%.2f paid, %.2f due", r.Amount, r.InvoiceAmount)
+	}
+
+	return invoice, nil
+}
+
+// InvoiceNumbers returns the distinct invoice numbers in a remittance
+// text, normalized to the INV-000042 format.
+func InvoiceNumbers(reference string) []string {
+	numbers := []string{}
+
+	for _, m := range invoiceNumberPattern.FindAllStringSubmatch(reference, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n == 0 {
+			continue
+		}
+
+		number := fmt.Sprintf("INV-%06d", n)
+		if !slices.Contains(numbers, number) {
+			numbers = append(numbers, number)
+		}
+	}
+
+	return numbers
+}
+
+// Fingerprint identifies tx across overlapping statements: by the bank
+// reference if there is one, otherwise by its content and its occurrence,
+// so identical transfers on the same day stay distinct. occurrences counts
+// the content fingerprints of the current statement.
+func Fingerprint(tx Transaction, occurrences map[string]int) string {
+	if tx.BankReference != "" {
+		return "bank:" + text.Truncate(tx.BankReference, 95)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		tx.BookedAt.Format("2006-01-02"),
+		strconv.FormatFloat(tx.Amount, 'f', 2, 64),
+		tx.Currency,
+		tx.Payer,
+		tx.Reference,
+	}, "\x00")))
+	key := hex.EncodeToString(sum[:16])
+
+	occurrences[key]++
+
+	return fmt.Sprintf("sha:%s#%d", key, occurrences[key])
+}
@@ -0,0 +1,184 @@
+package statements_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/statements"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestBankStatementReconciliation(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	exactRequest, locker := testutil.ReservedRequest(t, app)
+	exact := testutil.CreateInvoice(t, app, exactRequest, nil)
+	partialRequest, _ := testutil.ReservedRequest(t, app)
+	partial := testutil.CreateInvoice(t, app, partialRequest, nil)
+	overpaidRequest, _ := testutil.ReservedRequest(t, app)
+	overpaid := testutil.CreateInvoice(t, app, overpaidRequest, nil)
+
+	entry := func(ref string, amount string, indicator string, reference string) string {
+		return `<Ntry><Amt Ccy="EUR">` + amount + `</Amt><CdtDbtInd>` + indicator + `</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>` +
+			`<BookgDt><Dt>2024-08-05</Dt></BookgDt><AcctSvcrRef>` + ref + `</AcctSvcrRef><NtryDtls><TxDtls>` +
+			`<RltdPties><Dbtr><Nm>Familie Muster</Nm></Dbtr></RltdPties><RmtInf><Ustrd>` + reference + `</Ustrd></RmtInf>` +
+			`</TxDtls></NtryDtls></Ntry>`
+	}
+	camt := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"><BkToCstmrStmt><Stmt>` +
+		entry("REF-1", "20.00", "CRDT", "Spind "+exact.GetString("number")+" Max") +
+		entry("REF-2", "15.00", "CRDT", "Rechnung "+strings.Replace(partial.GetString("number"), "-", " ", 1)) +
+		entry("REF-3", "25.00", "CRDT", strings.ToLower(overpaid.GetString("number"))) +
+		entry("REF-4", "20.00", "CRDT", "Spende Schulfest") +
+		entry("REF-5", "20.00", "DBIT", "Miete "+exact.GetString("number")) +
+		`</Stmt></BkToCstmrStmt></Document>`
+
+	transactions, err := statements.Parse([]byte(camt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 5 || transactions[4].Amount != -20 {
+		t.Fatalf("expected 5 transactions with a debit, got %+v", transactions)
+	}
+
+	plan, err := statements.Plan(app, "camt.xml", transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Matched != 1 || plan.Review != 3 || plan.Ignored != 1 || plan.Applied {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	testutil.AssertString(t, "invoice status after plan", testutil.Reload(t, app, exact).GetString("status"), invoices.StatusSent)
+	testutil.AssertCount(t, app, statements.Collection, nil, 0)
+
+	report, err := statements.Apply(app, "camt.xml", transactions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Matched != 1 || report.Review != 3 || report.Errors != 0 || !report.Applied {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for i, want := range []string{
+		statements.StatusMatched,
+		statements.StatusPartial,
+		statements.StatusOverpaid,
+		statements.StatusUnmatched,
+		statements.StatusIgnored,
+	} {
+		testutil.AssertString(t, fmt.Sprintf("transaction %d status", i+1), report.Transactions[i].Status, want)
+	}
+
+	exact = testutil.Reload(t, app, exact)
+	testutil.AssertString(t, "exact invoice status", exact.GetString("status"), invoices.StatusPaid)
+	if got, want := exact.GetDateTime("paid_at").Time(), transactions[0].BookedAt; !got.Equal(want) {
+		t.Fatalf("expected paid_at %s, got %s", want, got)
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, exactRequest).GetString("status"), "assigned")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "occupied")
+	testutil.AssertString(t, "partial invoice status", testutil.Reload(t, app, partial).GetString("status"), invoices.StatusSent)
+	testutil.AssertString(t, "overpaid invoice status", testutil.Reload(t, app, overpaid).GetString("status"), invoices.StatusSent)
+
+	testutil.AssertCount(t, app, statements.Collection, nil, 4)
+	review, err := query.FindFirst(app, statements.Collection, query.Eq("status", statements.StatusPartial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "review invoice", review.GetString("invoice"), partial.Id)
+
+	// an overlapping CSV export with a second payment of the paid invoice
+	// and a transfer already imported with the XML statement
+	csv := "Kontoauszug Schulkonto;;;;\n" +
+		"Buchungstag;Auftraggeber;Verwendungszweck;Betrag;W\u00e4hrung;Transaction ID\n" +
+		"05.08.2024;Familie Muster;Spind " + exact.GetString("number") + " Max;20,00;EUR;REF-1\n" +
+		"06.08.2024;Familie Muster;Spind " + exact.GetString("number") + ";20,00;EUR;\n" +
+		"06.08.2024;Familie Beispiel;INV-000042 und " + partial.GetString("number") + ";1.020,00;EUR;\n"
+
+	transactions, err = statements.Parse([]byte(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 3 || transactions[2].Amount != 1020 {
+		t.Fatalf("expected 3 CSV transactions, got %+v", transactions)
+	}
+
+	report, err = statements.Apply(app, "umsaetze.csv", transactions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Duplicates != 1 || report.Matched != 0 || report.Review != 2 {
+		t.Fatalf("unexpected CSV report %+v", report)
+	}
+	testutil.AssertString(t, "second payment status", report.Transactions[1].Status, statements.StatusAlreadyPaid)
+	testutil.AssertString(t, "several invoices status", report.Transactions[2].Status, statements.StatusUnmatched)
+
+	// importing the same export again only reports duplicates
+	again, err := statements.Apply(app, "umsaetze.csv", transactions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Duplicates != 3 || again.Review != 0 {
+		t.Fatalf("expected only duplicates, got %+v", again)
+	}
+	testutil.AssertCount(t, app, statements.Collection, nil, 6)
+}
+
+func TestReconciliationRematchesOnApply(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	paidRequest, _ := testutil.ReservedRequest(t, app)
+	paid := testutil.CreateInvoice(t, app, paidRequest, nil)
+	cancelledRequest, _ := testutil.ReservedRequest(t, app)
+	cancelled := testutil.CreateInvoice(t, app, cancelledRequest, nil)
+	draftRequest, _ := testutil.ReservedRequest(t, app)
+	draft := testutil.CreateInvoice(t, app, draftRequest, map[string]any{"status": invoices.StatusDraft})
+
+	transactions := []statements.Transaction{}
+	for i, invoice := range []*core.Record{paid, cancelled, draft} {
+		transactions = append(transactions, statements.Transaction{
+			BookedAt:      testutil.Start,
+			Amount:        20,
+			Currency:      "EUR",
+			Payer:         "Familie Muster",
+			Reference:     "Spind " + invoice.GetString("number"),
+			BankReference: fmt.Sprintf("REF-%d", i+1),
+		})
+	}
+
+	// the second invoice is cancelled while the statement is applied
+	var once sync.Once
+	app.OnRecordCreate(statements.Collection).BindFunc(func(e *core.RecordEvent) error {
+		once.Do(func() {
+			cancelledRequest.Set("status", "cancelled")
+			if err := e.App.Save(cancelledRequest); err != nil {
+				t.Error(err)
+			}
+		})
+
+		return e.Next()
+	})
+
+	report, err := statements.Apply(app, "camt.xml", transactions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Matched != 1 || report.Review != 2 || report.Errors != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	testutil.AssertString(t, "cancelled transaction status", report.Transactions[1].Status, statements.StatusUnmatched)
+	testutil.AssertString(t, "cancelled transaction note", report.Transactions[1].Note, "the invoice is cancelled")
+	testutil.AssertString(t, "draft transaction status", report.Transactions[2].Status, statements.StatusUnmatched)
+
+	testutil.AssertString(t, "paid invoice status", testutil.Reload(t, app, paid).GetString("status"), invoices.StatusPaid)
+	testutil.AssertString(t, "cancelled invoice status", testutil.Reload(t, app, cancelled).GetString("status"), invoices.StatusCancelled)
+	testutil.AssertString(t, "draft invoice status", testutil.Reload(t, app, draft).GetString("status"), invoices.StatusDraft)
+	testutil.AssertCount(t, app, statements.Collection, dbx.HashExp{"status": statements.StatusUnmatched}, 2)
+}
@@ -0,0 +1,21 @@
+// Package text holds string helpers shared by the record writers.
+package text
+
+// Truncate shortens s to at most max characters.
+//
+// It counts runes like the max length of a PocketBase text field, so a
+// stored value is never cut in the middle of a multi-byte character.
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	for i := range s {
+		if max == 0 {
+			return s[:i]
+		}
+		max--
+	}
+
+	return s
+}
@@ -0,0 +1,35 @@
+package text_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jryannel/spindit/internal/app/text"
+)
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		value string
+		max   int
+		want  string
+	}{
+		{"", 3, ""},
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"Müller", 3, "Mül"},
+		{"Müller", 6, "Müller"},
+		{"Überweisung", 1, "Ü"},
+		{"€€€€", 2, "€€"},
+		{"abc", 0, ""},
+	}
+
+	for _, c := range cases {
+		got := text.Truncate(c.value, c.max)
+		if got != c.want {
+			t.Errorf("Truncate(%q, %d): expected %q, got %q", c.value, c.max, c.want, got)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d): expected valid UTF-8, got %q", c.value, c.max, got)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+// Package webhooks delivers domain events, such as a locker changing
+// hands, as signed JSON POSTs to the subscribed outbound webhooks.
+//
+// Events are queued as webhook_deliveries once their record change is
+// committed and posted by the webhooks.deliver job, which retries failed
+// deliveries with an increasing delay.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/text"
+)
+
+const (
+	// Collection is the name of the webhook subscriptions collection.
+	Collection = "webhooks"
+	// DeliveriesCollection is the name of the delivery log collection.
+	DeliveriesCollection = "webhook_deliveries"
+)
+
+// Domain events.
+const (
+	EventRequestCreated      = "request.created"
+	EventReservationExpired  = "reservation.expired"
+	EventInvoicePaid         = "invoice.paid"
+	EventAssignmentCreated   = "assignment.created"
+	EventLockerStatusChanged = "locker.status_changed"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Request headers of a delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret, prefixed with
+// "sha256=".
+const (
+	EventHeader     = "X-Spindit-Event"
+	DeliveryHeader  = "X-Spindit-Delivery"
+	TimestampHeader = "X-Spindit-Timestamp"
+	SignatureHeader = "X-Spindit-Signature"
+)
+
+// retryDelays are the waits after a failed attempt. A delivery fails for
+// good after len(retryDelays)+1 attempts.
+var retryDelays = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	12 * time.Hour,
+}
+
+// MaxAttempts is the number of attempts of a delivery.
+var MaxAttempts = len(retryDelays) + 1
+
+// dataFields are the fields of each collection sent as [Payload.Data],
+// besides the id.
+var dataFields = map[string][]string{
+	"requests":          {"user", "status", "school_year", "preferred_zone", "submitted_at"},
+	"assignments":       {"request", "locker", "assigned_at"},
+	invoices.Collection: {"request", "number", "status", "amount", "currency", "due_at", "paid_at"},
+	"lockers":           {"zone", "number", "label", "status"},
+}
+
+// batchSize caps the deliveries posted per run, so a backlog does not hold
+// the job lease for long.
+const batchSize = 100
+
+const requestTimeout = 10 * time.Second
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	// Id identifies the event; retries and replays keep it.
+	Id        string         `json:"id"`
+	Event     string         `json:"event"`
+	CreatedAt types.DateTime `json:"created_at"`
+	// Data holds the ids, status and school year of the changed record, see
+	// [Data]. Names, contact details and free texts are never sent.
+	Data map[string]any `json:"data"`
+	// Previous holds the changed fields before the change, if relevant.
+	Previous map[string]any `json:"previous,omitempty"`
+}
+
+// Report summarizes a delivery run.
+type Report struct {
+	Delivered int
+	Retried   int
+	Failed    int
+}
+
+// Register queues the domain events of committed record changes.
+//
+// The hooks use app rather than the event app, which may still be the
+// committed transaction of a hook that saved the record.
+func Register(app core.App) {
+	app.OnRecordAfterCreateSuccess("requests").BindFunc(func(e *core.RecordEvent) error {
+		enqueue(app, EventRequestCreated, e.Record, nil)
+		return e.Next()
+	})
+
+	app.OnRecordAfterCreateSuccess("assignments").BindFunc(func(e *core.RecordEvent) error {
+		enqueue(app, EventAssignmentCreated, e.Record, nil)
+		return e.Next()
+	})
+
+	// reservations expire by moving their request to "expired"
+	app.OnRecordAfterUpdateSuccess("requests").BindFunc(func(e *core.RecordEvent) error {
+		if changed(e.Record, "status") && e.Record.GetString("status") == "expired" {
+			enqueue(app, EventReservationExpired, e.Record, previous(e.Record, "status"))
+		}
+		return e.Next()
+	})
+
+	app.OnRecordAfterCreateSuccess(invoices.Collection).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("status") == invoices.StatusPaid {
+			enqueue(app, EventInvoicePaid, e.Record, nil)
+		}
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess(invoices.Collection).BindFunc(func(e *core.RecordEvent) error {
+		if changed(e.Record, "status") && e.Record.GetString("status") == invoices.StatusPaid {
+			enqueue(app, EventInvoicePaid, e.Record, previous(e.Record, "status"))
+		}
+		return e.Next()
+	})
+
+	app.OnRecordAfterUpdateSuccess("lockers").BindFunc(func(e *core.RecordEvent) error {
+		if changed(e.Record, "status") {
+			enqueue(app, EventLockerStatusChanged, e.Record, previous(e.Record, "status"))
+		}
+		return e.Next()
+	})
+}
+
+func changed(record *core.Record, field string) bool {
+	original := record.Original()
+
+	return original != nil && original.GetString(field) != record.GetString(field)
+}
+
+func previous(record *core.Record, field string) map[string]any {
+	return map[string]any{field: record.Original().Get(field)}
+}
+
+// enqueue logs instead of failing, as the record change is already
+// committed.
+func enqueue(app core.App, event string, record *core.Record, prev map[string]any) {
+	if _, err := Enqueue(app, event, record, prev); err != nil {
+		app.Logger().Error("failed to queue the webhook event", "event", event, "record", record.Id, "error", err)
+	}
+}
+
+// Enqueue queues event about record for every active webhook subscribed to
+// it and returns the created deliveries.
+func Enqueue(app core.App, event string, record *core.Record, prev map[string]any) ([]*core.Record, error) {
+	hooks, err := query.FindAll(app, Collection, query.Eq("active", true), "url", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed := []*core.Record{}
+	for _, hook := range hooks {
+		if slices.Contains(hook.GetStringSlice("events"), event) {
+			subscribed = append(subscribed, hook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil, nil
+	}
+
+	collection, err := app.FindCollectionByNameOrId(DeliveriesCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now(app)
+
+	createdAt, err := types.ParseDateTime(now)
+	if err != nil {
+		return nil, err
+	}
+	payload := Payload{
+		Id:        core.GenerateDefaultRandomId(),
+		Event:     event,
+		CreatedAt: createdAt,
+		Data:      Data(app, record),
+		Previous:  prev,
+	}
+
+	deliveries := make([]*core.Record, 0, len(subscribed))
+	for _, hook := range subscribed {
+		delivery := core.NewRecord(collection)
+		delivery.Set("webhook", hook.Id)
+		delivery.Set("event", event)
+		delivery.Set("event_id", payload.Id)
+		delivery.Set("payload", payload)
+		delivery.Set("status", StatusPending)
+		delivery.Set("next_attempt_at", now)
+		if err := app.Save(delivery); err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// Data returns the event data of record: its id and the [dataFields] of its
+// collection. Assignments add the label of their locker and the school year
+// of their request.
+func Data(app core.App, record *core.Record) map[string]any {
+	data := map[string]any{
+		"id":             record.Id,
+		"collectionName": record.Collection().Name,
+	}
+	for _, field := range dataFields[record.Collection().Name] {
+		data[field] = record.Get(field)
+	}
+
+	if record.Collection().Name == "assignments" {
+		if locker, err := app.FindRecordById("lockers", record.GetString("locker")); err == nil {
+			data["locker_label"] = locker.GetString("label")
+		}
+		if request, err := app.FindRecordById("requests", record.GetString("request")); err == nil {
+			data["school_year"] = request.GetString("school_year")
+		}
+	}
+
+	return data
+}
+
+// Deliver posts the pending deliveries due at now.
+//
+// A dry run posts nothing and reports the due deliveries as delivered.
+func Deliver(app core.App, now time.Time, dryRun bool) (Report, error) {
+	report := Report{}
+
+	due, err := query.FindAll(app, DeliveriesCollection, query.And(
+		query.Eq("status", StatusPending),
+		query.Where("next_attempt_at", query.OpLte, now.UTC().Format(types.DefaultDateLayout)),
+	), "next_attempt_at", batchSize, 0)
+	if err != nil {
+		return report, err
+	}
+
+	if dryRun {
+		report.Delivered = len(due)
+		return report, nil
+	}
+
+	for _, delivery := range due {
+		if err := Attempt(app, delivery, now); err != nil {
+			return report, err
+		}
+
+		switch delivery.GetString("status") {
+		case StatusDelivered:
+			report.Delivered++
+		case StatusFailed:
+			report.Failed++
+		default:
+			report.Retried++
+		}
+	}
+
+	return report, nil
+}
+
+// Attempt posts delivery once at now and records the outcome: delivered on
+// a 2xx response, otherwise pending with the next retry or failed after
+// [MaxAttempts]. Only saving the outcome returns an error.
+func Attempt(app core.App, delivery *core.Record, now time.Time) error {
+	hook, err := app.FindRecordById(Collection, delivery.GetString("webhook"))
+	if err != nil {
+		return err
+	}
+
+	status, postErr := post(hook, delivery, now)
+
+	attempts := delivery.GetInt("attempts") + 1
+	delivery.Set("attempts", attempts)
+	delivery.Set("response_status", status)
+
+	switch {
+	case postErr == nil:
+		delivery.Set("status", StatusDelivered)
+		delivery.Set("delivered_at", now)
+		delivery.Set("next_attempt_at", nil)
+		delivery.Set("error", "")
+	case attempts >= MaxAttempts:
+		delivery.Set("status", StatusFailed)
+		delivery.Set("next_attempt_at", nil)
+		delivery.Set("error", text.Truncate(postErr.Error(), 1000))
+	default:
+		delivery.Set("status", StatusPending)
+		delivery.Set("next_attempt_at", now.Add(retryDelays[attempts-1]))
+		delivery.Set("error", text.Truncate(postErr.Error(), 1000))
+	}
+
+	return app.Save(delivery)
+}
+
+// Replay queues a copy of delivery, e.g. one that failed for good or that
+// a receiver lost, and attempts it right away.
+func Replay(app core.App, delivery *core.Record, now time.Time) (*core.Record, error) {
+	replay := core.NewRecord(delivery.Collection())
+	replay.Set("webhook", delivery.GetString("webhook"))
+	replay.Set("event", delivery.GetString("event"))
+	replay.Set("event_id", delivery.GetString("event_id"))
+	replay.Set("payload", delivery.Get("payload"))
+	replay.Set("status", StatusPending)
+	replay.Set("next_attempt_at", now)
+	replay.Set("replay_of", delivery.Id)
+	if err := app.Save(replay); err != nil {
+		return nil, err
+	}
+
+	return replay, Attempt(app, replay, now)
+}
+
+// Sign returns the signature header value of body sent at timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(hook *core.Record, delivery *core.Record, now time.Time) (int, error) {
+	if !hook.GetBool("active") {
+		return 0, fmt.Errorf("webhook %s is inactive", hook.Id)
+	}
+
+	body, err := json.Marshal(delivery.Get("payload"))
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.GetString("url"), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Spindit-Webhooks")
+	req.Header.Set(EventHeader, delivery.GetString("event"))
+	req.Header.Set(DeliveryHeader, delivery.Id)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.GetString("secret"), timestamp, body))
+
+	client := &http.Client{Timeout: requestTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s responded with status %d", hook.GetString("url"), resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,179 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/jryannel/spindit/internal/app/query"
+	"github.com/jryannel/spindit/internal/app/requests"
+	"github.com/jryannel/spindit/internal/app/webhooks"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestWebhooks(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	const secret = "0123456789abcdef0123"
+
+	type delivery struct {
+		event   string
+		payload webhooks.Payload
+	}
+	received := make(chan delivery, 20)
+	var fail atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(webhooks.SignatureHeader), webhooks.Sign(secret, r.Header.Get(webhooks.TimestampHeader), body); got != want {
+			t.Errorf("expected signature %q, got %q", want, got)
+		}
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload webhooks.Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- delivery{event: r.Header.Get(webhooks.EventHeader), payload: payload}
+	}))
+	defer receiver.Close()
+
+	hooks, err := app.FindCollectionByNameOrId(webhooks.Collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := core.NewRecord(hooks)
+	hook.Set("url", receiver.URL)
+	hook.Set("secret", secret)
+	hook.Set("events", []string{webhooks.EventRequestCreated, webhooks.EventAssignmentCreated, webhooks.EventLockerStatusChanged})
+	hook.Set("active", true)
+	if err := app.Save(hook); err != nil {
+		t.Fatal(err)
+	}
+
+	request, locker := testutil.ReservedRequest(t, app)
+
+	testutil.AssertCount(t, app, webhooks.DeliveriesCollection, dbx.HashExp{"status": webhooks.StatusPending}, 3)
+
+	report, err := webhooks.Deliver(app, testutil.Start, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Delivered != 3 {
+		t.Fatalf("expected 3 delivered events, got %+v", report)
+	}
+
+	events := map[string]webhooks.Payload{}
+	for i := 0; i < 3; i++ {
+		d := <-received
+		events[d.event] = d.payload
+	}
+	if got := events[webhooks.EventRequestCreated].Data["id"]; got != request.Id {
+		t.Fatalf("expected the request.created payload of %s, got %v", request.Id, got)
+	}
+	lockerEvent := events[webhooks.EventLockerStatusChanged]
+	if lockerEvent.Data["id"] != locker.Id || lockerEvent.Data["status"] != "reserved" || lockerEvent.Previous["status"] != "free" {
+		t.Fatalf("expected the locker to change from free to reserved, got %+v", lockerEvent)
+	}
+	assignmentEvent, ok := events[webhooks.EventAssignmentCreated]
+	if !ok {
+		t.Fatalf("expected an assignment.created event, got %v", events)
+	}
+	if assignmentEvent.Data["locker_label"] != locker.GetString("label") || assignmentEvent.Data["school_year"] != request.GetString("school_year") {
+		t.Fatalf("expected the locker label and school year in the assignment payload, got %v", assignmentEvent.Data)
+	}
+
+	// receivers never get personal data
+	for event, payload := range events {
+		for _, field := range []string{"student_name", "student_class", "requester_name", "requester_address", "requester_phone", "note"} {
+			if _, ok := payload.Data[field]; ok {
+				t.Fatalf("expected no %s in the %s payload, got %v", field, event, payload.Data)
+			}
+		}
+	}
+
+	// unchanged locker statuses are no event
+	locker = testutil.Reload(t, app, locker)
+	locker.Set("note", "updated")
+	if err := app.Save(locker); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertCount(t, app, webhooks.DeliveriesCollection, dbx.HashExp{"status": webhooks.StatusPending}, 0)
+
+	// a failing receiver is retried with a delay
+	fail.Store(true)
+	if err := requests.Cancel(app, testutil.Reload(t, app, request)); err != nil {
+		t.Fatal(err)
+	}
+	report, err = webhooks.Deliver(app, testutil.Start, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Retried != 1 {
+		t.Fatalf("expected 1 retried delivery, got %+v", report)
+	}
+
+	retried, err := query.FindFirst(app, webhooks.DeliveriesCollection, query.Eq("status", webhooks.StatusPending))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.GetInt("attempts") != 1 || retried.GetInt("response_status") != http.StatusServiceUnavailable {
+		t.Fatalf("expected 1 failed attempt with status 503, got %d and %d", retried.GetInt("attempts"), retried.GetInt("response_status"))
+	}
+	if got := retried.GetDateTime("next_attempt_at").Time(); !got.Equal(testutil.Start.Add(time.Minute)) {
+		t.Fatalf("expected the retry at %s, got %s", testutil.Start.Add(time.Minute), got)
+	}
+
+	// not due yet
+	if report, _ := webhooks.Deliver(app, testutil.Start.Add(30*time.Second), false); report != (webhooks.Report{}) {
+		t.Fatalf("expected no due deliveries, got %+v", report)
+	}
+
+	for i := 1; i < webhooks.MaxAttempts; i++ {
+		retried = testutil.Reload(t, app, retried)
+		if err := webhooks.Attempt(app, retried, testutil.Start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testutil.AssertString(t, "delivery status", retried.GetString("status"), webhooks.StatusFailed)
+
+	// replaying keeps the event id and succeeds once the receiver is back
+	fail.Store(false)
+	replay, err := webhooks.Replay(app, retried, testutil.Start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "replay status", replay.GetString("status"), webhooks.StatusDelivered)
+	testutil.AssertString(t, "replay_of", replay.GetString("replay_of"), retried.Id)
+
+	d := <-received
+	if d.payload.Id != retried.GetString("event_id") || d.event != webhooks.EventLockerStatusChanged {
+		t.Fatalf("expected the replayed %s event, got %+v", retried.GetString("event_id"), d)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// reference value computed independently with
+	// printf '1722506400.{"event":"ping"}' | openssl dgst -sha256 -hmac whsec_test
+	const want = "sha256=4868a8a508e557910db401400052341d860f25cd11fe7321a718ead94ec49ddd"
+
+	body := []byte(`{"event":"ping"}`)
+	testutil.AssertString(t, "signature", webhooks.Sign("whsec_test", "1722506400", body), want)
+
+	if webhooks.Sign("whsec_test", "1722506401", body) == want {
+		t.Fatal("expected the timestamp to be part of the signature")
+	}
+	if webhooks.Sign("whsec_other", "1722506400", body) == want {
+		t.Fatal("expected the secret to be part of the signature")
+	}
+}
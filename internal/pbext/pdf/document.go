@@ -0,0 +1,260 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page sizes in PDF points (1/72 inch).
+var (
+	A4Portrait  = Size{Width: 595.28, Height: 841.89}
+	A4Landscape = Size{Width: 841.89, Height: 595.28}
+)
+
+// Size describes the dimensions of a page.
+type Size struct {
+	Width  float64
+	Height float64
+}
+
+const (
+	catalogObject   = 1
+	pagesObject     = 2
+	fontObject      = 3
+	boldFontObject  = 4
+	firstFreeObject = 5
+)
+
+// Document streams a text based PDF document to an underlying writer.
+//
+// Pages are flushed as soon as the next page starts, so only the content
+// of the current page is kept in memory. The document uses the standard
+// Helvetica fonts, which support the Latin-1 character set.
+type Document struct {
+	w       *countingWriter
+	size    Size
+	offsets map[int]int64
+	nextObj int
+	pages   []int
+	content *bytes.Buffer
+	closed  bool
+}
+
+// NewDocument starts a new PDF document with pages of the given size.
+func NewDocument(w io.Writer, size Size) *Document {
+	d := &Document{
+		w:       &countingWriter{w: w},
+		size:    size,
+		offsets: map[int]int64{},
+		nextObj: firstFreeObject,
+	}
+
+	d.w.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	d.writeObject(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	d.writeObject(fontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	d.writeObject(boldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	return d
+}
+
+// Size returns the page size of the document.
+func (d *Document) Size() Size {
+	return d.size
+}
+
+// AddPage flushes the current page (if any) and starts a new empty one.
+func (d *Document) AddPage() error {
+	if d.closed {
+		return errors.New("pdf: document is closed")
+	}
+
+	if err := d.flushPage(); err != nil {
+		return err
+	}
+
+	d.content = &bytes.Buffer{}
+
+	return d.w.err
+}
+
+// Text draws s at the given position, measured in points from the bottom left page corner.
+func (d *Document) Text(x, y, fontSize float64, bold bool, s string) {
+	if d.content == nil {
+		d.AddPage()
+	}
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	fmt.Fprintf(d.content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, fontSize, x, y, escapeText(s))
+}
+
+// Line draws a straight line between two points.
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	if d.content == nil {
+		d.AddPage()
+	}
+
+	fmt.Fprintf(d.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// QRCode draws code as a square of size points with its bottom left corner
+// at x, y. The quiet zone is not included, so leave a margin of four
+// modules around it.
+func (d *Document) QRCode(x, y, size float64, code *QRCode) {
+	if d.content == nil {
+		d.AddPage()
+	}
+
+	module := size / float64(code.Size)
+
+	d.content.WriteString("q 0 g\n")
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; {
+			if !code.Dark(col, row) {
+				col++
+				continue
+			}
+
+			run := 1
+			for code.Dark(col+run, row) {
+				run++
+			}
+			fmt.Fprintf(d.content, "%.3f %.3f %.3f %.3f re\n",
+				x+float64(col)*module, y+size-float64(row+1)*module, float64(run)*module, module)
+			col += run
+		}
+	}
+	d.content.WriteString("f Q\n")
+}
+
+// Close flushes the last page and writes the page tree, cross-reference table and trailer.
+func (d *Document) Close() error {
+	if d.closed {
+		return nil
+	}
+
+	if d.content == nil {
+		if err := d.AddPage(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.flushPage(); err != nil {
+		return err
+	}
+	d.closed = true
+
+	kids := make([]string, len(d.pages))
+	for i, page := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	d.writeObject(pagesObject, fmt.Sprintf(
+		"<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>",
+		strings.Join(kids, " "), len(d.pages), d.size.Width, d.size.Height,
+	))
+
+	xref := d.w.n
+	d.w.printf("xref\n0 %d\n0000000000 65535 f \n", d.nextObj)
+	for obj := 1; obj < d.nextObj; obj++ {
+		d.w.printf("%010d 00000 n \n", d.offsets[obj])
+	}
+	d.w.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", d.nextObj, catalogObject, xref)
+
+	return d.w.err
+}
+
+func (d *Document) flushPage() error {
+	if d.content == nil {
+		return nil
+	}
+
+	contentObj := d.allocObject()
+	d.writeStream(contentObj, d.content.Bytes())
+
+	pageObj := d.allocObject()
+	d.writeObject(pageObj, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pagesObject, fontObject, boldFontObject, contentObj,
+	))
+	d.pages = append(d.pages, pageObj)
+	d.content = nil
+
+	return d.w.err
+}
+
+func (d *Document) allocObject() int {
+	obj := d.nextObj
+	d.nextObj++
+	return obj
+}
+
+func (d *Document) writeObject(obj int, body string) {
+	d.offsets[obj] = d.w.n
+	d.w.printf("%d 0 obj\n%s\nendobj\n", obj, body)
+}
+
+func (d *Document) writeStream(obj int, data []byte) {
+	d.offsets[obj] = d.w.n
+	d.w.printf("%d 0 obj\n<< /Length %d >>\nstream\n", obj, len(data))
+	d.w.write(data)
+	d.w.printf("\nendstream\nendobj\n")
+}
+
+// winAnsiExtras maps the commonly used characters outside of Latin-1 to their WinAnsi codes.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80,
+	'…': 0x85,
+	'‘': 0x91,
+	'’': 0x92,
+	'“': 0x93,
+	'”': 0x94,
+	'•': 0x95,
+	'–': 0x96,
+	'—': 0x97,
+}
+
+// escapeText converts s to WinAnsi bytes and escapes the PDF string delimiters.
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case winAnsiExtras[r] != 0:
+			b.WriteByte(winAnsiExtras[r])
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) write(p []byte) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) printf(format string, args ...any) {
+	cw.write([]byte(fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,75 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGiroCodePayload(t *testing.T) {
+	g := GiroCode{
+		Name:       "Förderverein  Musterschule",
+		IBAN:       "de89 3704 0044 0532 0130 00",
+		BIC:        "cobadeffxxx",
+		Amount:     20,
+		Remittance: "INV-000001\nLocker A-001",
+	}
+
+	got, err := g.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"BCD", "002", "1", "SCT", "COBADEFFXXX", "Förderverein Musterschule",
+		"DE89370400440532013000", "EUR20.00", "", "", "INV-000001 Locker A-001",
+	}, "\n")
+	if got != want {
+		t.Fatalf("expected the payload\n%s\ngot\n%s", want, got)
+	}
+
+	code, err := g.QRCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := qrDecode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != want {
+		t.Fatalf("expected the symbol to hold the payload, got %q", decoded)
+	}
+
+	for name, invalid := range map[string]GiroCode{
+		"no name":        {IBAN: g.IBAN, Amount: 20},
+		"bad IBAN":       {Name: "Schule", IBAN: "DE88370400440532013000", Amount: 20},
+		"bad BIC":        {Name: "Schule", IBAN: g.IBAN, BIC: "COBADE", Amount: 20},
+		"zero amount":    {Name: "Schule", IBAN: g.IBAN},
+		"long name":      {Name: strings.Repeat("x", 71), IBAN: g.IBAN, Amount: 20},
+		"long reference": {Name: "Schule", IBAN: g.IBAN, Amount: 20, Remittance: strings.Repeat("x", 141)},
+	} {
+		if _, err := invalid.Payload(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidIBAN(t *testing.T) {
+	for iban, want := range map[string]bool{
+		"DE89370400440532013000":      true,
+		"GB82WEST12345698765432":      true,
+		"AT611904300234573201":        true,
+		"DE89370400440532013001":      false,
+		"DE8937040044053201300":       false,
+		"de89370400440532013000":      false, // not normalized
+		"DE89 3704 0044 0532 0130 00": false,
+	} {
+		if got := ValidIBAN(iban); got != want {
+			t.Errorf("ValidIBAN(%q) = %v, want %v", iban, got, want)
+		}
+	}
+
+	normalized := NormalizeIBAN(" de89 3704 0044\t0532 0130 00 ")
+	if normalized != "DE89370400440532013000" {
+		t.Fatalf("unexpected normalized IBAN %q", normalized)
+	}
+}
@@ -0,0 +1,153 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	invoiceMargin   = 56.0
+	invoiceFontSize = 10.0
+	invoiceLine     = 15.0
+	girocodeSize    = 113.0 // 40 mm, comfortably above the 10 mm minimum of EPC069-12
+)
+
+// Invoice is the content of an invoice document.
+type Invoice struct {
+	Number string
+	// Issuer is printed above the recipient, e.g. the school.
+	Issuer    string
+	Recipient []string
+	IssuedAt  time.Time
+	DueAt     time.Time
+	Items     []InvoiceItem
+	Currency  string
+	// Payment adds the bank details and a GiroCode for the total.
+	Payment *GiroCode
+}
+
+// InvoiceItem is a line of an [Invoice].
+type InvoiceItem struct {
+	Description string
+	Amount      float64
+}
+
+// Total returns the sum of the items.
+func (inv Invoice) Total() float64 {
+	total := 0.0
+	for _, item := range inv.Items {
+		total += item.Amount
+	}
+
+	return total
+}
+
+// WriteInvoice renders inv as a single page A4 document. With a payment,
+// the page ends with the bank details and the GiroCode of the transfer.
+func WriteInvoice(w io.Writer, inv Invoice) error {
+	var code *QRCode
+	if inv.Payment != nil {
+		var err error
+		if code, err = inv.Payment.QRCode(); err != nil {
+			return err
+		}
+	}
+
+	doc := NewDocument(w, A4Portrait)
+	if err := doc.AddPage(); err != nil {
+		return err
+	}
+
+	size := doc.Size()
+	right := size.Width - invoiceMargin
+	y := size.Height - invoiceMargin - 4
+
+	if inv.Issuer != "" {
+		doc.Text(invoiceMargin, y, 8, false, inv.Issuer)
+	}
+	y -= 2 * invoiceLine
+	for _, line := range inv.Recipient {
+		doc.Text(invoiceMargin, y, invoiceFontSize, false, line)
+		y -= invoiceLine
+	}
+
+	y -= 2 * invoiceLine
+	doc.Text(invoiceMargin, y, 16, true, "Invoice "+inv.Number)
+	y -= 1.5 * invoiceLine
+	doc.Text(invoiceMargin, y, invoiceFontSize, false, "Invoice date: "+formatInvoiceDate(inv.IssuedAt))
+	if !inv.DueAt.IsZero() {
+		doc.Text(invoiceMargin+180, y, invoiceFontSize, false, "Due date: "+formatInvoiceDate(inv.DueAt))
+	}
+
+	y -= 2 * invoiceLine
+	doc.Text(invoiceMargin, y, invoiceFontSize, true, "Description")
+	rightText(doc, right, y, true, "Amount")
+	doc.Line(invoiceMargin, y-4, right, y-4, 0.5)
+	y -= invoiceLine + 2
+	for _, item := range inv.Items {
+		doc.Text(invoiceMargin, y, invoiceFontSize, false, truncate(item.Description, right-invoiceMargin-100))
+		rightText(doc, right, y, false, formatInvoiceAmount(item.Amount, inv.Currency))
+		y -= invoiceLine
+	}
+	doc.Line(invoiceMargin, y+invoiceLine-4, right, y+invoiceLine-4, 0.5)
+	doc.Text(invoiceMargin, y-2, invoiceFontSize, true, "Total")
+	rightText(doc, right, y-2, true, formatInvoiceAmount(inv.Total(), inv.Currency))
+
+	if inv.Payment != nil {
+		y -= 4 * invoiceLine
+		doc.Text(invoiceMargin, y, invoiceFontSize, true, "Payment")
+		y -= invoiceLine
+		lines := []string{
+			"Please transfer the total by the due date to",
+			"Account holder: " + singleLine(inv.Payment.Name),
+			"IBAN: " + formatIBAN(NormalizeIBAN(inv.Payment.IBAN)),
+		}
+		if inv.Payment.BIC != "" {
+			lines = append(lines, "BIC: "+strings.ToUpper(inv.Payment.BIC))
+		}
+		lines = append(lines, "Reference: "+singleLine(inv.Payment.Remittance))
+		top := y
+		for _, line := range lines {
+			doc.Text(invoiceMargin, y, invoiceFontSize, false, line)
+			y -= invoiceLine
+		}
+
+		doc.QRCode(right-girocodeSize, top-girocodeSize+invoiceFontSize, girocodeSize, code)
+		doc.Text(right-girocodeSize, top-girocodeSize-6, 7, false, "Scan with your banking app (GiroCode)")
+	}
+
+	return doc.Close()
+}
+
+// rightText draws s right aligned at x, with the approximate Helvetica width.
+func rightText(doc *Document, x, y float64, bold bool, s string) {
+	width := float64(len([]rune(s))) * invoiceFontSize * averageCharSize
+	doc.Text(x-width, y, invoiceFontSize, bold, s)
+}
+
+func formatInvoiceDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format("02.01.2006")
+}
+
+func formatInvoiceAmount(amount float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", amount, strings.ToUpper(currency))
+}
+
+// formatIBAN groups iban in blocks of four characters.
+func formatIBAN(iban string) string {
+	var b strings.Builder
+	for i, r := range iban {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -5,7 +5,8 @@ import "github.com/pocketbase/pocketbase/core"
 const Version = "v0.30"
 
 // Register attaches the PDF generation extension to the PocketBase app.
-// The actual template rendering will be completed in later milestones.
+// Documents are rendered on demand through [NewDocument] and [NewTable],
+// invoices through [WriteInvoice] with an optional [GiroCode].
 func Register(app core.App) error {
 	_ = app
 	return nil
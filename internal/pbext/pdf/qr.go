@@ -0,0 +1,503 @@
+package pdf
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// qrQuietZone is the light border around a symbol required by ISO/IEC 18004, in modules.
+const qrQuietZone = 4
+
+// qrBlocks describes the error correction blocks of a version at level M:
+// the EC codewords per block and the number and size of the short and long
+// data blocks.
+type qrBlocks struct {
+	ecPerBlock int
+	shortCount int
+	shortData  int
+	longCount  int
+	longData   int
+}
+
+// qrVersionsM lists versions 1 to 13 at error correction level M, which
+// holds up to 331 bytes, the maximum size of an EPC069-12 payload.
+var qrVersionsM = []qrBlocks{
+	{10, 1, 16, 0, 0},
+	{16, 1, 28, 0, 0},
+	{26, 1, 44, 0, 0},
+	{18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0},
+	{16, 4, 27, 0, 0},
+	{18, 4, 31, 0, 0},
+	{22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37},
+	{26, 4, 43, 1, 44},
+	{30, 1, 50, 4, 51},
+	{22, 6, 36, 2, 37},
+	{22, 8, 37, 1, 38},
+}
+
+// qrAlignment lists the alignment pattern center coordinates per version.
+var qrAlignment = [][]int{
+	{},
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+	{6, 30, 54},
+	{6, 32, 58},
+	{6, 34, 62},
+}
+
+// ErrQRTooLong is returned by [EncodeQR] for data that does not fit into a
+// supported QR code version.
+var ErrQRTooLong = errors.New("pdf: data too long for a QR code")
+
+// QRCode is an encoded QR code symbol.
+type QRCode struct {
+	// Size is the width and height in modules, without quiet zone.
+	Size int
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// EncodeQR encodes data in byte mode with error correction level M, as
+// required by EPC069-12, choosing the smallest version that fits. Versions
+// 1 to 13 are supported, i.e. up to 331 bytes.
+func EncodeQR(data []byte) (*QRCode, error) {
+	version := 0
+	for v := 1; v <= len(qrVersionsM); v++ {
+		if 4+qrCountBits(v)+8*len(data) <= 8*qrVersionsM[v-1].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRTooLong
+	}
+
+	blocks := qrVersionsM[version-1]
+	capacity := 8 * blocks.dataCodewords()
+
+	bits := &qrBitBuffer{}
+	bits.append(0b0100, 4)
+	bits.append(len(data), qrCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, capacity-bits.len()))
+	bits.append(0, (8-bits.len()%8)%8)
+	for pad := 0xec; bits.len() < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	q := newQRCode(version)
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(blocks.interleave(bits.bytes()))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask) // masks are their own inverse
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+
+	return q, nil
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (q *QRCode) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < q.Size && y < q.Size && q.modules[y][x]
+}
+
+// Image renders the symbol with scale pixels per module and the quiet zone.
+func (q *QRCode) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+
+	width := (q.Size + 2*qrQuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, width, width), color.Palette{color.White, color.Black})
+	for y := 0; y < width; y++ {
+		for x := 0; x < width; x++ {
+			if q.Dark(x/scale-qrQuietZone, y/scale-qrQuietZone) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes the symbol as PNG image with scale pixels per module.
+func (q *QRCode) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, q.Image(scale))
+}
+
+func newQRCode(version int) *QRCode {
+	size := 4*version + 17
+	q := &QRCode{Size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunction[i] = make([]bool, size)
+	}
+
+	return q
+}
+
+func (b qrBlocks) dataCodewords() int {
+	return b.shortCount*b.shortData + b.longCount*b.longData
+}
+
+// interleave splits data into blocks, appends the error correction
+// codewords of each block and interleaves the result.
+func (b qrBlocks) interleave(data []byte) []byte {
+	divisor := rsDivisor(b.ecPerBlock)
+
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < b.shortCount+b.longCount; i++ {
+		size := b.shortData
+		if i >= b.shortCount {
+			size = b.longData
+		}
+		dataBlocks = append(dataBlocks, data[:size])
+		ecBlocks = append(ecBlocks, rsRemainder(data[:size], divisor))
+		data = data[size:]
+	}
+
+	result := []byte{}
+	for i := 0; i < max(b.shortData, b.longData); i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+// qrCountBits is the length of the byte mode character count.
+func qrCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+func (q *QRCode) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+func (q *QRCode) drawFunctionPatterns(version int) {
+	for i := 0; i < q.Size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	q.drawFinder(3, 3)
+	q.drawFinder(q.Size-4, 3)
+	q.drawFinder(3, q.Size-4)
+
+	positions := qrAlignment[version-1]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas, drawn after masking
+	q.drawFormatBits(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := q.Size-11+i%3, i/3
+			q.setFunction(a, b, dark)
+			q.setFunction(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern with its separator around the center x, y.
+func (q *QRCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= q.Size || yy >= q.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information of level M
+// with mask, plus the dark module.
+func (q *QRCode) drawFormatBits(mask int) {
+	data := 0b00<<3 | mask // level M
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, bit(i))
+	}
+	q.setFunction(8, q.Size-8, true)
+}
+
+// drawCodewords places data in the zigzag pattern of two module wide
+// columns from the bottom right, skipping the function patterns.
+func (q *QRCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if q.isFunction[y][x] || i >= len(data)*8 {
+					continue
+				}
+				q.modules[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.isFunction[y][x] {
+				continue
+			}
+
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the mask evaluation rules of ISO/IEC 18004;
+// the mask with the lowest score is used.
+func (q *QRCode) penalty() int {
+	penalty := 0
+	dark := 0
+
+	// finderLike is the 1:1:3:1:1 finder pattern with four light modules on either side.
+	finderLike := []bool{true, false, true, true, true, false, true}
+	matches := func(get func(int) bool, start int) bool {
+		for i, want := range finderLike {
+			if get(start+i) != want {
+				return false
+			}
+		}
+		light := func(from int) bool {
+			for i := from; i < from+4; i++ {
+				if get(i) {
+					return false
+				}
+			}
+			return true
+		}
+		return light(start-4) || light(start+len(finderLike))
+	}
+
+	for i := 0; i < q.Size; i++ {
+		row := func(x int) bool { return q.Dark(x, i) }
+		column := func(y int) bool { return q.Dark(i, y) }
+
+		for _, get := range []func(int) bool{row, column} {
+			run := 1
+			for j := 1; j <= q.Size; j++ {
+				if j < q.Size && get(j) == get(j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+
+			for j := 0; j+len(finderLike) <= q.Size; j++ {
+				if matches(get, j) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.Size && y+1 < q.Size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	total := q.Size * q.Size
+	penalty += abs(dark*20-total*10) / total * 10
+
+	return penalty
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of degree n,
+// without its leading coefficient.
+func rsDivisor(n int) []byte {
+	result := make([]byte, n)
+	result[n-1] = 1
+
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>i&1) * int(x)
+	}
+
+	return byte(z)
+}
+
+type qrBitBuffer struct {
+	bits []bool
+}
+
+func (b *qrBitBuffer) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, value>>i&1 == 1)
+	}
+}
+
+func (b *qrBitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *qrBitBuffer) bytes() []byte {
+	result := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			result[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+
+	return result
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,304 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// qrFormatM are the format information strings of level M by mask, as
+// listed in ISO/IEC 18004 table C.1, most significant bit first.
+var qrFormatM = []string{
+	"101010000010010",
+	"101000100100101",
+	"101111001111100",
+	"101101101001011",
+	"100010111111001",
+	"100000011001110",
+	"100111110010111",
+	"100101010100000",
+}
+
+// qrVersionInfo are the version information strings of ISO/IEC 18004
+// table D.1, most significant bit first.
+var qrVersionInfo = map[int]string{
+	7:  "000111110010010100",
+	8:  "001000010110111100",
+	9:  "001001101010011001",
+	10: "001010010011010011",
+	11: "001011101111110110",
+	12: "001100011101100010",
+	13: "001101100001000111",
+}
+
+// qrReference is the symbol of "INV-000001": version 1, level M, mask 2.
+var qrReference = []string{
+	"#######...##..#######",
+	"#.....#....#..#.....#",
+	"#.###.#.#..##.#.###.#",
+	"#.###.#.####..#.###.#",
+	"#.###.#.#..##.#.###.#",
+	"#.....#.##..#.#.....#",
+	"#######.#.#.#.#######",
+	"........#####........",
+	"#.#####...#.#.#####..",
+	".#.###.##.#.#...##...",
+	"..#...####.#...#...#.",
+	"..##...####....#.###.",
+	"###.#.#..###.###.##.#",
+	"........#.###...###..",
+	"#######.....#..#...#.",
+	"#.....#.##.##..#..#.#",
+	"#.###.#.###.####....#",
+	"#.###.#.###.#...##...",
+	"#.###.#.#..#.#.#.##..",
+	"#.....#..##....#..#..",
+	"#######.#..#...#.#.#.",
+}
+
+func TestQRReedSolomon(t *testing.T) {
+	// the "HELLO WORLD" 1-M example of the thonky.com QR code tutorial
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Fatalf("expected the error correction codewords %v, got %v", want, got)
+	}
+}
+
+func TestQRFormatInformation(t *testing.T) {
+	for mask, want := range qrFormatM {
+		q := newQRCode(1)
+		q.drawFormatBits(mask)
+
+		// the first copy around the top left finder, the second split
+		// between the bottom left and top right finders
+		first, second := "", ""
+		for x := 0; x <= 5; x++ {
+			first += qrBit(q, x, 8)
+		}
+		first += qrBit(q, 7, 8) + qrBit(q, 8, 8) + qrBit(q, 8, 7)
+		for y := 5; y >= 0; y-- {
+			first += qrBit(q, 8, y)
+		}
+		for y := q.Size - 1; y >= q.Size-7; y-- {
+			second += qrBit(q, 8, y)
+		}
+		for x := q.Size - 8; x < q.Size; x++ {
+			second += qrBit(q, x, 8)
+		}
+
+		if first != want || second != want {
+			t.Errorf("mask %d: expected the format information %s, got %s and %s", mask, want, first, second)
+		}
+	}
+}
+
+func TestQRVersionInformation(t *testing.T) {
+	for version, want := range qrVersionInfo {
+		q := newQRCode(version)
+		q.drawFunctionPatterns(version)
+
+		// both copies hold the least significant bit at the corner next to
+		// the timing pattern
+		bottomLeft, topRight := "", ""
+		for i := 17; i >= 0; i-- {
+			bottomLeft += qrBit(q, i/3, q.Size-11+i%3)
+			topRight += qrBit(q, q.Size-11+i%3, i/3)
+		}
+
+		if bottomLeft != want || topRight != want {
+			t.Errorf("version %d: expected the version information %s, got %s and %s", version, want, bottomLeft, topRight)
+		}
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	q, err := EncodeQR([]byte("INV-000001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := qrRows(q); strings.Join(got, "\n") != strings.Join(qrReference, "\n") {
+		t.Fatalf("expected the reference symbol\n%s\ngot\n%s", strings.Join(qrReference, "\n"), strings.Join(got, "\n"))
+	}
+
+	if _, err := EncodeQR(bytes.Repeat([]byte("x"), 332)); err != ErrQRTooLong {
+		t.Fatalf("expected ErrQRTooLong, got %v", err)
+	}
+}
+
+func TestEncodeQRDecodes(t *testing.T) {
+	for _, size := range []int{1, 14, 15, 42, 84, 85, 100, 213, 331} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte('A' + i%26)
+		}
+
+		q, err := EncodeQR(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := qrDecode(q)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes: expected %q, got %q", size, data, got)
+		}
+	}
+}
+
+func qrBit(q *QRCode, x, y int) string {
+	if q.Dark(x, y) {
+		return "1"
+	}
+	return "0"
+}
+
+func qrRows(q *QRCode) []string {
+	rows := make([]string, q.Size)
+	for y := range rows {
+		for x := 0; x < q.Size; x++ {
+			if q.Dark(x, y) {
+				rows[y] += "#"
+			} else {
+				rows[y] += "."
+			}
+		}
+	}
+
+	return rows
+}
+
+// qrDecode reads a symbol back by the rules of ISO/IEC 18004: the mask
+// from the format information, the codewords in placement order, the
+// error correction of each block and the byte mode segment.
+func qrDecode(q *QRCode) ([]byte, error) {
+	version := (q.Size - 17) / 4
+	blocks := qrVersionsM[version-1]
+
+	format := ""
+	for x := 0; x <= 5; x++ {
+		format += qrBit(q, x, 8)
+	}
+	format += qrBit(q, 7, 8) + qrBit(q, 8, 8) + qrBit(q, 8, 7)
+	for y := 5; y >= 0; y-- {
+		format += qrBit(q, 8, y)
+	}
+	mask := -1
+	for m, want := range qrFormatM {
+		if format == want {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		return nil, fmt.Errorf("no level M format information: %s", format)
+	}
+
+	masked := func(x, y int) bool {
+		switch mask {
+		case 0:
+			return (x+y)%2 == 0
+		case 1:
+			return y%2 == 0
+		case 2:
+			return x%3 == 0
+		case 3:
+			return (x+y)%3 == 0
+		case 4:
+			return (y/2+x/3)%2 == 0
+		case 5:
+			return x*y%2+x*y%3 == 0
+		case 6:
+			return (x*y%2+x*y%3)%2 == 0
+		default:
+			return ((x+y)%2+x*y%3)%2 == 0
+		}
+	}
+
+	bits := []bool{}
+	upward := true
+	for right := q.Size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right-- // skip the vertical timing pattern
+		}
+		for i := 0; i < q.Size; i++ {
+			y := i
+			if upward {
+				y = q.Size - 1 - i
+			}
+			for _, x := range []int{right, right - 1} {
+				if !q.isFunction[y][x] {
+					bits = append(bits, q.Dark(x, y) != masked(x, y))
+				}
+			}
+		}
+		upward = !upward
+	}
+
+	count := blocks.shortCount + blocks.longCount
+	total := blocks.dataCodewords() + count*blocks.ecPerBlock
+	codewords := make([]byte, total)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				codewords[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	// de-interleave: data codewords round robin over the blocks, the
+	// extra codeword of the long blocks last, then the EC codewords
+	dataBlocks := make([][]byte, count)
+	ecBlocks := make([][]byte, count)
+	next := 0
+	for i := 0; i < max(blocks.shortData, blocks.longData); i++ {
+		for b := 0; b < count; b++ {
+			size := blocks.shortData
+			if b >= blocks.shortCount {
+				size = blocks.longData
+			}
+			if i < size {
+				dataBlocks[b] = append(dataBlocks[b], codewords[next])
+				next++
+			}
+		}
+	}
+	for i := 0; i < blocks.ecPerBlock; i++ {
+		for b := 0; b < count; b++ {
+			ecBlocks[b] = append(ecBlocks[b], codewords[next])
+			next++
+		}
+	}
+
+	data := []byte{}
+	for b := range dataBlocks {
+		if ec := rsRemainder(dataBlocks[b], rsDivisor(blocks.ecPerBlock)); !bytes.Equal(ec, ecBlocks[b]) {
+			return nil, fmt.Errorf("block %d: invalid error correction codewords", b)
+		}
+		data = append(data, dataBlocks[b]...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		return nil, fmt.Errorf("expected a byte mode segment, got mode %04b", data[0]>>4)
+	}
+	read := func(offset, n int) int {
+		value := 0
+		for i := offset; i < offset+n; i++ {
+			value = value<<1 | int(data[i/8]>>(7-i%8)&1)
+		}
+		return value
+	}
+	countBits := qrCountBits(version)
+	length := read(4, countBits)
+
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = byte(read(4+countBits+8*i, 8))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package pdf
+
+import "unicode/utf8"
+
+const (
+	tableMargin     = 36.0
+	tableFontSize   = 9.0
+	tableRowHeight  = 14.0
+	titleFontSize   = 14.0
+	averageCharSize = 0.52 // approximate Helvetica glyph width relative to the font size
+)
+
+// Column describes a table column; Width is relative to the other columns.
+type Column struct {
+	Title string
+	Width float64
+}
+
+// Table renders rows of text into a [Document], repeating the section
+// title and column headers on every page break.
+type Table struct {
+	doc     *Document
+	columns []Column
+	widths  []float64
+	title   string
+	y       float64
+}
+
+// NewTable creates a table writer for doc with the provided columns.
+func NewTable(doc *Document, columns []Column) *Table {
+	total := 0.0
+	for _, col := range columns {
+		total += col.Width
+	}
+
+	available := doc.Size().Width - 2*tableMargin
+	widths := make([]float64, len(columns))
+	for i, col := range columns {
+		widths[i] = available * col.Width / total
+	}
+
+	return &Table{doc: doc, columns: columns, widths: widths}
+}
+
+// Section starts a new page headed by title.
+func (t *Table) Section(title string) error {
+	t.title = title
+	return t.newPage()
+}
+
+// Row appends a row of cell values, truncating values that do not fit their column.
+func (t *Table) Row(values ...string) error {
+	if t.y == 0 || t.y < tableMargin+tableRowHeight {
+		if err := t.newPage(); err != nil {
+			return err
+		}
+	}
+
+	t.drawCells(values, false)
+	t.y -= tableRowHeight
+
+	return nil
+}
+
+func (t *Table) newPage() error {
+	if err := t.doc.AddPage(); err != nil {
+		return err
+	}
+
+	t.y = t.doc.Size().Height - tableMargin - titleFontSize
+	if t.title != "" {
+		t.doc.Text(tableMargin, t.y, titleFontSize, true, t.title)
+		t.y -= 2 * tableRowHeight
+	}
+
+	headers := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		headers[i] = col.Title
+	}
+	t.drawCells(headers, true)
+	t.doc.Line(tableMargin, t.y-4, t.doc.Size().Width-tableMargin, t.y-4, 0.5)
+	t.y -= tableRowHeight
+
+	return nil
+}
+
+func (t *Table) drawCells(values []string, bold bool) {
+	x := tableMargin
+	for i, width := range t.widths {
+		if i < len(values) {
+			t.doc.Text(x, t.y, tableFontSize, bold, truncate(values[i], width))
+		}
+		x += width
+	}
+}
+
+// truncate shortens s so that it approximately fits into width points.
+func truncate(s string, width float64) string {
+	maxChars := int(width / (tableFontSize * averageCharSize))
+	if maxChars < 5 || utf8.RuneCountInString(s) <= maxChars-1 {
+		return s
+	}
+
+	runes := []rune(s)
+	return string(runes[:maxChars-4]) + "..."
+}
@@ -0,0 +1,130 @@
+package testutil_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/reservations"
+	"github.com/jryannel/spindit/internal/testutil"
+)
+
+func TestRequestReservationInvoicePaymentAssignment(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	now := testutil.FreezeClock(app, testutil.Start)
+
+	request, locker := testutil.ReservedRequest(t, app)
+
+	// request -> reservation
+	testutil.AssertString(t, "request status", request.GetString("status"), "reserved")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "reserved")
+
+	reservation, err := app.FindFirstRecordByData(reservations.Collection, "request", request.Id)
+	if err != nil {
+		t.Fatalf("expected a reservation: %v", err)
+	}
+	testutil.AssertString(t, "reserved locker", reservation.GetString("locker"), locker.Id)
+	if expiresAt := reservation.GetDateTime("expires_at").Time(); !expiresAt.Equal(testutil.Start.AddDate(0, 0, 7)) {
+		t.Fatalf("expected the reservation to expire 7 days after %s, got %s", testutil.Start, expiresAt)
+	}
+
+	// reservation -> invoice -> payment
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+	now.Advance(3 * 24 * time.Hour)
+	paidAt := now.Now()
+	if err := invoices.MarkPaid(app, invoice, paidAt); err != nil {
+		t.Fatalf("failed to mark the invoice as paid: %v", err)
+	}
+
+	// payment -> assignment
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, invoice).GetString("status"), "paid")
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "assigned")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "occupied")
+
+	assignment, err := app.FindFirstRecordByData("assignments", "request", request.Id)
+	if err != nil {
+		t.Fatalf("expected an assignment: %v", err)
+	}
+	testutil.AssertString(t, "assigned locker", assignment.GetString("locker"), locker.Id)
+	if !assignment.GetDateTime("assigned_at").Time().Equal(paidAt) {
+		t.Fatalf("expected assigned_at %s, got %s", paidAt, assignment.GetDateTime("assigned_at"))
+	}
+
+	testutil.AssertCount(t, app, reservations.Collection, dbx.HashExp{"request": request.Id}, 0)
+
+	// the paid request is not affected by later expiry runs
+	if _, err := reservations.Expire(app, testutil.Start.AddDate(0, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "assigned")
+}
+
+func TestReservationExpiry(t *testing.T) {
+	app := testutil.NewTestApp(t)
+	testutil.FreezeClock(app, testutil.Start)
+
+	request, locker := testutil.ReservedRequest(t, app)
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+
+	expired, err := reservations.Expire(app, testutil.Start.AddDate(0, 0, 7).Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 0 {
+		t.Fatalf("expected no expired reservations before the deadline, got %d", expired)
+	}
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "reserved")
+
+	expired, err = reservations.Expire(app, testutil.Start.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 {
+		t.Fatalf("expected 1 expired reservation, got %d", expired)
+	}
+
+	testutil.AssertString(t, "request status", testutil.Reload(t, app, request).GetString("status"), "expired")
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "free")
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, invoice).GetString("status"), "cancelled")
+	testutil.AssertCount(t, app, "assignments", dbx.HashExp{"request": request.Id}, 0)
+	testutil.AssertCount(t, app, reservations.Collection, dbx.HashExp{"request": request.Id}, 0)
+
+	if err := invoices.MarkPaid(app, testutil.Reload(t, app, invoice), testutil.Start.AddDate(0, 0, 8)); err == nil {
+		t.Fatal("expected paying a cancelled invoice to fail")
+	}
+}
+
+func TestRequestCancellation(t *testing.T) {
+	app := testutil.NewTestApp(t)
+
+	request, locker := testutil.ReservedRequest(t, app)
+	invoice := testutil.CreateInvoice(t, app, request, nil)
+
+	request.Set("status", "cancelled")
+	if err := app.Save(request); err != nil {
+		t.Fatalf("failed to cancel the request: %v", err)
+	}
+
+	testutil.AssertString(t, "locker status", testutil.Reload(t, app, locker).GetString("status"), "free")
+	testutil.AssertString(t, "invoice status", testutil.Reload(t, app, invoice).GetString("status"), "cancelled")
+	testutil.AssertCount(t, app, "assignments", dbx.HashExp{"request": request.Id}, 0)
+	testutil.AssertCount(t, app, reservations.Collection, dbx.HashExp{"request": request.Id}, 0)
+
+	// the freed locker is reserved by the next request of the zone
+	next := testutil.CreateRequest(t, app, testutil.CreateUser(t, app, nil), map[string]any{
+		"preferred_zone": locker.GetString("zone"),
+	})
+	testutil.AssertString(t, "next request status", next.GetString("status"), "reserved")
+
+	assignment, err := app.FindFirstRecordByData("assignments", "request", next.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		t.Fatal("expected the next request to be assigned the freed locker")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "reserved locker", assignment.GetString("locker"), locker.Id)
+}
@@ -0,0 +1,230 @@
+// Package testutil boots a PocketBase test app with all migrations and
+// Spindit hooks applied and provides factories for the core records.
+package testutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	spindit "github.com/jryannel/spindit/internal/app"
+	"github.com/jryannel/spindit/internal/app/access"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/invoices"
+	"github.com/jryannel/spindit/internal/app/requests"
+	_ "github.com/jryannel/spindit/migrations"
+)
+
+// Password is the password of all users created by [CreateUser].
+const Password = "testpassword123"
+
+// Start is the time tests freeze the clock at, the first day of the
+// 2024/25 school year.
+var Start = time.Date(2024, time.August, 1, 10, 0, 0, 0, time.UTC)
+
+var sequence atomic.Int64
+
+func next() int64 {
+	return sequence.Add(1)
+}
+
+// NewTestApp returns a fresh test app with all migrations (including the
+// seeded zones and lockers) applied and the same hooks and routes registered
+// as the server. The app is cleaned up when the test finishes.
+func NewTestApp(t testing.TB) *tests.TestApp {
+	t.Helper()
+
+	app := NewScenarioApp(t)
+	t.Cleanup(app.Cleanup)
+
+	return app
+}
+
+// NewScenarioApp returns a test app like [NewTestApp] that is not cleaned up
+// with the test, for the TestAppFactory of a [tests.ApiScenario], which
+// cleans up its app itself. Records the scenario needs, e.g. the user of
+// its auth token, can be created before it runs.
+func NewScenarioApp(t testing.TB) *tests.TestApp {
+	t.Helper()
+
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create the test app: %v", err)
+	}
+
+	if err := spindit.Register(app); err != nil {
+		t.Fatalf("failed to register the app hooks: %v", err)
+	}
+
+	return app
+}
+
+// FreezeClock replaces the clock of app with a fixed clock at now that the
+// test can move with [clock.Fixed.Set] and [clock.Fixed.Advance].
+func FreezeClock(app core.App, now time.Time) *clock.Fixed {
+	c := clock.NewFixed(now)
+	clock.Set(app, c)
+
+	return c
+}
+
+// CreateUser creates a user with the "family" role, overridden by data.
+func CreateUser(t testing.TB, app core.App, data map[string]any) *core.Record {
+	t.Helper()
+
+	n := next()
+
+	return create(t, app, "users", map[string]any{
+		"email":     fmt.Sprintf("user%d@example.com", n),
+		"password":  Password,
+		"full_name": fmt.Sprintf("Test User %d", n),
+		"role":      access.RoleFamily,
+		"verified":  true,
+	}, data)
+}
+
+// CreateZone creates a zone with a unique name and code, overridden by data.
+func CreateZone(t testing.TB, app core.App, data map[string]any) *core.Record {
+	t.Helper()
+
+	n := next()
+
+	return create(t, app, "zones", map[string]any{
+		"name": fmt.Sprintf("Test Zone %d", n),
+		"code": fmt.Sprintf("T%d", n),
+	}, data)
+}
+
+// CreateLocker creates a free locker in zone with a unique number, overridden by data.
+func CreateLocker(t testing.TB, app core.App, zone *core.Record, data map[string]any) *core.Record {
+	t.Helper()
+
+	return create(t, app, "lockers", map[string]any{
+		"zone":   zone.Id,
+		"number": 10000 + next(),
+		"status": "free",
+	}, data)
+}
+
+// CreateRequest submits a pending request of user, overridden by data.
+//
+// Like a real submission it triggers the automatic reservation, so the
+// returned record is reloaded to reflect the resulting status.
+func CreateRequest(t testing.TB, app core.App, user *core.Record, data map[string]any) *core.Record {
+	t.Helper()
+
+	n := next()
+
+	record := create(t, app, requests.Collection, map[string]any{
+		"user":              user.Id,
+		"requester_name":    user.GetString("full_name"),
+		"requester_address": "Teststraße 1, 12345 Berlin",
+		"requester_phone":   "+49301234567",
+		"student_name":      fmt.Sprintf("Student %d", n),
+		"student_class":     "5a",
+		"school_year":       "2024/25",
+		"status":            requests.StatusPending,
+		"submitted_at":      clock.Now(app),
+	}, data)
+
+	return Reload(t, app, record)
+}
+
+// CreateInvoice creates a sent invoice for request with a unique number, overridden by data.
+func CreateInvoice(t testing.TB, app core.App, request *core.Record, data map[string]any) *core.Record {
+	t.Helper()
+
+	return create(t, app, invoices.Collection, map[string]any{
+		"request":  request.Id,
+		"number":   fmt.Sprintf("INV-%06d", next()),
+		"amount":   20,
+		"currency": "EUR",
+		"status":   invoices.StatusSent,
+		"due_at":   clock.Now(app).AddDate(0, 0, 7),
+	}, data)
+}
+
+// ReservedRequest submits a request for the only locker of a fresh zone,
+// which reserves it.
+func ReservedRequest(t testing.TB, app core.App) (request, locker *core.Record) {
+	t.Helper()
+
+	family := CreateUser(t, app, nil)
+	zone := CreateZone(t, app, nil)
+	locker = CreateLocker(t, app, zone, nil)
+	request = CreateRequest(t, app, family, map[string]any{"preferred_zone": zone.Id})
+
+	return request, locker
+}
+
+// AssignedRequest submits and pays a request for the only locker of a
+// fresh zone, which assigns it.
+func AssignedRequest(t testing.TB, app core.App) (request, locker *core.Record) {
+	t.Helper()
+
+	request, locker = ReservedRequest(t, app)
+	invoice := CreateInvoice(t, app, request, nil)
+	if err := invoices.MarkPaid(app, invoice, clock.Now(app)); err != nil {
+		t.Fatalf("failed to mark the invoice as paid: %v", err)
+	}
+
+	return Reload(t, app, request), locker
+}
+
+// AssertString fails the test if got differs from want.
+func AssertString(t testing.TB, name string, got string, want string) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("expected %s %q, got %q", name, want, got)
+	}
+}
+
+// AssertCount fails the test unless collection holds want records matching where.
+func AssertCount(t testing.TB, app core.App, collection string, where dbx.Expression, want int64) {
+	t.Helper()
+
+	total, err := app.CountRecords(collection, where)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != want {
+		t.Fatalf("expected %d %s records, got %d", want, collection, total)
+	}
+}
+
+// Reload fetches the current state of record from the database.
+func Reload(t testing.TB, app core.App, record *core.Record) *core.Record {
+	t.Helper()
+
+	fresh, err := app.FindRecordById(record.Collection(), record.Id)
+	if err != nil {
+		t.Fatalf("failed to reload %s record %s: %v", record.Collection().Name, record.Id, err)
+	}
+
+	return fresh
+}
+
+func create(t testing.TB, app core.App, collectionName string, defaults map[string]any, data map[string]any) *core.Record {
+	t.Helper()
+
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		t.Fatalf("failed to load the %s collection: %v", collectionName, err)
+	}
+
+	record := core.NewRecord(collection)
+	record.Load(defaults)
+	record.Load(data)
+
+	if err := app.Save(record); err != nil {
+		t.Fatalf("failed to create %s record: %v", collectionName, err)
+	}
+
+	return record
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
@@ -13,9 +14,10 @@ import (
 	"github.com/pocketbase/pocketbase/tools/hook"
 	"github.com/pocketbase/pocketbase/tools/osutils"
 
+	spindit "github.com/jryannel/spindit/internal/app"
+	"github.com/jryannel/spindit/internal/app/clock"
+	"github.com/jryannel/spindit/internal/app/commands"
 	"github.com/jryannel/spindit/internal/app/cronjobs"
-	"github.com/jryannel/spindit/internal/app/hooks/autoreserve"
-	"github.com/jryannel/spindit/internal/pbext/pdf"
 	_ "github.com/jryannel/spindit/migrations"
 )
 
@@ -54,8 +56,25 @@ func main() {
 		"fallback missing static paths to index.html (SPA support)",
 	)
 
+	var fakeNow string
+	app.RootCmd.PersistentFlags().StringVar(
+		&fakeNow,
+		"fake-now",
+		"",
+		"run hooks and jobs as if the current time were this (RFC 3339 or YYYY-MM-DD [HH:MM]), for staging rehearsals",
+	)
+
 	app.RootCmd.ParseFlags(os.Args[1:])
 
+	if fakeNow != "" {
+		now, err := clock.Parse(fakeNow, clock.Location())
+		if err != nil {
+			log.Fatalf("invalid --fake-now value: %v", err)
+		}
+		clock.Set(app, clock.NewOffset(now))
+		log.Printf("WARNING: hooks and jobs run with a fake clock starting at %s", now.Format(time.RFC3339))
+	}
+
 	migratecmd.MustRegister(app, app.RootCmd, migratecmd.Config{
 		TemplateLang: migratecmd.TemplateLangGo,
 		Automigrate:  automigrate,
@@ -72,13 +91,13 @@ func main() {
 		Priority: 999,
 	})
 
-	if err := pdf.Register(app); err != nil {
+	commands.Register(app)
+	cronjobs.Register(app)
+
+	if err := spindit.Register(app); err != nil {
 		log.Fatal(err)
 	}
 
-	cronjobs.Register(app)
-	autoreserve.Register(app)
-
 	if err := app.Start(); err != nil {
 		log.Fatal(err)
 	}
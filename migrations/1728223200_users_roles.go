@@ -0,0 +1,225 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Access rule building blocks for the role model.
+const (
+	roleAdminRule        = `@request.auth.role = "admin"`
+	roleStaffRule        = `(@request.auth.role = "staff" || @request.auth.role = "admin")`
+	roleStaffJanitorRule = `(@request.auth.role = "staff" || @request.auth.role = "admin" || @request.auth.role = "janitor")`
+	ownRequestRule       = `(@collection.requests:auth.id ?= request && @collection.requests:auth.user ?= @request.auth.id)`
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		users.Fields.Add(&core.SelectField{
+			Name:        "role",
+			Presentable: true,
+			Values:      []string{"family", "janitor", "staff", "admin"},
+			MaxSelect:   1,
+		})
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		if users.Fields.GetByName("is_staff") != nil {
+			_, err := app.DB().NewQuery(
+				"UPDATE {{users}} SET [[role]] = CASE WHEN [[is_staff]] = TRUE THEN 'staff' ELSE 'family' END",
+			).Execute()
+			if err != nil {
+				return err
+			}
+
+			users.Fields.RemoveByName("is_staff")
+		}
+
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		return setRoleAccessRules(app)
+	}, func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		users.Fields.Add(&core.BoolField{
+			Name:        "is_staff",
+			Presentable: true,
+		})
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery(
+			"UPDATE {{users}} SET [[is_staff]] = ([[role]] IN ('staff', 'admin'))",
+		).Execute()
+		if err != nil {
+			return err
+		}
+
+		users.Fields.RemoveByName("role")
+		users.CreateRule = types.Pointer("")
+		users.UpdateRule = types.Pointer("id = @request.auth.id")
+		users.DeleteRule = types.Pointer("id = @request.auth.id")
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		return restoreStaffFlagRules(app)
+	})
+}
+
+// setRoleAccessRules rewrites the collection rules of the init migration for the
+// family/janitor/staff/admin role model.
+//
+// Janitors can read zones, lockers and assignments (which only reference the
+// request and locker ids) but never requests, invoices or user contact details.
+// Only admins can grant the admin role.
+func setRoleAccessRules(app core.App) error {
+	authRule := "@request.auth.id != ''"
+
+	rules := map[string]struct {
+		list, view, create, update, delete *string
+	}{
+		"users": {
+			list: types.Pointer(roleStaffRule),
+			view: types.Pointer(roleStaffRule + " || id = @request.auth.id"),
+			create: types.Pointer(
+				`@request.body.role:isset = false || @request.body.role = "family" || ` + roleAdminRule +
+					` || (@request.auth.role = "staff" && @request.body.role != "admin")`,
+			),
+			update: types.Pointer(
+				roleAdminRule +
+					` || (@request.auth.role = "staff" && role != "admin" && @request.body.role != "admin")` +
+					` || (id = @request.auth.id && @request.body.role:isset = false)`,
+			),
+			delete: types.Pointer(
+				roleAdminRule + ` || (@request.auth.role = "staff" && role != "admin") || id = @request.auth.id`,
+			),
+		},
+		"zones": {
+			list:   types.Pointer(authRule),
+			view:   types.Pointer(authRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"lockers": {
+			list:   types.Pointer(authRule),
+			view:   types.Pointer(authRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"requests": {
+			list:   types.Pointer(roleStaffRule + " || user = @request.auth.id"),
+			view:   types.Pointer(roleStaffRule + " || user = @request.auth.id"),
+			create: types.Pointer(roleStaffRule + " || user = @request.auth.id"),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"reservations": {
+			list:   types.Pointer(roleStaffRule),
+			view:   types.Pointer(roleStaffRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"invoices": {
+			list:   types.Pointer(roleStaffRule + " || " + ownRequestRule),
+			view:   types.Pointer(roleStaffRule + " || " + ownRequestRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"assignments": {
+			list:   types.Pointer(roleStaffJanitorRule + " || " + ownRequestRule),
+			view:   types.Pointer(roleStaffJanitorRule + " || " + ownRequestRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"renewals": {
+			list:   types.Pointer(roleStaffRule + " || (@collection.assignments:auth.id ?= assignment && @collection.assignments:auth.request ?= @collection.requests:auth.id && @collection.requests:auth.user ?= @request.auth.id)"),
+			view:   types.Pointer(roleStaffRule + " || (@collection.assignments:auth.id ?= assignment && @collection.assignments:auth.request ?= @collection.requests:auth.id && @collection.requests:auth.user ?= @request.auth.id)"),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"email_queue": {
+			list:   types.Pointer(roleStaffRule),
+			view:   types.Pointer(roleStaffRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+		"audit_logs": {
+			list:   types.Pointer(roleStaffRule),
+			view:   types.Pointer(roleStaffRule),
+			create: types.Pointer(roleStaffRule),
+			update: types.Pointer(roleStaffRule),
+			delete: types.Pointer(roleStaffRule),
+		},
+	}
+
+	for name, rule := range rules {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			return err
+		}
+
+		collection.ListRule = rule.list
+		collection.ViewRule = rule.view
+		collection.CreateRule = rule.create
+		collection.UpdateRule = rule.update
+		collection.DeleteRule = rule.delete
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreStaffFlagRules reverts the collection rules to the is_staff based variant.
+func restoreStaffFlagRules(app core.App) error {
+	staffRule := "@request.auth.is_staff = true"
+
+	for _, name := range []string{"zones", "lockers", "reservations", "invoices", "assignments", "renewals", "email_queue", "audit_logs", "requests"} {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			return err
+		}
+
+		collection.CreateRule = types.Pointer(staffRule)
+		collection.UpdateRule = types.Pointer(staffRule)
+		collection.DeleteRule = types.Pointer(staffRule)
+
+		switch name {
+		case "reservations", "email_queue", "audit_logs":
+			collection.ListRule = types.Pointer(staffRule)
+			collection.ViewRule = types.Pointer(staffRule)
+		case "zones", "lockers":
+			collection.ListRule = types.Pointer("@request.auth.id != ''")
+			collection.ViewRule = types.Pointer("@request.auth.id != ''")
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+	}
+
+	return setAccessRules(app)
+}
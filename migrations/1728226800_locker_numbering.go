@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		if err := createAppSettingsCollection(app); err != nil {
+			return err
+		}
+
+		if err := addZoneCodes(app); err != nil {
+			return err
+		}
+
+		return addLockerLabels(app)
+	}, func(app core.App) error {
+		if collection, err := app.FindCollectionByNameOrId("lockers"); err == nil {
+			collection.RemoveIndex("idx_lockers_label")
+			collection.RemoveIndex("idx_lockers_zone_number")
+			collection.Fields.RemoveByName("label")
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		if zones, err := app.FindCollectionByNameOrId("zones"); err == nil {
+			zones.RemoveIndex("idx_zones_code")
+			zones.Fields.RemoveByName("code")
+			if err := app.Save(zones); err != nil {
+				return err
+			}
+		}
+
+		if settings, err := app.FindCollectionByNameOrId("app_settings"); err == nil {
+			return app.Delete(settings)
+		}
+
+		return nil
+	})
+}
+
+// createAppSettingsCollection creates the singleton collection holding the
+// school wide configuration, editable by admins.
+func createAppSettingsCollection(app core.App) error {
+	collection := core.NewBaseCollection("app_settings", "k2v7s0e1t2t8n9g")
+
+	collection.Fields.Add(&core.SelectField{
+		Name:        "locker_numbering",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"global", "zone"},
+		MaxSelect:   1,
+	})
+
+	collection.ListRule = types.Pointer(roleAdminRule)
+	collection.ViewRule = types.Pointer(roleAdminRule)
+	collection.UpdateRule = types.Pointer(roleAdminRule)
+
+	if err := saveCollection(app, collection); err != nil {
+		return err
+	}
+
+	total, err := app.CountRecords(collection)
+	if err != nil || total > 0 {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("locker_numbering", "global")
+
+	return app.Save(record)
+}
+
+func addZoneCodes(app core.App) error {
+	zones, err := app.FindCollectionByNameOrId("zones")
+	if err != nil {
+		return err
+	}
+
+	zones.Fields.Add(&core.TextField{
+		Name:        "code",
+		Presentable: true,
+		Max:         8,
+		Pattern:     `^[A-Z0-9]+$`,
+	})
+	if err := app.Save(zones); err != nil {
+		return err
+	}
+
+	rows := []struct {
+		Id   string `db:"id"`
+		Name string `db:"name"`
+	}{}
+	if err := app.DB().NewQuery("SELECT [[id]], [[name]] FROM {{zones}}").All(&rows); err != nil {
+		return err
+	}
+
+	// backfill with plain SQL, saving the records would run the zone hooks
+	used := map[string]bool{}
+	for _, row := range rows {
+		base := zoneCode(row.Name)
+		code := base
+		for i := 2; used[code]; i++ {
+			code = base + strconv.Itoa(i)
+		}
+		used[code] = true
+
+		if _, err := app.DB().Update("zones", dbx.Params{"code": code}, dbx.HashExp{"id": row.Id}).Execute(); err != nil {
+			return err
+		}
+	}
+
+	zones.AddIndex("idx_zones_code", true, "code", "code != ''")
+
+	return app.Save(zones)
+}
+
+func addLockerLabels(app core.App) error {
+	collection, err := app.FindCollectionByNameOrId("lockers")
+	if err != nil {
+		return err
+	}
+
+	duplicates := []struct {
+		Number int `db:"number"`
+		Total  int `db:"total"`
+	}{}
+	err = app.DB().NewQuery(
+		"SELECT [[number]], COUNT(*) AS [[total]] FROM {{lockers}} GROUP BY [[number]] HAVING COUNT(*) > 1 ORDER BY [[number]]",
+	).All(&duplicates)
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		numbers := make([]string, len(duplicates))
+		for i, d := range duplicates {
+			numbers[i] = fmt.Sprintf("%d (%dx)", d.Number, d.Total)
+		}
+		return fmt.Errorf("cannot add unique locker numbers, resolve the duplicate lockers first: %s", strings.Join(numbers, ", "))
+	}
+
+	collection.Fields.Add(&core.TextField{
+		Name:        "label",
+		Presentable: true,
+		Max:         16,
+	})
+	if err := app.Save(collection); err != nil {
+		return err
+	}
+
+	// the default "global" numbering scheme uses the plain number as label
+	if _, err := app.DB().NewQuery("UPDATE {{lockers}} SET [[label]] = CAST([[number]] AS TEXT)").Execute(); err != nil {
+		return err
+	}
+
+	collection.AddIndex("idx_lockers_label", true, "label", "")
+	collection.AddIndex("idx_lockers_zone_number", true, "zone, number", "")
+
+	return app.Save(collection)
+}
+
+// zoneCode derives a short code from the last word of a zone name ("Zone A"
+// -> "A"). It is a frozen copy of lockers.ZoneCode as of this migration.
+func zoneCode(name string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return "Z"
+	}
+
+	code := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, words[len(words)-1])
+
+	if len(code) > 6 {
+		code = code[:6]
+	}
+	if code == "" {
+		return "Z"
+	}
+
+	return code
+}
@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"golang.org/x/text/unicode/norm"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("requests")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.TextField{
+			Name:   "student_key",
+			Hidden: true,
+			Max:    120,
+		})
+		collection.Fields.Add(&core.BoolField{
+			Name: "allow_duplicate",
+		})
+		collection.AddIndex("idx_requests_student_key", false, "user, school_year, student_key", "")
+
+		// families may never bypass the duplicate guard themselves
+		collection.CreateRule = types.Pointer(
+			roleStaffRule + " || (user = @request.auth.id && (@request.body.allow_duplicate:isset = false || @request.body.allow_duplicate = false))",
+		)
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// backfill with plain SQL, saving the records would run the
+		// duplicate guard of the requests hooks
+		rows := []struct {
+			Id          string `db:"id"`
+			StudentName string `db:"student_name"`
+		}{}
+		if err := app.DB().NewQuery("SELECT [[id]], [[student_name]] FROM {{requests}}").All(&rows); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			_, err := app.DB().NewQuery("UPDATE {{requests}} SET [[student_key]] = {:key} WHERE [[id]] = {:id}").
+				Bind(dbx.Params{"key": studentKey(row.StudentName), "id": row.Id}).
+				Execute()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("requests")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_requests_student_key")
+		collection.Fields.RemoveByName("student_key")
+		collection.Fields.RemoveByName("allow_duplicate")
+		collection.CreateRule = types.Pointer(roleStaffRule + " || user = @request.auth.id")
+
+		return app.Save(collection)
+	})
+}
+
+// studentKey normalizes a student name for duplicate detection ("  Jörg
+// MÜLLER " -> "jorg muller"). It is a frozen copy of requests.StudentKey as
+// of this migration.
+func studentKey(name string) string {
+	decomposed := norm.NFD.String(strings.ReplaceAll(strings.ToLower(name), "ß", "ss"))
+
+	key := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			return -1
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return r
+		default:
+			return ' '
+		}
+	}, decomposed)
+
+	return strings.Join(strings.Fields(key), " ")
+}
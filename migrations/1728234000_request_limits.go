@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.NumberField{
+			Name:    "max_active_requests_per_family",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+		})
+		collection.Fields.Add(&core.NumberField{
+			Name:    "max_requests_per_hour",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+		})
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			record.Set("max_active_requests_per_family", 5)
+			record.Set("max_requests_per_hour", 10)
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("max_active_requests_per_family")
+		collection.Fields.RemoveByName("max_requests_per_hour")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.NumberField{
+			Name:    "reservation_days",
+			OnlyInt: true,
+			Min:     types.Pointer(1.0),
+		})
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			record.Set("reservation_days", 7)
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("reservation_days")
+
+		return app.Save(collection)
+	})
+}
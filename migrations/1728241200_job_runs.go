@@ -0,0 +1,82 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		return createJobRunsCollection(app)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("job_runs")
+		if err != nil {
+			return nil
+		}
+
+		return app.Delete(collection)
+	})
+}
+
+// createJobRunsCollection creates the history of scheduled and manual job
+// executions. Runs are only written by the server, staff can read them.
+func createJobRunsCollection(app core.App) error {
+	collection := core.NewBaseCollection("job_runs", "j0br7n5h1st0ry1")
+
+	collection.Fields.Add(&core.TextField{
+		Name:        "job",
+		Presentable: true,
+		Required:    true,
+		Max:         80,
+		Pattern:     `^[a-z0-9_.]+$`,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:        "trigger",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"schedule", "manual"},
+		MaxSelect:   1,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "triggered_by",
+		Max:  255,
+	})
+	collection.Fields.Add(&core.BoolField{
+		Name: "dry_run",
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:        "status",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"running", "success", "failed"},
+		MaxSelect:   1,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name:     "started_at",
+		Required: true,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name: "finished_at",
+	})
+	collection.Fields.Add(&core.NumberField{
+		Name:    "affected",
+		OnlyInt: true,
+		Min:     types.Pointer(0.0),
+	})
+	collection.Fields.Add(&core.JSONField{
+		Name:    "details",
+		MaxSize: 4000,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "error",
+		Max:  2000,
+	})
+
+	collection.AddIndex("idx_job_runs_job_started", false, "job, started_at", "")
+
+	collection.ListRule = types.Pointer(roleStaffRule)
+	collection.ViewRule = types.Pointer(roleStaffRule)
+
+	return saveCollection(app, collection)
+}
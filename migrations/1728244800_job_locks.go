@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		if err := createJobLocksCollection(app); err != nil {
+			return err
+		}
+
+		return setJobRunStatuses(app, []string{"running", "success", "failed", "skipped"})
+	}, func(app core.App) error {
+		if err := setJobRunStatuses(app, []string{"running", "success", "failed"}); err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("job_locks")
+		if err != nil {
+			return nil
+		}
+
+		return app.Delete(collection)
+	})
+}
+
+// createJobLocksCollection creates the job leases. A lease is held by one
+// server instance until it is released or expires, and is only accessible
+// to superusers.
+func createJobLocksCollection(app core.App) error {
+	collection := core.NewBaseCollection("job_locks", "j0bl0ck5l3as3s1")
+
+	collection.Fields.Add(&core.TextField{
+		Name:        "job",
+		Presentable: true,
+		Required:    true,
+		Max:         80,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:     "holder",
+		Required: true,
+		Max:      255,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name:     "acquired_at",
+		Required: true,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name:     "expires_at",
+		Required: true,
+	})
+
+	collection.AddIndex("idx_job_locks_job", true, "job", "")
+
+	return saveCollection(app, collection)
+}
+
+func setJobRunStatuses(app core.App, values []string) error {
+	collection, err := app.FindCollectionByNameOrId("job_runs")
+	if err != nil {
+		return err
+	}
+
+	field, ok := collection.Fields.GetByName("status").(*core.SelectField)
+	if !ok {
+		return nil
+	}
+	field.Values = values
+
+	return app.Save(collection)
+}
@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		if err := addRetentionSettings(app); err != nil {
+			return err
+		}
+
+		requests, err := app.FindCollectionByNameOrId("requests")
+		if err != nil {
+			return err
+		}
+		requests.Fields.Add(&core.DateField{Name: "anonymized_at"})
+		if err := app.Save(requests); err != nil {
+			return err
+		}
+
+		emails, err := app.FindCollectionByNameOrId("email_queue")
+		if err != nil {
+			return err
+		}
+		emails.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		emails.Fields.Add(&core.DateField{Name: "anonymized_at"})
+
+		return app.Save(emails)
+	}, func(app core.App) error {
+		if emails, err := app.FindCollectionByNameOrId("email_queue"); err == nil {
+			emails.Fields.RemoveByName("created")
+			emails.Fields.RemoveByName("anonymized_at")
+			if err := app.Save(emails); err != nil {
+				return err
+			}
+		}
+
+		if requests, err := app.FindCollectionByNameOrId("requests"); err == nil {
+			requests.Fields.RemoveByName("anonymized_at")
+			if err := app.Save(requests); err != nil {
+				return err
+			}
+		}
+
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("retention_requests_months")
+		collection.Fields.RemoveByName("retention_emails_months")
+
+		return app.Save(collection)
+	})
+}
+
+// addRetentionSettings adds the retention periods, in months after the end of
+// a school year, to app_settings. A period of 0 keeps the data.
+func addRetentionSettings(app core.App) error {
+	collection, err := app.FindCollectionByNameOrId("app_settings")
+	if err != nil {
+		return err
+	}
+
+	collection.Fields.Add(&core.NumberField{
+		Name:    "retention_requests_months",
+		OnlyInt: true,
+		Min:     types.Pointer(0.0),
+	})
+	collection.Fields.Add(&core.NumberField{
+		Name:    "retention_emails_months",
+		OnlyInt: true,
+		Min:     types.Pointer(0.0),
+	})
+	if err := app.Save(collection); err != nil {
+		return err
+	}
+
+	records, err := app.FindAllRecords(collection)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		record.Set("retention_requests_months", 24)
+		record.Set("retention_emails_months", 6)
+		if err := app.Save(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// backupRetentionFields are the app_settings fields with the number of
+// daily, weekly and monthly backups to keep, and their defaults.
+var backupRetentionFields = []struct {
+	name  string
+	value int
+}{
+	{"backup_keep_daily", 7},
+	{"backup_keep_weekly", 4},
+	{"backup_keep_monthly", 6},
+}
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		for _, field := range backupRetentionFields {
+			collection.Fields.Add(&core.NumberField{
+				Name:    field.name,
+				OnlyInt: true,
+				Min:     types.Pointer(0.0),
+			})
+		}
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			for _, field := range backupRetentionFields {
+				record.Set(field.name, field.value)
+			}
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		for _, field := range backupRetentionFields {
+			collection.Fields.RemoveByName(field.name)
+		}
+
+		return app.Save(collection)
+	})
+}
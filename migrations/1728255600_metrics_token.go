@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		// bearer token of the /metrics endpoint, empty disables the endpoint
+		collection.Fields.Add(&core.TextField{
+			Name: "metrics_token",
+			Max:  200,
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("metrics_token")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,126 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// alertThresholdFields are the app_settings fields of the email bounce
+// alert, and their defaults.
+var alertThresholdFields = []struct {
+	name  string
+	value int
+}{
+	{"alert_bounce_rate_percent", 20},
+	{"alert_bounce_min_failures", 5},
+}
+
+func init() {
+	pm.Register(func(app core.App) error {
+		if err := createAlertsCollection(app); err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		// receives a JSON POST for every fired and resolved alert
+		collection.Fields.Add(&core.URLField{
+			Name: "alert_webhook_url",
+		})
+		for _, field := range alertThresholdFields {
+			collection.Fields.Add(&core.NumberField{
+				Name:    field.name,
+				OnlyInt: true,
+				Min:     types.Pointer(0.0),
+			})
+		}
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			for _, field := range alertThresholdFields {
+				record.Set(field.name, field.value)
+			}
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("alert_webhook_url")
+		for _, field := range alertThresholdFields {
+			collection.Fields.RemoveByName(field.name)
+		}
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		alerts, err := app.FindCollectionByNameOrId("alerts")
+		if err != nil {
+			return nil
+		}
+
+		return app.Delete(alerts)
+	})
+}
+
+// createAlertsCollection creates the alert state used to deduplicate
+// notifications. An alert stays firing until its condition clears, then it
+// is resolved. Alerts are only written by the server, staff can read them.
+func createAlertsCollection(app core.App) error {
+	collection := core.NewBaseCollection("alerts", "a1ert5st4t3s001")
+
+	collection.Fields.Add(&core.TextField{
+		Name:        "rule",
+		Presentable: true,
+		Required:    true,
+		Max:         80,
+		Pattern:     `^[a-z0-9_]+$`,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:     "key",
+		Required: true,
+		Max:      200,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:        "status",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"firing", "resolved"},
+		MaxSelect:   1,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "message",
+		Max:  1000,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name:     "fired_at",
+		Required: true,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name: "resolved_at",
+	})
+
+	collection.AddIndex("idx_alerts_key_status", false, "key, status", "")
+
+	collection.ListRule = types.Pointer(roleStaffRule)
+	collection.ViewRule = types.Pointer(roleStaffRule)
+
+	return saveCollection(app, collection)
+}
@@ -0,0 +1,144 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// webhookEvents are the domain events a webhook can subscribe to.
+var webhookEvents = []string{
+	"request.created",
+	"reservation.expired",
+	"invoice.paid",
+	"assignment.created",
+	"locker.status_changed",
+}
+
+func init() {
+	pm.Register(func(app core.App) error {
+		if err := createWebhooksCollection(app); err != nil {
+			return err
+		}
+
+		return createWebhookDeliveriesCollection(app)
+	}, func(app core.App) error {
+		for _, name := range []string{"webhook_deliveries", "webhooks"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := app.Delete(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// createWebhooksCollection creates the outbound webhook subscriptions. They
+// hold the signing secrets and are therefore managed by superusers only.
+func createWebhooksCollection(app core.App) error {
+	collection := core.NewBaseCollection("webhooks", "w3bh00k5ub5crb1")
+
+	collection.Fields.Add(&core.URLField{
+		Name:        "url",
+		Presentable: true,
+		Required:    true,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:     "secret",
+		Required: true,
+		Min:      16,
+		Max:      255,
+		Hidden:   true,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:      "events",
+		Required:  true,
+		Values:    webhookEvents,
+		MaxSelect: len(webhookEvents),
+	})
+	collection.Fields.Add(&core.BoolField{
+		Name: "active",
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "description",
+		Max:  255,
+	})
+
+	return saveCollection(app, collection)
+}
+
+// createWebhookDeliveriesCollection creates the delivery log. Every event
+// is delivered once per subscribed webhook and retried until it succeeds or
+// runs out of attempts; staff can read the log.
+func createWebhookDeliveriesCollection(app core.App) error {
+	collection := core.NewBaseCollection("webhook_deliveries", "w3bh00kd3l1v3r1")
+
+	collection.Fields.Add(&core.RelationField{
+		Name:          "webhook",
+		Required:      true,
+		CollectionId:  "w3bh00k5ub5crb1",
+		CascadeDelete: true,
+		MaxSelect:     1,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:        "event",
+		Presentable: true,
+		Required:    true,
+		Max:         80,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:     "event_id",
+		Required: true,
+		Max:      40,
+	})
+	collection.Fields.Add(&core.JSONField{
+		Name:    "payload",
+		MaxSize: 1 << 20,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:        "status",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"pending", "delivered", "failed"},
+		MaxSelect:   1,
+	})
+	collection.Fields.Add(&core.NumberField{
+		Name:    "attempts",
+		OnlyInt: true,
+		Min:     types.Pointer(0.0),
+	})
+	collection.Fields.Add(&core.DateField{
+		Name: "next_attempt_at",
+	})
+	collection.Fields.Add(&core.NumberField{
+		Name:    "response_status",
+		OnlyInt: true,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "error",
+		Max:  1000,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name: "delivered_at",
+	})
+	// id of the replayed delivery
+	collection.Fields.Add(&core.TextField{
+		Name: "replay_of",
+		Max:  15,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+
+	collection.AddIndex("idx_webhook_deliveries_status_next", false, "status, next_attempt_at", "")
+
+	collection.ListRule = types.Pointer(roleStaffRule)
+	collection.ViewRule = types.Pointer(roleStaffRule)
+
+	return saveCollection(app, collection)
+}
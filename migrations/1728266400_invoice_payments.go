@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+)
+
+// invoicePaymentFields link an invoice to the checkout session and the
+// payment of an online payment provider.
+var invoicePaymentFields = []string{"payment_provider", "payment_session", "payment_reference"}
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.TextField{
+			Name: "payment_provider",
+			Max:  40,
+		})
+		collection.Fields.Add(&core.TextField{
+			Name: "payment_session",
+			Max:  255,
+		})
+		collection.Fields.Add(&core.TextField{
+			Name: "payment_reference",
+			Max:  255,
+		})
+
+		collection.AddIndex("idx_invoices_payment_session", false, "payment_provider, payment_session", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_invoices_payment_session")
+		for _, name := range invoicePaymentFields {
+			collection.Fields.RemoveByName(name)
+		}
+
+		return app.Save(collection)
+	})
+}
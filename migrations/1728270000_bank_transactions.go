@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		return createBankTransactionsCollection(app)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("bank_transactions")
+		if err != nil {
+			return nil
+		}
+
+		return app.Delete(collection)
+	})
+}
+
+// createBankTransactionsCollection creates the incoming transfers of the
+// imported bank statements. Exact matches are stored as matched; partial,
+// over-paid, unmatched and repeated payments form the review queue, which
+// staff work off by setting the status to resolved.
+func createBankTransactionsCollection(app core.App) error {
+	collection := core.NewBaseCollection("bank_transactions", "b4nktr4n54ct10n")
+
+	collection.Fields.Add(&core.TextField{
+		Name: "statement",
+		Max:  255,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name:        "booked_at",
+		Presentable: true,
+		Required:    true,
+	})
+	collection.Fields.Add(&core.NumberField{
+		Name:        "amount",
+		Presentable: true,
+		Required:    true,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "currency",
+		Max:  3,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "payer",
+		Max:  255,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "reference",
+		Max:  1000,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "bank_reference",
+		Max:  255,
+	})
+	// identifies the transfer across overlapping statements
+	collection.Fields.Add(&core.TextField{
+		Name:     "fingerprint",
+		Required: true,
+		Max:      100,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:        "status",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"matched", "partial", "overpaid", "unmatched", "already_paid", "resolved"},
+		MaxSelect:   1,
+	})
+	collection.Fields.Add(&core.RelationField{
+		Name:          "invoice",
+		CollectionId:  "vemsb4s051evn7f",
+		CascadeDelete: false,
+		MaxSelect:     1,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "note",
+		Max:  500,
+	})
+	collection.Fields.Add(&core.RelationField{
+		Name:          "imported_by",
+		CollectionId:  "_pb_users_auth_",
+		CascadeDelete: false,
+		MaxSelect:     1,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+
+	collection.AddIndex("idx_bank_transactions_fingerprint", true, "fingerprint", "")
+	collection.AddIndex("idx_bank_transactions_status", false, "status", "")
+
+	collection.ListRule = types.Pointer(roleStaffRule)
+	collection.ViewRule = types.Pointer(roleStaffRule)
+	collection.UpdateRule = types.Pointer(roleStaffRule)
+
+	return saveCollection(app, collection)
+}
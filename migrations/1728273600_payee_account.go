@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		// bank account printed on the invoices and encoded in their GiroCode,
+		// an empty IBAN omits the payment details
+		collection.Fields.Add(&core.TextField{
+			Name: "payee_name",
+			Max:  70,
+		})
+		collection.Fields.Add(&core.TextField{
+			Name:    "payee_iban",
+			Max:     42,
+			Pattern: `^[A-Za-z]{2}[0-9]{2}[A-Za-z0-9 ]{11,38}$`,
+		})
+		collection.Fields.Add(&core.TextField{
+			Name:    "payee_bic",
+			Max:     11,
+			Pattern: `^[A-Za-z0-9]{8}([A-Za-z0-9]{3})?$`,
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("app_settings")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"payee_name", "payee_iban", "payee_bic"} {
+			collection.Fields.RemoveByName(name)
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		return createPaymentReviewsCollection(app)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("payment_reviews")
+		if err != nil {
+			return nil
+		}
+
+		return app.Delete(collection)
+	})
+}
+
+// createPaymentReviewsCollection creates the review queue of verified online
+// payments that could not be booked on an invoice, e.g. a payment for an
+// invoice cancelled while the family was at the checkout. Staff refund or
+// book them by hand and set the status to resolved.
+func createPaymentReviewsCollection(app core.App) error {
+	collection := core.NewBaseCollection("payment_reviews", "p4ym3n7r3v13w5q")
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "provider",
+		Required: true,
+		Max:      40,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:     "session",
+		Required: true,
+		Max:      255,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "reference",
+		Max:  255,
+	})
+	collection.Fields.Add(&core.RelationField{
+		Name:          "invoice",
+		CollectionId:  "vemsb4s051evn7f",
+		CascadeDelete: false,
+		MaxSelect:     1,
+	})
+	collection.Fields.Add(&core.NumberField{
+		Name:        "amount",
+		Presentable: true,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "currency",
+		Max:  3,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name: "paid_at",
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:      "reason",
+		Required:  true,
+		Values:    []string{"unknown_session", "not_payable", "amount_mismatch", "already_paid"},
+		MaxSelect: 1,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:        "status",
+		Presentable: true,
+		Required:    true,
+		Values:      []string{"open", "resolved"},
+		MaxSelect:   1,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "note",
+		Max:  500,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+
+	// a provider completes a checkout session once, repeated webhooks of
+	// a queued payment do not queue it again
+	collection.AddIndex("idx_payment_reviews_session", true, "provider, session", "")
+	collection.AddIndex("idx_payment_reviews_status", false, "status", "")
+
+	collection.ListRule = types.Pointer(roleStaffRule)
+	collection.ViewRule = types.Pointer(roleStaffRule)
+	collection.UpdateRule = types.Pointer(roleStaffRule)
+
+	return saveCollection(app, collection)
+}
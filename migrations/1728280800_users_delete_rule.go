@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// usersDeleteRule is the delete rule of the users collection set by the
+// role migration.
+const usersDeleteRule = roleAdminRule + ` || (@request.auth.role = "staff" && role != "admin") || id = @request.auth.id`
+
+func init() {
+	pm.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// accounts are removed through the right to erasure routes, which
+		// keep invoices and write the audit record; deleting them through
+		// the records API is left to superusers
+		users.DeleteRule = nil
+
+		return app.Save(users)
+	}, func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		users.DeleteRule = types.Pointer(usersDeleteRule)
+
+		return app.Save(users)
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("bank_transactions")
+		if err != nil {
+			return err
+		}
+
+		// set once the payer and remittance text were removed by the
+		// retention or an erasure
+		collection.Fields.Add(&core.DateField{Name: "anonymized_at"})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("bank_transactions")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("anonymized_at")
+
+		return app.Save(collection)
+	})
+}
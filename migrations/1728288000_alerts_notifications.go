@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// alertChannelFields record the notified status per notification channel.
+var alertChannelFields = []string{"notified_email_status", "notified_webhook_status"}
+
+func init() {
+	pm.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alerts")
+		if err != nil {
+			return err
+		}
+
+		// the last status staff were emailed and the webhook was posted,
+		// each channel is notified again until it matches status
+		for _, name := range alertChannelFields {
+			collection.Fields.Add(&core.SelectField{
+				Name:      name,
+				Values:    []string{"firing", "resolved"},
+				MaxSelect: 1,
+			})
+		}
+		collection.Fields.Add(&core.NumberField{
+			Name:    "notify_attempts",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+		})
+		collection.Fields.Add(&core.TextField{
+			Name: "notify_error",
+			Max:  2000,
+		})
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// existing alerts were notified when they fired or resolved
+		_, err = app.DB().Update("alerts", dbx.Params{
+			"notified_email_status":   dbx.NewExp("[[status]]"),
+			"notified_webhook_status": dbx.NewExp("[[status]]"),
+		}, nil).Execute()
+
+		return err
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alerts")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range alertChannelFields {
+			collection.Fields.RemoveByName(name)
+		}
+		collection.Fields.RemoveByName("notify_attempts")
+		collection.Fields.RemoveByName("notify_error")
+
+		return app.Save(collection)
+	})
+}
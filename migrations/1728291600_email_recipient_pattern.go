@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	pm "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	pm.Register(func(app core.App) error {
+		// the initial pattern excluded the letter "n" instead of line breaks
+		return setEmailRecipientPattern(app, `^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	}, func(app core.App) error {
+		return setEmailRecipientPattern(app, `^[^@\\n]+@[^@\\n]+\\.[^@\\n]+$`)
+	})
+}
+
+func setEmailRecipientPattern(app core.App, pattern string) error {
+	collection, err := app.FindCollectionByNameOrId("email_queue")
+	if err != nil {
+		return err
+	}
+
+	if recipient, ok := collection.Fields.GetByName("recipient").(*core.TextField); ok {
+		recipient.Pattern = pattern
+	}
+
+	return app.Save(collection)
+}